@@ -0,0 +1,162 @@
+package backlight
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultAmbientInterval is the sampling interval of Ambient when none is given.
+const DefaultAmbientInterval = time.Second
+
+// ErrLuxCurve is returned by ParseLuxCurve when the string is not a list of lux:percent points.
+var ErrLuxCurve = errors.New("Error lux curve must be like 0:5,100:40,1000:80 with increasing lux")
+
+// LuxPoint is a point of a LuxCurve, the brightness percentage of an illuminance in lux.
+type LuxPoint struct {
+	Lux     float64
+	Percent float64
+}
+
+// LuxCurve maps the illuminances to brightness percentages, with points ordered by lux.
+// The percentage is interpolated between the points on a logarithmic scale of lux, like the eye perceives it,
+// and is the one of the first or last point outside of them.
+type LuxCurve []LuxPoint
+
+// DefaultLuxCurve goes from a dim panel in the dark to full brightness in daylight.
+var DefaultLuxCurve = LuxCurve{{0, 5}, {10, 20}, {100, 40}, {1000, 80}, {10000, 100}}
+
+// ParseLuxCurve parses a curve like 0:5,100:40,1000:80, made of lux:percent points with increasing lux.
+func ParseLuxCurve(s string) (LuxCurve, error) {
+	var curve LuxCurve
+	for _, p := range strings.Split(s, ",") {
+		fields := strings.Split(strings.TrimSpace(p), ":")
+		if len(fields) != 2 {
+			return nil, ErrLuxCurve
+		}
+		lux, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil || lux < 0 || (len(curve) > 0 && lux <= curve[len(curve)-1].Lux) {
+			return nil, ErrLuxCurve
+		}
+		percent, err := strconv.ParseFloat(strings.TrimSuffix(fields[1], "%"), 64)
+		if err != nil || percent < 0 || percent > 100 {
+			return nil, ErrLuxCurve
+		}
+		curve = append(curve, LuxPoint{lux, percent})
+	}
+	return curve, nil
+}
+
+// Percent returns the brightness percentage of the illuminance.
+func (c LuxCurve) Percent(lux float64) float64 {
+	if len(c) == 0 {
+		return 100
+	}
+	if lux <= c[0].Lux {
+		return c[0].Percent
+	}
+	for i := 1; i < len(c); i++ {
+		if lux <= c[i].Lux {
+			from, to := math.Log1p(c[i-1].Lux), math.Log1p(c[i].Lux)
+			t := (math.Log1p(lux) - from) / (to - from)
+			return c[i-1].Percent + t*(c[i].Percent-c[i-1].Percent)
+		}
+	}
+	return c[len(c)-1].Percent
+}
+
+// Smoother smooths the samples of a sensor : it takes the median of the last Window samples, dropping the spikes,
+// then an exponential moving average with the Alpha factor, between 0 and 1, a lower factor smoothing more.
+// The Hysteresis is the relative change of the average needed to report a new value, like 0.1 for 10%,
+// so the brightness doesn't flicker when the light stays around a value.
+type Smoother struct {
+	Window     int
+	Alpha      float64
+	Hysteresis float64
+
+	samples  []float64
+	average  float64
+	reported float64
+	started  bool
+}
+
+// Add adds a sample, and returns the smoothed value with true when it moved past the hysteresis of the last reported one.
+// The first sample is always reported.
+func (s *Smoother) Add(sample float64) (float64, bool) {
+	window := s.Window
+	if window < 1 {
+		window = 1
+	}
+	s.samples = append(s.samples, sample)
+	if len(s.samples) > window {
+		s.samples = s.samples[len(s.samples)-window:]
+	}
+	sorted := append([]float64(nil), s.samples...)
+	sort.Float64s(sorted)
+	median := sorted[len(sorted)/2]
+	if len(sorted)%2 == 0 {
+		median = (sorted[len(sorted)/2-1] + median) / 2
+	}
+
+	if !s.started {
+		s.average, s.reported, s.started = median, median, true
+		return median, true
+	}
+	alpha := s.Alpha
+	if alpha <= 0 || alpha > 1 {
+		alpha = 1
+	}
+	s.average += alpha * (median - s.average)
+	// the dark needs an absolute threshold too, 1 lux, or every photon would count
+	if math.Abs(s.average-s.reported) <= math.Max(s.Hysteresis*s.reported, 1) {
+		return s.reported, false
+	}
+	s.reported = s.average
+	return s.reported, true
+}
+
+// Ambient sets the brightness of a device from the illuminance of an ambient light sensor.
+// The sensor is sampled every Interval, the samples are smoothed by the Smoother, and mapped to a percentage by the Curve.
+type Ambient struct {
+	Sensor   *Sensor
+	Curve    LuxCurve
+	Smoother Smoother
+	Interval time.Duration
+}
+
+// Run samples the sensor until ctx is done, and sets the brightness of the device when the smoothed illuminance changes,
+// through ApplyContext, so the Limits, the Floor and the Fade of the device apply.
+// It returns the context error when ctx is done, or the error of a failed read or write.
+func (a *Ambient) Run(ctx context.Context, d *Device) error {
+	interval := a.Interval
+	if interval <= 0 {
+		interval = DefaultAmbientInterval
+	}
+	curve := a.Curve
+	if curve == nil {
+		curve = DefaultLuxCurve
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		sample, err := a.Sensor.Lux()
+		if err != nil {
+			return err
+		}
+		if lux, ok := a.Smoother.Add(sample); ok {
+			if err := d.ApplyContext(ctx, Value{Unit: Percent, Amount: curve.Percent(lux)}); err != nil {
+				return err
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
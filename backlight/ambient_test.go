@@ -0,0 +1,111 @@
+package backlight
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *BacklightSuite) TestParseLuxCurveOk(c *C) {
+	curve, err := ParseLuxCurve("0:5, 100:40%,1000:80")
+
+	c.Assert(err, IsNil)
+	c.Assert(curve, DeepEquals, LuxCurve{{0, 5}, {100, 40}, {1000, 80}})
+}
+
+func (s *BacklightSuite) TestParseLuxCurveKo(c *C) {
+	for _, value := range []string{"", "0:5,", "0-5", "100:40,10:50", "0:5,0:10", "-1:5", "0:150", "dark:5"} {
+		_, err := ParseLuxCurve(value)
+		c.Assert(err, Equals, ErrLuxCurve, Commentf("curve %q", value))
+	}
+}
+
+func (s *BacklightSuite) TestLuxCurvePercentOk(c *C) {
+	curve := LuxCurve{{0, 10}, {99, 50}, {9999, 90}}
+
+	c.Assert(curve.Percent(0), Equals, float64(10))
+	c.Assert(curve.Percent(99), Equals, float64(50))
+	c.Assert(curve.Percent(20000), Equals, float64(90))
+	// the log scale puts the middle of 99 and 9999 lux at 999 lux
+	c.Assert(curve.Percent(999), Equals, float64(70))
+	c.Assert(LuxCurve{}.Percent(50), Equals, float64(100))
+}
+
+func (s *BacklightSuite) TestSmootherMedianOk(c *C) {
+	sm := Smoother{Window: 3, Alpha: 1}
+
+	v, ok := sm.Add(100)
+	c.Assert(v, Equals, float64(100))
+	c.Assert(ok, Equals, true)
+
+	// a spike of a single sample is dropped by the median
+	_, ok = sm.Add(5000)
+	c.Assert(ok, Equals, true)
+	v, _ = sm.Add(100)
+	c.Assert(v, Equals, float64(100))
+}
+
+func (s *BacklightSuite) TestSmootherHysteresisOk(c *C) {
+	sm := Smoother{Window: 1, Alpha: 1, Hysteresis: 0.1}
+	sm.Add(100)
+
+	// around the threshold, the reported value doesn't move
+	for _, sample := range []float64{105, 95, 109, 91} {
+		v, ok := sm.Add(sample)
+		c.Assert(ok, Equals, false, Commentf("sample %v", sample))
+		c.Assert(v, Equals, float64(100))
+	}
+	v, ok := sm.Add(120)
+	c.Assert(ok, Equals, true)
+	c.Assert(v, Equals, float64(120))
+
+	// in the dark, a lux is the least change
+	sm = Smoother{Window: 1, Alpha: 1, Hysteresis: 0.1}
+	sm.Add(0)
+	_, ok = sm.Add(0.5)
+	c.Assert(ok, Equals, false)
+}
+
+func (s *BacklightSuite) TestSmootherAverageOk(c *C) {
+	sm := Smoother{Window: 1, Alpha: 0.5}
+	sm.Add(0)
+
+	v, ok := sm.Add(100)
+	c.Assert(ok, Equals, true)
+	c.Assert(v, Equals, float64(50))
+	v, _ = sm.Add(100)
+	c.Assert(v, Equals, float64(75))
+}
+
+func (s *BacklightSuite) TestAmbientRunOk(c *C) {
+	root := c.MkDir()
+	path := makeSensor(c, root, "iio:device0", map[string]string{"in_illuminance_input": "000"})
+	sensor, err := OpenSensor(root, Auto)
+	if err != nil {
+		c.Fatal(err)
+	}
+	d, err := Open(s.root, "intel_backlight")
+	if err != nil {
+		c.Fatal(err)
+	}
+	file := filepath.Join(s.path, "brightness")
+
+	a := &Ambient{
+		Sensor:   sensor,
+		Curve:    LuxCurve{{0, 10}, {99, 50}, {9999, 90}},
+		Smoother: Smoother{Window: 1, Alpha: 1, Hysteresis: 0.1},
+		Interval: 5 * time.Millisecond,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- a.Run(ctx, d) }()
+
+	c.Assert(waitBrightness(c, file, 500), Equals, int64(100))
+	overwrite(c, filepath.Join(path, "in_illuminance_input"), "999")
+	c.Assert(waitBrightness(c, file, 100), Equals, int64(700))
+
+	cancel()
+	c.Assert(<-done, Equals, context.Canceled)
+}
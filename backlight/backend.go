@@ -0,0 +1,293 @@
+package backlight
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrClosed is returned by the backends once they are closed.
+var ErrClosed = errors.New("Error the backend is closed")
+
+// Backend is where a Device reads and writes its brightness, in raw values : the driver files of a sysfs class,
+// or the memory of a Fake. The percentages, the limits, the fades and the watches of the Device are the same for all of them.
+type Backend interface {
+	// Read returns the brightness last written, the actual brightness and the maximum brightness.
+	Read() (Reading, error)
+	// Write sets the brightness to a raw value, between 0 and the maximum brightness.
+	Write(raw int64) error
+	// Describe returns the optional attributes of the device, which don't change the brightness math.
+	Describe() Description
+	// Close releases the resources held by the backend.
+	Close() error
+}
+
+// ColorBackend is a Backend of a multicolor LED, with the intensities of its channels.
+type ColorBackend interface {
+	Backend
+	// ReadColor returns the names of the channels and their intensities, both empty when the device has no channels.
+	ReadColor() ([]string, []int64, error)
+	// WriteColor sets the intensities of the channels, in the order of ReadColor.
+	WriteColor(intensities []int64) error
+}
+
+// Reading is the brightness of a device read by a Backend.
+// Actual is the brightness the device shows, it may differ from Brightness while the firmware moves it.
+type Reading struct {
+	Brightness int64
+	Actual     int64
+	Max        int64
+}
+
+// Description holds the optional attributes of a device read by a Backend.
+// BlPower and HwChanged are nil when the device doesn't have them, and Files are the files to watch for changes.
+type Description struct {
+	Type      string
+	Scale     string
+	BlPower   *int64
+	HwChanged *int64
+	Writable  bool
+	Files     []string
+}
+
+// NewDevice returns a device called name reading and writing its brightness through the backend, with its values loaded.
+func NewDevice(name string, backend Backend) (*Device, error) {
+	d := &Device{Name: name, Backend: backend}
+	if err := d.Load(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// SysfsBacklight is the Backend of a device of the backlight class, in its folder Path.
+type SysfsBacklight struct {
+	Path string
+}
+
+// Read reads the brightness, actual_brightness and max_brightness files.
+// It returns ErrDriverFiles when one of them is missing.
+func (b SysfsBacklight) Read() (Reading, error) {
+	return readDriver(b.Path, driverFiles[:])
+}
+
+// Write writes the brightness file.
+func (b SysfsBacklight) Write(raw int64) error {
+	return writeStringToFile(filepath.Join(b.Path, "brightness"), strconv.FormatInt(raw, 10))
+}
+
+// Describe reads the type, scale, bl_power and brightness_hw_changed files, when they exist.
+func (b SysfsBacklight) Describe() Description {
+	return describe(b.Path, "actual_brightness")
+}
+
+// Close does nothing, the files are opened on each read and write.
+func (b SysfsBacklight) Close() error {
+	return nil
+}
+
+// SysfsLED is the Backend of a device of the leds class, in its folder Path.
+// A LED has no actual_brightness file, its actual brightness is its brightness,
+// and a multicolor LED has the multi_index and multi_intensity files of its channels.
+type SysfsLED struct {
+	Path string
+}
+
+// Read reads the brightness and max_brightness files.
+// It returns ErrDriverFiles when one of them is missing.
+func (b SysfsLED) Read() (Reading, error) {
+	return readDriver(b.Path, ledFiles[:])
+}
+
+// Write writes the brightness file.
+func (b SysfsLED) Write(raw int64) error {
+	return writeStringToFile(filepath.Join(b.Path, "brightness"), strconv.FormatInt(raw, 10))
+}
+
+// Describe reads the optional attributes of the LED, like brightness_hw_changed, when they exist.
+func (b SysfsLED) Describe() Description {
+	return describe(b.Path, "brightness")
+}
+
+// Close does nothing, the files are opened on each read and write.
+func (b SysfsLED) Close() error {
+	return nil
+}
+
+// ReadColor reads the multi_index and multi_intensity files, the channels are empty when the LED has no multi_index file.
+// It returns an error when the files don't have as many values.
+func (b SysfsLED) ReadColor() ([]string, []int64, error) {
+	index := readAttribute(b.Path, "multi_index")
+	if index == "" {
+		return nil, nil, nil
+	}
+	channels, values := strings.Fields(index), strings.Fields(readAttribute(b.Path, "multi_intensity"))
+	if len(values) != len(channels) {
+		return nil, nil, fmt.Errorf("Error %s has %d intensities for %d channels", filepath.Base(b.Path), len(values), len(channels))
+	}
+	intensities := make([]int64, len(values))
+	for i, s := range values {
+		v, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, nil, err
+		}
+		intensities[i] = v
+	}
+	return channels, intensities, nil
+}
+
+// WriteColor writes the multi_intensity file.
+func (b SysfsLED) WriteColor(intensities []int64) error {
+	values := make([]string, len(intensities))
+	for i, v := range intensities {
+		values[i] = strconv.FormatInt(v, 10)
+	}
+	return writeStringToFile(filepath.Join(b.Path, "multi_intensity"), strings.Join(values, " "))
+}
+
+// readDriver reads the driver files of a sysfs device, the actual brightness being the brightness without actual_brightness.
+func readDriver(path string, files []string) (Reading, error) {
+	if _, err := checkFiles(path, files); err != nil {
+		return Reading{}, err
+	}
+	r := Reading{Actual: -1}
+	for _, file := range files {
+		v, err := readInt(filepath.Join(path, file))
+		if err != nil {
+			return Reading{}, err
+		}
+		switch file {
+		case "brightness":
+			r.Brightness = v
+		case "actual_brightness":
+			r.Actual = v
+		case "max_brightness":
+			r.Max = v
+		}
+	}
+	if r.Actual < 0 {
+		r.Actual = r.Brightness
+	}
+	return r, nil
+}
+
+// hwChangedFile is the file of the brightness last set by the firmware, notified by the kernel on each change.
+const hwChangedFile = "brightness_hw_changed"
+
+// describe reads the optional attributes of a sysfs device, watching the file of its actual brightness.
+func describe(path string, actual string) Description {
+	desc := Description{
+		Type:     readAttribute(path, "type"),
+		Scale:    readAttribute(path, "scale"),
+		Writable: canWrite(filepath.Join(path, "brightness")),
+		Files:    []string{filepath.Join(path, actual)},
+	}
+	if actual != "brightness" {
+		desc.Files = append(desc.Files, filepath.Join(path, "brightness"))
+	}
+	if v, err := readInt(filepath.Join(path, "bl_power")); err == nil {
+		desc.BlPower = &v
+	}
+	// brightness_hw_changed can't be read until the firmware changes the brightness, but it is notified anyway
+	if _, err := os.Stat(filepath.Join(path, hwChangedFile)); err == nil {
+		desc.Files = append(desc.Files, filepath.Join(path, hwChangedFile))
+	}
+	if v, err := readInt(filepath.Join(path, hwChangedFile)); err == nil {
+		desc.HwChanged = &v
+	}
+	return desc
+}
+
+// Fake is an in-memory Backend, for the tests of the programs using the package.
+// Its actual brightness follows the writes, and Err, when set, is returned by the reads and writes.
+// Channels and Intensities make it a multicolor LED.
+type Fake struct {
+	Brightness  int64
+	Max         int64
+	Description Description
+	Channels    []string
+	Intensities []int64
+	Err         error
+
+	mu     sync.Mutex
+	writes []int64
+	closed bool
+}
+
+// Read returns the brightness of the fake.
+func (f *Fake) Read() (Reading, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.check(); err != nil {
+		return Reading{}, err
+	}
+	return Reading{Brightness: f.Brightness, Actual: f.Brightness, Max: f.Max}, nil
+}
+
+// Write sets the brightness of the fake, and records the value for Writes.
+func (f *Fake) Write(raw int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.check(); err != nil {
+		return err
+	}
+	if raw < 0 || raw > f.Max {
+		return ErrRange
+	}
+	f.Brightness = raw
+	f.writes = append(f.writes, raw)
+	return nil
+}
+
+// Describe returns the Description of the fake.
+func (f *Fake) Describe() Description {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.Description
+}
+
+// Close closes the fake, its reads and writes return ErrClosed afterwards.
+func (f *Fake) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	return nil
+}
+
+// ReadColor returns the channels of the fake.
+func (f *Fake) ReadColor() ([]string, []int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.check(); err != nil {
+		return nil, nil, err
+	}
+	return append([]string(nil), f.Channels...), append([]int64(nil), f.Intensities...), nil
+}
+
+// WriteColor sets the intensities of the channels of the fake.
+func (f *Fake) WriteColor(intensities []int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.check(); err != nil {
+		return err
+	}
+	f.Intensities = append([]int64(nil), intensities...)
+	return nil
+}
+
+// Writes returns the raw values written to the fake, in order.
+func (f *Fake) Writes() []int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]int64(nil), f.writes...)
+}
+
+func (f *Fake) check() error {
+	if f.closed {
+		return ErrClosed
+	}
+	return f.Err
+}
@@ -0,0 +1,142 @@
+package backlight
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+// probeBackend is a Backend counting its reads, which wait for release when it is not nil, like a slow monitor.
+type probeBackend struct {
+	Backend
+	release chan struct{}
+
+	mu    sync.Mutex
+	reads int
+}
+
+func (b *probeBackend) Read() (Reading, error) {
+	b.mu.Lock()
+	b.reads++
+	b.mu.Unlock()
+	r, err := b.Backend.Read()
+	if b.release != nil {
+		<-b.release
+	}
+	return r, err
+}
+
+func (b *probeBackend) Reads() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.reads
+}
+
+func (s *BacklightSuite) TestSysfsBackendOk(c *C) {
+	b := SysfsBacklight{Path: s.path}
+	r, err := b.Read()
+	c.Assert(err, IsNil)
+	c.Assert(r.Max, Equals, int64(1000))
+
+	c.Assert(b.Write(250), IsNil)
+	r, err = b.Read()
+	c.Assert(err, IsNil)
+	c.Assert(r.Brightness, Equals, int64(250))
+	c.Assert(b.Describe().Files, HasLen, 2)
+	c.Assert(b.Close(), IsNil)
+
+	root := c.MkDir()
+	path := makeLED(c, root, "tpacpi::kbd_backlight")
+	led := SysfsLED{Path: path}
+	r, err = led.Read()
+	c.Assert(err, IsNil)
+	c.Assert(r, Equals, Reading{Brightness: 1, Actual: 1, Max: 2})
+	channels, _, err := led.ReadColor()
+	c.Assert(err, IsNil)
+	c.Assert(channels, HasLen, 0)
+}
+
+func (s *BacklightSuite) TestSysfsBackendKo(c *C) {
+	_, err := SysfsBacklight{Path: s.root}.Read()
+	c.Assert(err, Equals, ErrDriverFiles)
+
+	_, err = SysfsLED{Path: c.MkDir()}.Read()
+	c.Assert(err, Equals, ErrDriverFiles)
+}
+
+func (s *BacklightSuite) TestFakeBackendOk(c *C) {
+	fake := &Fake{Brightness: 50, Max: 100}
+	d, err := NewDevice("fake", fake)
+	c.Assert(err, IsNil)
+	c.Assert(d.Get(), Equals, 50)
+	c.Assert(d.Writable(), Equals, false)
+
+	c.Assert(d.Set(20), IsNil)
+	c.Assert(d.Inc(30), IsNil)
+	c.Assert(d.Dec(10), IsNil)
+	// the relative values start from the last write
+	c.Assert(fake.Writes(), DeepEquals, []int64{20, 50, 40})
+	c.Assert(d.Load(), IsNil)
+	c.Assert(d.Get(), Equals, 40)
+
+	// the fades and the limits are the ones of the device
+	d.Max = 60
+	d.Fade = Fade{Duration: 20 * time.Millisecond, Interval: 5 * time.Millisecond}
+	c.Assert(d.ApplyContext(context.Background(), Value{Unit: Percent, Amount: 100}), IsNil)
+	c.Assert(d.Clamped, Equals, true)
+	writes := fake.Writes()
+	c.Assert(len(writes) > 4, Equals, true)
+	c.Assert(writes[len(writes)-1], Equals, int64(60))
+
+	c.Assert(d.Close(), IsNil)
+	c.Assert(d.Load(), Equals, ErrClosed)
+}
+
+func (s *BacklightSuite) TestFakeBackendKo(c *C) {
+	fake := &Fake{Brightness: 50, Max: 100, Err: errors.New("Error no device")}
+	_, err := NewDevice("fake", fake)
+	c.Assert(err, ErrorMatches, "Error no device")
+
+	fake.Err = nil
+	d, err := NewDevice("fake", fake)
+	c.Assert(err, IsNil)
+	fake.Err = errors.New("Error write")
+	c.Assert(d.Set(20), ErrorMatches, "Error write")
+	c.Assert(d.Brightness, Equals, int64(50))
+
+	fake.Err = nil
+	c.Assert(fake.Write(101), Equals, ErrRange)
+}
+
+func (s *BacklightSuite) TestFakeColorOk(c *C) {
+	fake := &Fake{Brightness: 255, Max: 255, Channels: []string{"red", "green", "blue"}, Intensities: []int64{255, 255, 255}}
+	d, err := NewDevice("fake", fake)
+	c.Assert(err, IsNil)
+
+	intensities, err := d.ParseColor("ff8800")
+	c.Assert(err, IsNil)
+	c.Assert(d.SetColor(intensities), IsNil)
+	c.Assert(fake.Intensities, DeepEquals, []int64{255, 136, 0})
+}
+
+func (s *BacklightSuite) TestLoadSlowBackendOk(c *C) {
+	probe := &probeBackend{Backend: &Fake{Brightness: 50, Max: 100}}
+	d, err := NewDevice("fake", probe)
+	c.Assert(err, IsNil)
+
+	probe.release = make(chan struct{})
+	done := make(chan error)
+	go func() { done <- d.Load() }()
+	for probe.Reads() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	// the write doesn't wait for the read
+	c.Assert(d.Set(80), IsNil)
+	close(probe.release)
+	c.Assert(<-done, IsNil)
+	// and the brightness read before the write is dropped
+	c.Assert(d.Get(), Equals, 80)
+}
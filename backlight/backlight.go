@@ -0,0 +1,236 @@
+// Package backlight reads and writes the brightness of the backlight devices
+// exposed by the kernel in sysfs.
+//
+// Every function takes the sysfs root explicitly, DefaultRoot on a real system,
+// so the package can be used against a fake tree in tests.
+package backlight
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+)
+
+const (
+	// DefaultRoot is the sysfs folder of the kernel backlight class.
+	DefaultRoot = "/sys/class/backlight/"
+
+	// Auto is the device name asking Open to pick the preferred device of the root.
+	Auto = "auto"
+)
+
+var (
+	// ErrDriverFiles is returned when a device folder misses one of the driver files.
+	ErrDriverFiles = errors.New("Error driver files not found in device path")
+	// ErrNoDevice is returned when no device could be found in the root.
+	ErrNoDevice = errors.New("Error no backlight device found")
+
+	driverFiles = [3]string{"brightness", "actual_brightness", "max_brightness"}
+)
+
+// Device is a backlight device, holding the values read from its driver files.
+// Backend reads and writes the brightness, it is the sysfs backend of the Class in Path when nil.
+// Class is the class of the device, ClassBacklight when empty.
+// BlPower is nil when the device has no bl_power file, and Scale is empty when it has no scale file.
+// HwChanged is the last brightness set by the firmware, like with the hotkeys, read from brightness_hw_changed :
+// it is nil when the driver doesn't expose the file, or when the firmware didn't change the brightness yet.
+// Channels are the color channels of a multicolor LED, read from multi_index, and Intensities their intensities,
+// read from multi_intensity : both are empty for the other devices.
+// Curve converts the percentages of Get, Set, Inc and Dec to raw values, it is picked from Scale when nil.
+// Min and Max bound the raw values written by Set, Inc and Dec, see Limits, and Cap lowers the upper bound
+// for the power source, see Profiles.
+// Floor is the lowest raw value of the device which still lights the panel, and AllowOff lets it go to 0.
+// Clamped reports whether the last Set, Inc or Dec had its target clamped to those limits.
+// Fade configures the transitions of Set, Inc and Dec, they are instant by default.
+//
+// The methods of a Device are safe for concurrent use, and a write retargets the transition in progress.
+// The fields changed by the methods should only be read when no write is in progress, or through Info and LastWrite.
+type Device struct {
+	Name             string
+	Path             string
+	Class            Class
+	Backend          Backend
+	Type             string
+	Scale            string
+	Brightness       int64
+	ActualBrightness int64
+	MaxBrightness    int64
+	BlPower          *int64
+	HwChanged        *int64
+	Channels         []string
+	Intensities      []int64
+	Curve            Curve
+	Min              int64
+	Max              int64
+	Cap              int64
+	Floor            int64
+	AllowOff         bool
+	Clamped          bool
+	Fade             Fade
+
+	mu         sync.Mutex
+	transition *transition
+	writes     int
+	writable   bool
+}
+
+// Open returns the backlight device called name in the sysfs root, with its values loaded.
+// When name is Auto, it uses Discover to pick the preferred device of the root.
+// It returns an error if the device folder doesn't exist or doesn't contain the driver files.
+func Open(root string, name string) (*Device, error) {
+	return ClassBacklight.Open(root, name)
+}
+
+// Load reads the brightness and the attributes of the device through its Backend, and fills the Device fields.
+// The sysfs backends expect that the device folder contains at least 3 files : brightness, actual_brightness, max_brightness,
+// but LEDs have no actual_brightness, and their ActualBrightness is their brightness.
+// The Backend is read without holding the device, so the writes don't wait for a slow one, like a monitor over DDC/CI,
+// and the brightness read is dropped when a write happened meanwhile.
+// It returns an error when the driver files could not be read, or converted to integers.
+func (d *Device) Load() error {
+	d.mu.Lock()
+	b, writes := d.backend(), d.writes
+	d.mu.Unlock()
+
+	r, err := b.Read()
+	if err != nil {
+		return err
+	}
+	desc := b.Describe()
+	channels, intensities, err := readColor(b)
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.writes == writes {
+		d.Brightness, d.ActualBrightness = r.Brightness, r.Actual
+	}
+	d.MaxBrightness = r.Max
+	d.Type, d.Scale, d.BlPower, d.HwChanged = desc.Type, desc.Scale, desc.BlPower, desc.HwChanged
+	d.Channels, d.Intensities = channels, intensities
+	d.writable = desc.Writable
+	return nil
+}
+
+// Writable reports whether the current user can write the brightness of the device, asking the Backend again,
+// and logind too for a Logind backend, when Load reuses what it last answered.
+func (d *Device) Writable() bool {
+	b := d.backend()
+	if l, ok := b.(*Logind); ok {
+		l.Active()
+	}
+	writable := b.Describe().Writable
+	d.mu.Lock()
+	d.writable = writable
+	d.mu.Unlock()
+	return writable
+}
+
+// Close closes the Backend of the device.
+func (d *Device) Close() error {
+	return d.backend().Close()
+}
+
+// Get returns the current brightness expressed as percentage, rounded to the nearest integer.
+// It uses the Curve to convert ActualBrightness, and returns 0 when MaxBrightness is unknown.
+func (d *Device) Get() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return int(math.Round(d.percent(d.ActualBrightness)))
+}
+
+// Percent returns the percentage of a raw brightness value, through the Curve.
+func (d *Device) Percent(raw int64) float64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.percent(raw)
+}
+
+func (d *Device) percent(raw int64) float64 {
+	return d.curve().Percent(raw, d.MaxBrightness)
+}
+
+// Raw returns the raw brightness value of a percentage, through the Curve.
+func (d *Device) Raw(percent float64) int64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.raw(percent)
+}
+
+func (d *Device) raw(percent float64) int64 {
+	return d.curve().Raw(percent, d.MaxBrightness)
+}
+
+// backend returns the Backend of the device, the sysfs backend of its class when it has none.
+func (d *Device) backend() Backend {
+	if d.Backend == nil {
+		return d.class().backend(d.Path)
+	}
+	return d.Backend
+}
+
+// class returns the class of the device, a zero Class being ClassBacklight.
+func (d *Device) class() Class {
+	if d.Class == "" {
+		return ClassBacklight
+	}
+	return d.Class
+}
+
+func (d *Device) curve() Curve {
+	if d.Curve == nil {
+		return ScaleCurve(d.Scale, d.MaxBrightness)
+	}
+	return d.Curve
+}
+
+// Inc will increment the current brightness with the given percentage.
+// The percentage is added to the current one, so the steps follow the Curve.
+// It returns an error if it could not write the new value to the brightness file.
+func (d *Device) Inc(percent int) error {
+	return d.IncContext(context.Background(), percent)
+}
+
+// IncContext is like Inc, and stops the transition when ctx is done.
+func (d *Device) IncContext(ctx context.Context, percent int) error {
+	return d.ApplyContext(ctx, Value{Unit: Percent, Relative: true, Amount: float64(percent)})
+}
+
+// Dec will decrement the current brightness with the given percentage.
+// The percentage is subtracted from the current one, so the steps follow the Curve.
+// It returns an error if it could not write the new value to the brightness file.
+func (d *Device) Dec(percent int) error {
+	return d.DecContext(context.Background(), percent)
+}
+
+// DecContext is like Dec, and stops the transition when ctx is done.
+func (d *Device) DecContext(ctx context.Context, percent int) error {
+	return d.ApplyContext(ctx, Value{Unit: Percent, Relative: true, Amount: -float64(percent)})
+}
+
+// Set will set the current brightness to the given percentage.
+// It uses the Curve to convert the percentage to a raw brightness value.
+// It returns ErrRange if the percentage is not between 0 and 100, or an error if it could not write the brightness file.
+func (d *Device) Set(percent int) error {
+	return d.SetContext(context.Background(), percent)
+}
+
+// SetContext is like Set, and stops the transition when ctx is done.
+func (d *Device) SetContext(ctx context.Context, percent int) error {
+	return d.ApplyContext(ctx, Value{Unit: Percent, Amount: float64(percent)})
+}
+
+// writeValue writes a raw value through the backend.
+// The value becomes the actual brightness too, so the next relative values and Get start from it
+// without reading the device again.
+func (d *Device) writeValue(value int64) error {
+	if err := d.backend().Write(value); err != nil {
+		return err
+	}
+	d.Brightness, d.ActualBrightness = value, value
+	d.writes++
+	return nil
+}
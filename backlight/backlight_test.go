@@ -0,0 +1,457 @@
+package backlight
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type BacklightSuite struct {
+	root  string
+	path  string
+	files [3]string
+}
+
+var _ = Suite(&BacklightSuite{})
+
+var nofileMsg = "open .*: no such file or directory"
+
+func makeDevice(c *C, root string, name string, kind string) string {
+	path := filepath.Join(root, name)
+	if err := os.Mkdir(path, 0755); err != nil {
+		c.Fatal(err)
+	}
+	values := map[string]string{"brightness": "500", "actual_brightness": "500", "max_brightness": "1000"}
+	if kind != "" {
+		values["type"] = kind
+	}
+	for file, value := range values {
+		if err := ioutil.WriteFile(filepath.Join(path, file), []byte(value+"\n"), 0644); err != nil {
+			c.Fatal(err)
+		}
+	}
+	return path
+}
+
+func readValue(c *C, file string) int64 {
+	v, err := readInt(file)
+	if err != nil {
+		c.Fatal(err)
+	}
+	return v
+}
+
+// syncActual copies brightness to actual_brightness like the kernel does, and reloads the device.
+func (s *BacklightSuite) syncActual(c *C, d *Device) error {
+	v, err := readFile(filepath.Join(d.Path, "brightness"))
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(d.Path, "actual_brightness"), []byte(v), 0644); err != nil {
+		return err
+	}
+	return d.Load()
+}
+
+func (s *BacklightSuite) SetUpTest(c *C) {
+	s.files = driverFiles
+	s.root = c.MkDir()
+	s.path = makeDevice(c, s.root, "intel_backlight", "raw")
+}
+
+func (s *BacklightSuite) TearDownTest(c *C) {
+	os.Chmod(s.root, 0755)
+	os.Chmod(s.path, 0755)
+}
+
+func (s *BacklightSuite) TestCheckDeviceFilePathOk(c *C) {
+	files, err := checkDevice(s.path)
+
+	c.Assert(err, IsNil)
+	c.Assert(files, HasLen, 3)
+}
+
+func (s *BacklightSuite) TestCheckDeviceFilePathKo(c *C) {
+	files, err := checkDevice(s.root)
+
+	c.Assert(err, Equals, ErrDriverFiles)
+	c.Assert(files, HasLen, 0)
+}
+
+func (s *BacklightSuite) TestCheckDeviceFilePathPermissionKo(c *C) {
+	if err := os.Chmod(s.path, 0300); err != nil {
+		c.Fatal(err)
+	}
+
+	files, err := checkDevice(s.path)
+
+	c.Assert(err, Not(IsNil))
+	c.Assert(files, HasLen, 0)
+	c.Assert(err, ErrorMatches, "open .*: permission denied")
+}
+
+func (s *BacklightSuite) TestReadFileOk(c *C) {
+	for _, f := range s.files {
+		value, err := readFile(filepath.Join(s.path, f))
+		c.Assert(err, IsNil)
+		c.Assert(value, Not(HasLen), 0)
+	}
+}
+
+func (s *BacklightSuite) TestReadFilePermissionKo(c *C) {
+	for _, f := range s.files {
+		if err := os.Chmod(filepath.Join(s.path, f), 0300); err != nil {
+			c.Fatal(err)
+		}
+
+		value, err := readFile(filepath.Join(s.path, f))
+		c.Assert(err, Not(IsNil))
+		c.Assert(value, Equals, "")
+		c.Assert(err, ErrorMatches, "open .*: permission denied")
+	}
+}
+
+func (s *BacklightSuite) TestWriteFileToStringOk(c *C) {
+	err := writeStringToFile(filepath.Join(s.path, "brightness"), strconv.Itoa(250))
+	c.Assert(err, IsNil)
+	c.Assert(readValue(c, filepath.Join(s.path, "brightness")), Equals, int64(250))
+}
+
+func (s *BacklightSuite) TestWriteFileToStringFileKo(c *C) {
+	if err := os.Remove(filepath.Join(s.path, "brightness")); err != nil {
+		c.Fatal(err)
+	}
+	err := writeStringToFile(filepath.Join(s.path, "brightness"), strconv.Itoa(500))
+	c.Assert(err, ErrorMatches, nofileMsg)
+}
+
+func (s *BacklightSuite) TestOpenOk(c *C) {
+	d, err := Open(s.root, "intel_backlight")
+
+	c.Assert(err, IsNil)
+	c.Assert(d.Name, Equals, "intel_backlight")
+	c.Assert(d.Path, Equals, s.path)
+	c.Assert(d.Type, Equals, "raw")
+	c.Assert(d.Brightness, Equals, int64(500))
+	c.Assert(d.ActualBrightness, Equals, int64(500))
+	c.Assert(d.MaxBrightness, Equals, int64(1000))
+	c.Assert(d.BlPower, IsNil)
+	c.Assert(d.HwChanged, IsNil)
+}
+
+func (s *BacklightSuite) TestOpenAutoOk(c *C) {
+	makeDevice(c, s.root, "acpi_video0", "firmware")
+
+	d, err := Open(s.root, Auto)
+
+	c.Assert(err, IsNil)
+	c.Assert(d.Name, Equals, "acpi_video0")
+	c.Assert(d.Path, Equals, filepath.Join(s.root, "acpi_video0"))
+}
+
+func (s *BacklightSuite) TestOpenDeviceDirKo(c *C) {
+	d, err := Open(s.root, "acpi_video0")
+
+	c.Assert(err, ErrorMatches, "stat .* no such file or directory")
+	c.Assert(d, IsNil)
+}
+
+func (s *BacklightSuite) TestOpenDevicePermissionKo(c *C) {
+	if err := os.Chmod(s.path, 0300); err != nil {
+		c.Fatal(err)
+	}
+
+	d, err := Open(s.root, "intel_backlight")
+
+	c.Assert(err, ErrorMatches, "open .*: permission denied")
+	c.Assert(d, IsNil)
+}
+
+func (s *BacklightSuite) TestOpenFilePermissionKo(c *C) {
+	for _, f := range s.files {
+		if err := os.Chmod(filepath.Join(s.path, f), 0300); err != nil {
+			c.Fatal(err)
+		}
+	}
+
+	d, err := Open(s.root, "intel_backlight")
+
+	c.Assert(err, ErrorMatches, "open .*: permission denied")
+	c.Assert(d, IsNil)
+}
+
+func (s *BacklightSuite) TestLoadBlPowerOk(c *C) {
+	if err := ioutil.WriteFile(filepath.Join(s.path, "bl_power"), []byte("4\n"), 0644); err != nil {
+		c.Fatal(err)
+	}
+	d := Device{Path: s.path}
+	err := d.Load()
+
+	c.Assert(err, IsNil)
+	c.Assert(*d.BlPower, Equals, int64(4))
+}
+
+func (s *BacklightSuite) TestLoadHwChangedOk(c *C) {
+	if err := ioutil.WriteFile(filepath.Join(s.path, "brightness_hw_changed"), []byte("300\n"), 0644); err != nil {
+		c.Fatal(err)
+	}
+	d := Device{Path: s.path}
+	err := d.Load()
+
+	c.Assert(err, IsNil)
+	c.Assert(*d.HwChanged, Equals, int64(300))
+	c.Assert(*d.Info().HwChanged, Equals, int64(300))
+}
+
+func (s *BacklightSuite) TestLoadFilePathKo(c *C) {
+	d := Device{Path: s.root}
+	err := d.Load()
+
+	c.Assert(err, Equals, ErrDriverFiles)
+	c.Assert(d.Brightness, Equals, int64(0))
+	c.Assert(d.MaxBrightness, Equals, int64(0))
+	c.Assert(d.ActualBrightness, Equals, int64(0))
+}
+
+func (s *BacklightSuite) TestLoadFileContentKo(c *C) {
+	for _, f := range s.files {
+		file := filepath.Join(s.path, f)
+		if err := ioutil.WriteFile(file, []byte("a"), 0644); err != nil {
+			c.Fatal(err)
+		}
+		d := Device{Path: s.path}
+		err := d.Load()
+
+		c.Assert(err, Not(IsNil)) // strconv value type error
+		c.Assert(err, ErrorMatches, "strconv.ParseInt:.*")
+
+		if err := ioutil.WriteFile(file, []byte("500"), 0644); err != nil {
+			c.Fatal(err)
+		}
+	}
+}
+
+func (s *BacklightSuite) TestLoadFileContentEmpty(c *C) {
+	for _, f := range s.files {
+		if err := ioutil.WriteFile(filepath.Join(s.path, f), nil, 0644); err != nil {
+			c.Fatal(err)
+		}
+		d := Device{Path: s.path}
+		err := d.Load()
+
+		c.Assert(err, Not(IsNil))
+		c.Assert(err, ErrorMatches, "strconv.ParseInt:.*")
+	}
+}
+
+func (s *BacklightSuite) TestLoadFileAbsentKo(c *C) {
+	for _, f := range s.files {
+		if err := os.Rename(filepath.Join(s.path, f), filepath.Join(s.path, f+"_test")); err != nil {
+			c.Fatal(err)
+		}
+		d := Device{Path: s.path}
+		err := d.Load()
+
+		c.Assert(err, Equals, ErrDriverFiles)
+
+		if err := os.Rename(filepath.Join(s.path, f+"_test"), filepath.Join(s.path, f)); err != nil {
+			c.Fatal(err)
+		}
+	}
+}
+
+func (s *BacklightSuite) TestGetOk(c *C) {
+	d, err := Open(s.root, "intel_backlight")
+	if err != nil {
+		c.Fatal(err)
+	}
+
+	c.Assert(d.Get(), Equals, 50)
+}
+
+func (s *BacklightSuite) TestGetMaxZeroOk(c *C) {
+	d := Device{ActualBrightness: 500}
+
+	c.Assert(d.Get(), Equals, 0)
+}
+
+func (s *BacklightSuite) TestIncOk(c *C) {
+	d, err := Open(s.root, "intel_backlight")
+	if err != nil {
+		c.Fatal(err)
+	}
+
+	err = d.Inc(5)
+	c.Assert(err, IsNil)
+	c.Assert(d.Brightness, Equals, int64(550))
+	c.Assert(readValue(c, filepath.Join(s.path, "brightness")), Equals, int64(550))
+}
+
+func (s *BacklightSuite) TestIncTwiceOk(c *C) {
+	d, err := Open(s.root, "intel_backlight")
+	if err != nil {
+		c.Fatal(err)
+	}
+
+	// the increments of a long-lived device add up, without reading the device again
+	c.Assert(d.Inc(5), IsNil)
+	c.Assert(d.Inc(5), IsNil)
+	c.Assert(readValue(c, filepath.Join(s.path, "brightness")), Equals, int64(600))
+	c.Assert(d.Get(), Equals, 60)
+
+	d.Fade = Fade{Duration: 10 * time.Millisecond, Interval: 5 * time.Millisecond}
+	c.Assert(d.Inc(5), IsNil)
+	c.Assert(d.Inc(5), IsNil)
+	c.Assert(readValue(c, filepath.Join(s.path, "brightness")), Equals, int64(700))
+	c.Assert(d.Get(), Equals, 70)
+}
+
+func (s *BacklightSuite) TestDecOk(c *C) {
+	d, err := Open(s.root, "intel_backlight")
+	if err != nil {
+		c.Fatal(err)
+	}
+
+	err = d.Dec(5)
+	c.Assert(err, IsNil)
+	c.Assert(d.Brightness, Equals, int64(450))
+	c.Assert(readValue(c, filepath.Join(s.path, "brightness")), Equals, int64(450))
+}
+
+func (s *BacklightSuite) TestSetOk(c *C) {
+	d, err := Open(s.root, "intel_backlight")
+	if err != nil {
+		c.Fatal(err)
+	}
+
+	err = d.Set(25)
+	c.Assert(err, IsNil)
+	c.Assert(d.Brightness, Equals, int64(250))
+	c.Assert(readValue(c, filepath.Join(s.path, "brightness")), Equals, int64(250))
+}
+
+func (s *BacklightSuite) TestIncFileKo(c *C) {
+	d, err := Open(s.root, "intel_backlight")
+	if err != nil {
+		c.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(s.path, "brightness")); err != nil {
+		c.Fatal(err)
+	}
+
+	err = d.Inc(5)
+	c.Assert(err, ErrorMatches, nofileMsg)
+}
+
+func (s *BacklightSuite) TestDecFileKo(c *C) {
+	d, err := Open(s.root, "intel_backlight")
+	if err != nil {
+		c.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(s.path, "brightness")); err != nil {
+		c.Fatal(err)
+	}
+
+	err = d.Dec(5)
+	c.Assert(err, ErrorMatches, nofileMsg)
+}
+
+func (s *BacklightSuite) TestSetFileKo(c *C) {
+	d, err := Open(s.root, "intel_backlight")
+	if err != nil {
+		c.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(s.path, "brightness")); err != nil {
+		c.Fatal(err)
+	}
+
+	err = d.Set(25)
+	c.Assert(err, ErrorMatches, nofileMsg)
+}
+
+func (s *BacklightSuite) TestDiscoverPriorityOk(c *C) {
+	root := c.MkDir()
+	makeDevice(c, root, "amdgpu_bl0", "raw")
+	makeDevice(c, root, "dell_backlight", "platform")
+	makeDevice(c, root, "acpi_video0", "firmware")
+
+	device, err := Discover(root)
+	c.Assert(err, IsNil)
+	c.Assert(device, Equals, "acpi_video0")
+}
+
+func (s *BacklightSuite) TestDiscoverTieOk(c *C) {
+	root := c.MkDir()
+	makeDevice(c, root, "radeon_bl0", "raw")
+	makeDevice(c, root, "amdgpu_bl0", "raw")
+	makeDevice(c, root, "unknown_bl0", "")
+
+	device, err := Discover(root)
+	c.Assert(err, IsNil)
+	c.Assert(device, Equals, "amdgpu_bl0")
+}
+
+func (s *BacklightSuite) TestDiscoverSkipKo(c *C) {
+	if err := os.Mkdir(filepath.Join(s.root, "acpi_video0"), 0755); err != nil {
+		c.Fatal(err)
+	}
+
+	device, err := Discover(s.root)
+	c.Assert(err, IsNil)
+	c.Assert(device, Equals, "intel_backlight")
+}
+
+func (s *BacklightSuite) TestDiscoverEmptyKo(c *C) {
+	device, err := Discover(c.MkDir())
+
+	c.Assert(err, Equals, ErrNoDevice)
+	c.Assert(device, Equals, "")
+}
+
+func (s *BacklightSuite) TestDevicesOk(c *C) {
+	makeDevice(c, s.root, "acpi_video0", "firmware")
+	if err := ioutil.WriteFile(filepath.Join(s.path, "bl_power"), []byte("0\n"), 0644); err != nil {
+		c.Fatal(err)
+	}
+	if err := os.Chmod(filepath.Join(s.root, "acpi_video0", "brightness"), 0444); err != nil {
+		c.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(s.root, "not_a_device"), 0755); err != nil {
+		c.Fatal(err)
+	}
+
+	devices, err := Devices(s.root)
+	c.Assert(err, IsNil)
+	c.Assert(devices, HasLen, 2)
+
+	c.Assert(devices[0].Name, Equals, "acpi_video0")
+	c.Assert(devices[0].Type, Equals, "firmware")
+	c.Assert(devices[0].BlPower, IsNil)
+	c.Assert(devices[0].Writable, Equals, false)
+
+	c.Assert(devices[1].Name, Equals, "intel_backlight")
+	c.Assert(devices[1].Type, Equals, "raw")
+	c.Assert(devices[1].Brightness, Equals, int64(500))
+	c.Assert(devices[1].MaxBrightness, Equals, int64(1000))
+	c.Assert(devices[1].ActualBrightness, Equals, int64(500))
+	c.Assert(devices[1].Percent, Equals, 50)
+	c.Assert(*devices[1].BlPower, Equals, int64(0))
+	c.Assert(devices[1].Writable, Equals, true)
+}
+
+func (s *BacklightSuite) TestDevicesFileContentKo(c *C) {
+	if err := ioutil.WriteFile(filepath.Join(s.path, "max_brightness"), []byte("a"), 0644); err != nil {
+		c.Fatal(err)
+	}
+
+	devices, err := Devices(s.root)
+	c.Assert(err, ErrorMatches, "strconv.ParseInt:.*")
+	c.Assert(devices, HasLen, 0)
+}
@@ -0,0 +1,159 @@
+package backlight
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrNoBattery is returned when no system battery could be found in the power_supply root.
+	ErrNoBattery = errors.New("Error no battery found")
+	// ErrBatteryRules is returned by ParseBatteryRules when the string is not a list of capacity:percent rules.
+	ErrBatteryRules = errors.New("Error battery rules must be like 20:40,10:25, a capacity below which the percentage is capped")
+)
+
+// Battery is a system battery of the power_supply class, with its capacity in percent and its status,
+// like Charging, Discharging, Not charging or Full.
+type Battery struct {
+	Name     string
+	Path     string
+	Capacity int
+	Status   string
+}
+
+// ReadBattery returns the first system battery of the power_supply root, in name order.
+// The batteries of the peripherals, like a mouse, have a Device scope and are skipped.
+// It returns ErrNoBattery when there is none.
+func ReadBattery(root string) (*Battery, error) {
+	entries, err := ioutil.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		path := filepath.Join(root, e.Name())
+		if readAttribute(path, "type") != "Battery" || readAttribute(path, "scope") == "Device" {
+			continue
+		}
+		capacity, err := strconv.Atoi(readAttribute(path, "capacity"))
+		if err != nil {
+			return nil, err
+		}
+		return &Battery{Name: e.Name(), Path: path, Capacity: capacity, Status: readAttribute(path, "status")}, nil
+	}
+	return nil, ErrNoBattery
+}
+
+// Charging reports whether the battery is charging, or full.
+func (b *Battery) Charging() bool {
+	return b.Status == "Charging" || b.Status == "Full"
+}
+
+// BatteryRule caps the brightness to the Cap percentage when the battery capacity is below Below.
+type BatteryRule struct {
+	Below int     `json:"below"`
+	Cap   float64 `json:"cap"`
+}
+
+// BatteryRules are the rules of the battery levels, like 40% below 20% of capacity and 25% below 10%.
+type BatteryRules []BatteryRule
+
+// ParseBatteryRules parses rules like 20:40,10:25, made of capacity:percent rules, both between 0 and 100.
+func ParseBatteryRules(s string) (BatteryRules, error) {
+	var rules BatteryRules
+	for _, r := range strings.Split(s, ",") {
+		fields := strings.Split(strings.TrimSpace(r), ":")
+		if len(fields) != 2 {
+			return nil, ErrBatteryRules
+		}
+		below, err := strconv.Atoi(strings.TrimSuffix(fields[0], "%"))
+		if err != nil || below <= 0 || below > 100 {
+			return nil, ErrBatteryRules
+		}
+		percent, err := strconv.ParseFloat(strings.TrimSuffix(fields[1], "%"), 64)
+		if err != nil || percent <= 0 || percent > 100 {
+			return nil, ErrBatteryRules
+		}
+		rules = append(rules, BatteryRule{below, percent})
+	}
+	return rules, nil
+}
+
+// Rule returns the rule with the lowest cap among the ones matching the capacity, false when none does.
+func (r BatteryRules) Rule(capacity int) (BatteryRule, bool) {
+	var rule BatteryRule
+	found := false
+	for _, candidate := range r {
+		if capacity < candidate.Below && (!found || candidate.Cap < rule.Cap) {
+			rule, found = candidate, true
+		}
+	}
+	return rule, found
+}
+
+// BatteryCap follows the level of a battery and returns the rule capping the brightness.
+// A rule holds until the battery charges again, so the cap doesn't come and go with the capacity around a level,
+// and only a lower cap replaces it.
+// Path is the state file where the rule held is saved, so it holds across the processes, like the commands run
+// for each key press. The rule is only kept in memory when Path is empty.
+type BatteryCap struct {
+	Rules BatteryRules
+	Path  string
+
+	rule  BatteryRule
+	holds bool
+}
+
+// batteryState is the rule held by a BatteryCap, as saved in its state file.
+type batteryState struct {
+	Holds bool        `json:"holds"`
+	Rule  BatteryRule `json:"rule"`
+}
+
+// Update returns the rule capping the brightness with the battery, false when none does.
+// With a Path, the rule held is read from the state file first, and saved when it changes, holding the lock of the file.
+// The state file is best effort : when it can't be read or written, the rule is the one held in memory.
+func (c *BatteryCap) Update(b *Battery) (BatteryRule, bool) {
+	if c.Path != "" {
+		if err := os.MkdirAll(filepath.Dir(c.Path), 0755); err == nil {
+			if unlock, err := lockState(c.Path); err == nil {
+				defer unlock()
+			}
+		}
+		c.load()
+	}
+	before := batteryState{c.holds, c.rule}
+	if b.Charging() {
+		c.holds, c.rule = false, BatteryRule{}
+	} else if rule, ok := c.Rules.Rule(b.Capacity); ok && (!c.holds || rule.Cap < c.rule.Cap) {
+		c.rule, c.holds = rule, true
+	}
+	if state := (batteryState{c.holds, c.rule}); c.Path != "" && state != before {
+		saveState(c.Path, state)
+	}
+	return c.rule, c.holds
+}
+
+// load reads the rule held from the state file. A missing file holds no rule,
+// and neither does a rule which is not one of the Rules anymore.
+func (c *BatteryCap) load() {
+	data, err := ioutil.ReadFile(c.Path)
+	if os.IsNotExist(err) {
+		c.holds, c.rule = false, BatteryRule{}
+		return
+	}
+	var state batteryState
+	if err != nil || json.Unmarshal(data, &state) != nil {
+		return
+	}
+	c.holds, c.rule = false, BatteryRule{}
+	for _, rule := range c.Rules {
+		if state.Holds && rule == state.Rule {
+			c.holds, c.rule = true, rule
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package backlight
+
+import (
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *BacklightSuite) TestReadBatteryOk(c *C) {
+	root := c.MkDir()
+	makeSensor(c, root, "AC", map[string]string{"type": "Mains", "online": "0"})
+	makeSensor(c, root, "hidpp_battery_0", map[string]string{"type": "Battery", "scope": "Device", "capacity": "5", "status": "Discharging"})
+	makeSensor(c, root, "BAT0", map[string]string{"type": "Battery", "capacity": "18", "status": "Discharging"})
+
+	b, err := ReadBattery(root)
+	c.Assert(err, IsNil)
+	c.Assert(b.Name, Equals, "BAT0")
+	c.Assert(b.Capacity, Equals, 18)
+	c.Assert(b.Charging(), Equals, false)
+}
+
+func (s *BacklightSuite) TestReadBatteryKo(c *C) {
+	root := c.MkDir()
+	makeSensor(c, root, "AC", map[string]string{"type": "Mains", "online": "1"})
+
+	_, err := ReadBattery(root)
+	c.Assert(err, Equals, ErrNoBattery)
+}
+
+func (s *BacklightSuite) TestParseBatteryRulesOk(c *C) {
+	rules, err := ParseBatteryRules("20:40%, 10%:25")
+	c.Assert(err, IsNil)
+	c.Assert(rules, DeepEquals, BatteryRules{{20, 40}, {10, 25}})
+
+	for _, capacity := range []int{20, 90} {
+		_, ok := rules.Rule(capacity)
+		c.Assert(ok, Equals, false)
+	}
+	rule, ok := rules.Rule(15)
+	c.Assert(ok, Equals, true)
+	c.Assert(rule, Equals, BatteryRule{20, 40})
+	rule, _ = rules.Rule(3)
+	c.Assert(rule, Equals, BatteryRule{10, 25})
+}
+
+func (s *BacklightSuite) TestParseBatteryRulesKo(c *C) {
+	for _, rules := range []string{"", "20", "20:40:10", "low:40", "20:dim", "0:40", "120:40", "20:0", "20:140"} {
+		_, err := ParseBatteryRules(rules)
+		c.Assert(err, Equals, ErrBatteryRules, Commentf(rules))
+	}
+}
+
+func (s *BacklightSuite) TestBatteryCapHoldsOk(c *C) {
+	guard := BatteryCap{Rules: BatteryRules{{20, 40}, {10, 25}}}
+
+	_, ok := guard.Update(&Battery{Capacity: 50, Status: "Discharging"})
+	c.Assert(ok, Equals, false)
+
+	rule, ok := guard.Update(&Battery{Capacity: 19, Status: "Discharging"})
+	c.Assert(ok, Equals, true)
+	c.Assert(rule.Cap, Equals, float64(40))
+
+	// the capacity read around the level doesn't lift the cap
+	rule, ok = guard.Update(&Battery{Capacity: 21, Status: "Discharging"})
+	c.Assert(ok, Equals, true)
+	c.Assert(rule.Cap, Equals, float64(40))
+
+	rule, _ = guard.Update(&Battery{Capacity: 9, Status: "Discharging"})
+	c.Assert(rule.Cap, Equals, float64(25))
+	rule, _ = guard.Update(&Battery{Capacity: 15, Status: "Not charging"})
+	c.Assert(rule.Cap, Equals, float64(25))
+
+	_, ok = guard.Update(&Battery{Capacity: 9, Status: "Charging"})
+	c.Assert(ok, Equals, false)
+	_, ok = guard.Update(&Battery{Capacity: 30, Status: "Discharging"})
+	c.Assert(ok, Equals, false)
+}
+
+func (s *BacklightSuite) TestBatteryCapPathOk(c *C) {
+	path := filepath.Join(c.MkDir(), "gobacklight", "battery.json")
+	rules := BatteryRules{{20, 40}, {10, 25}}
+	first := BatteryCap{Rules: rules, Path: path}
+	rule, ok := first.Update(&Battery{Capacity: 15, Status: "Discharging"})
+	c.Assert(ok, Equals, true)
+	c.Assert(rule.Cap, Equals, float64(40))
+
+	// the rule held is read by another cap, like the next command
+	next := BatteryCap{Rules: rules, Path: path}
+	rule, ok = next.Update(&Battery{Capacity: 21, Status: "Discharging"})
+	c.Assert(ok, Equals, true)
+	c.Assert(rule.Cap, Equals, float64(40))
+
+	// but not with other rules
+	other := BatteryCap{Rules: BatteryRules{{30, 50}}, Path: path}
+	_, ok = other.Update(&Battery{Capacity: 35, Status: "Discharging"})
+	c.Assert(ok, Equals, false)
+
+	// and charging lifts it for all of them
+	first = BatteryCap{Rules: rules, Path: path}
+	first.Update(&Battery{Capacity: 15, Status: "Discharging"})
+	_, ok = first.Update(&Battery{Capacity: 15, Status: "Charging"})
+	c.Assert(ok, Equals, false)
+	next = BatteryCap{Rules: rules, Path: path}
+	_, ok = next.Update(&Battery{Capacity: 21, Status: "Discharging"})
+	c.Assert(ok, Equals, false)
+}
@@ -0,0 +1,153 @@
+package backlight
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Class is a kernel device class exposing a brightness, each with its own layout of driver files.
+type Class string
+
+const (
+	// ClassBacklight is the class of the panel backlights, under DefaultRoot.
+	ClassBacklight Class = "backlight"
+	// ClassLEDs is the class of the LEDs, like the keyboard backlights, under DefaultLEDRoot.
+	// They have no actual_brightness file, and may be switched off.
+	ClassLEDs Class = "leds"
+	// ClassDDC is the class of the external monitors, driven over DDC/CI on the I2C bus of their connector under DefaultDRMRoot.
+	// Their lowest brightness still lights the panel.
+	ClassDDC Class = "ddc"
+
+	// DefaultLEDRoot is the sysfs folder of the kernel leds class.
+	DefaultLEDRoot = "/sys/class/leds/"
+)
+
+var (
+	// ErrClass is returned by ParseClass when the string is not a known class.
+	ErrClass = errors.New("Error class must be backlight, leds or ddc")
+
+	ledFiles = [2]string{"brightness", "max_brightness"}
+)
+
+// ParseClass returns the class called s.
+func ParseClass(s string) (Class, error) {
+	switch c := Class(s); c {
+	case ClassBacklight, ClassLEDs, ClassDDC:
+		return c, nil
+	}
+	return "", ErrClass
+}
+
+// files returns the driver files of the devices of the class, a zero class being ClassBacklight.
+func (c Class) files() []string {
+	if c == ClassLEDs {
+		return ledFiles[:]
+	}
+	return driverFiles[:]
+}
+
+// backend returns the sysfs Backend of a device of the class in path.
+func (c Class) backend(path string) Backend {
+	if c == ClassLEDs {
+		return SysfsLED{Path: path}
+	}
+	return SysfsBacklight{Path: path}
+}
+
+// Open returns the device of the class called name in the sysfs root, with its values loaded.
+// When name is Auto, it uses Discover to pick the preferred device of the root.
+// It returns an error if the device folder doesn't exist or doesn't contain the driver files of the class.
+// The root of ClassDDC is the drm root, and its devices are opened with OpenDDC on the I2C buses of OpenI2C.
+func (c Class) Open(root string, name string) (*Device, error) {
+	if c == ClassDDC {
+		return OpenDDC(root, name, OpenI2C)
+	}
+	if name == Auto {
+		device, err := c.Discover(root)
+		if err != nil {
+			return nil, err
+		}
+		name = device
+	}
+	path := filepath.Join(root, name)
+	d := &Device{Name: name, Path: path, Class: c, Backend: c.backend(path)}
+	if _, err := os.Stat(d.Path); err != nil {
+		return nil, err
+	}
+	if err := d.Load(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Discover scans the sysfs root and returns the name of the preferred device of the class.
+// Backlights are ranked on their type attribute : firmware first, then platform, then raw.
+// Only the keyboard backlights, called like tpacpi::kbd_backlight, are picked among the LEDs, and the first display for ClassDDC.
+// Devices of the same rank are ordered by name, so the choice is deterministic.
+// It returns ErrNoDevice when no folder of the root contains the driver files.
+func (c Class) Discover(root string) (string, error) {
+	if c == ClassDDC {
+		displays, err := Displays(root)
+		if err != nil {
+			return "", err
+		}
+		if len(displays) == 0 {
+			return "", ErrNoDisplay
+		}
+		return displays[0].Name, nil
+	}
+	entries, err := ioutil.ReadDir(root)
+	if err != nil {
+		return "", err
+	}
+	device, rank := "", len(typePriority)
+	for _, e := range entries {
+		path := filepath.Join(root, e.Name())
+		if _, err := checkFiles(path, c.files()); err != nil {
+			continue
+		}
+		if c == ClassLEDs && !strings.HasSuffix(e.Name(), "::kbd_backlight") {
+			continue
+		}
+		r, ok := typePriority[readAttribute(path, "type")]
+		if !ok {
+			r = len(typePriority)
+		}
+		if device == "" || r < rank {
+			device, rank = e.Name(), r
+		}
+	}
+	if device == "" {
+		return "", ErrNoDevice
+	}
+	return device, nil
+}
+
+// Devices walks the sysfs root and returns the attributes of every device of the class, ordered by name.
+// Folders of the root which don't contain the driver files are skipped.
+// It returns an error when the driver files of a device could not be read.
+func (c Class) Devices(root string) ([]Info, error) {
+	if c == ClassDDC {
+		return DDCDevices(root, OpenI2C)
+	}
+	entries, err := ioutil.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+	devices := []Info{}
+	for _, e := range entries {
+		path := filepath.Join(root, e.Name())
+		d := &Device{Name: e.Name(), Path: path, Class: c, Backend: c.backend(path)}
+		if _, err := checkFiles(d.Path, c.files()); err != nil {
+			continue
+		}
+		if err := d.Load(); err != nil {
+			return nil, err
+		}
+		devices = append(devices, d.Info())
+	}
+	return devices, nil
+}
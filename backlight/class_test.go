@@ -0,0 +1,75 @@
+package backlight
+
+import (
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+)
+
+// makeLED creates a fake LED in root, with a brightness of 1 out of 2, like a keyboard backlight.
+func makeLED(c *C, root string, name string) string {
+	return makeSensor(c, root, name, map[string]string{"brightness": "1", "max_brightness": "2"})
+}
+
+func (s *BacklightSuite) TestParseClassOk(c *C) {
+	class, err := ParseClass("leds")
+	c.Assert(err, IsNil)
+	c.Assert(class, Equals, ClassLEDs)
+
+	_, err = ParseClass("keyboard")
+	c.Assert(err, Equals, ErrClass)
+}
+
+func (s *BacklightSuite) TestOpenLEDOk(c *C) {
+	root := c.MkDir()
+	path := makeLED(c, root, "tpacpi::kbd_backlight")
+
+	_, err := Open(root, "tpacpi::kbd_backlight")
+	c.Assert(err, Equals, ErrDriverFiles)
+
+	d, err := ClassLEDs.Open(root, "tpacpi::kbd_backlight")
+	c.Assert(err, IsNil)
+	c.Assert(d.ActualBrightness, Equals, int64(1))
+	c.Assert(d.MaxBrightness, Equals, int64(2))
+	c.Assert(d.Get(), Equals, 50)
+	c.Assert(d.Info().Class, Equals, ClassLEDs)
+
+	c.Assert(d.Inc(50), IsNil)
+	c.Assert(readValue(c, filepath.Join(path, "brightness")), Equals, int64(2))
+
+	// the LEDs may be switched off
+	c.Assert(d.Set(0), IsNil)
+	c.Assert(d.Clamped, Equals, false)
+	c.Assert(readValue(c, filepath.Join(path, "brightness")), Equals, int64(0))
+	c.Assert(d.Load(), IsNil)
+	c.Assert(d.ActualBrightness, Equals, int64(0))
+}
+
+func (s *BacklightSuite) TestDiscoverLEDOk(c *C) {
+	root := c.MkDir()
+	makeLED(c, root, "input3::capslock")
+	makeLED(c, root, "tpacpi::kbd_backlight")
+	makeLED(c, root, "tpacpi::power")
+
+	name, err := ClassLEDs.Discover(root)
+	c.Assert(err, IsNil)
+	c.Assert(name, Equals, "tpacpi::kbd_backlight")
+
+	devices, err := ClassLEDs.Devices(root)
+	c.Assert(err, IsNil)
+	c.Assert(devices, HasLen, 3)
+	c.Assert(devices[0].Name, Equals, "input3::capslock")
+	c.Assert(devices[0].Class, Equals, ClassLEDs)
+
+	devices, err = Devices(root)
+	c.Assert(err, IsNil)
+	c.Assert(devices, HasLen, 0)
+}
+
+func (s *BacklightSuite) TestDiscoverLEDKo(c *C) {
+	root := c.MkDir()
+	makeLED(c, root, "input3::capslock")
+
+	_, err := ClassLEDs.Discover(root)
+	c.Assert(err, Equals, ErrNoDevice)
+}
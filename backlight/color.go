@@ -0,0 +1,122 @@
+package backlight
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrColor is returned by ParseColor when the string is not a color.
+	ErrColor = errors.New("Error color must be like ff8800 or red=255,green=128,blue=0")
+	// ErrNoColor is returned when a color is given to a device without multi_index and multi_intensity files.
+	ErrNoColor = errors.New("Error the device has no color channels")
+)
+
+// hexChannels are the channels of a hex color, in order.
+var hexChannels = [3]string{"red", "green", "blue"}
+
+// readColor reads the channels of a multicolor LED, through the ColorBackend of the device.
+// The channels are empty when the device has none, or when its backend has no colors.
+func readColor(b Backend) ([]string, []int64, error) {
+	cb, ok := b.(ColorBackend)
+	if !ok {
+		return nil, nil, nil
+	}
+	return cb.ReadColor()
+}
+
+// ParseColor returns the intensities of the channels of the device for a color, the ones it doesn't give being unchanged.
+// The color is a hex color like ff8800, scaled from 255 to MaxBrightness, for the red, green and blue channels,
+// or a list of channel=intensity like red=255,green=128,blue=0, each intensity being at most MaxBrightness.
+// It returns ErrNoColor when the device has no channels, and ErrColor when the string is not a color of the device,
+// or when the backend read fewer or more intensities than channels.
+func (d *Device) ParseColor(s string) ([]int64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.Channels) == 0 {
+		return nil, ErrNoColor
+	}
+	if len(d.Intensities) != len(d.Channels) {
+		return nil, ErrColor
+	}
+	intensities := append([]int64(nil), d.Intensities...)
+	set := func(channel string, v int64) error {
+		for i, c := range d.Channels {
+			if c == channel {
+				intensities[i] = v
+				return nil
+			}
+		}
+		return ErrColor
+	}
+
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 6 && !strings.Contains(hex, "=") {
+		for i, channel := range hexChannels {
+			v, err := strconv.ParseUint(hex[2*i:2*i+2], 16, 8)
+			if err != nil {
+				return nil, ErrColor
+			}
+			if err := set(channel, (int64(v)*d.MaxBrightness+127)/255); err != nil {
+				return nil, err
+			}
+		}
+		return intensities, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		fields := strings.Split(strings.TrimSpace(pair), "=")
+		if len(fields) != 2 {
+			return nil, ErrColor
+		}
+		v, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil || v < 0 || v > d.MaxBrightness {
+			return nil, ErrColor
+		}
+		if err := set(fields[0], v); err != nil {
+			return nil, err
+		}
+	}
+	return intensities, nil
+}
+
+// SetColor writes the intensities of the channels of the device, in the order of its Channels.
+// It returns ErrNoColor when the device has no channels, ErrColor when the intensities don't match them,
+// or the error of the backend.
+func (d *Device) SetColor(intensities []int64) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.Channels) == 0 {
+		return ErrNoColor
+	}
+	if len(intensities) != len(d.Channels) {
+		return ErrColor
+	}
+	b, ok := d.backend().(ColorBackend)
+	if !ok {
+		return ErrNoColor
+	}
+	for _, v := range intensities {
+		if v < 0 || v > d.MaxBrightness {
+			return ErrColor
+		}
+	}
+	if err := b.WriteColor(intensities); err != nil {
+		return err
+	}
+	d.Intensities = append([]int64(nil), intensities...)
+	return nil
+}
+
+// Color returns the intensities of the channels, like red=255,green=128,blue=0, empty when the device has none.
+// It returns ErrColor when the intensities don't match the channels.
+func (i Info) Color() (string, error) {
+	if len(i.Intensities) != len(i.Channels) {
+		return "", ErrColor
+	}
+	pairs := make([]string, len(i.Channels))
+	for n, c := range i.Channels {
+		pairs[n] = c + "=" + strconv.FormatInt(i.Intensities[n], 10)
+	}
+	return strings.Join(pairs, ","), nil
+}
@@ -0,0 +1,129 @@
+package backlight
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+// makeRGB creates a fake multicolor LED in root, white at full brightness out of 100.
+func makeRGB(c *C, root string, name string) string {
+	return makeSensor(c, root, name, map[string]string{
+		"brightness":      "100",
+		"max_brightness":  "100",
+		"multi_index":     "red green blue",
+		"multi_intensity": "100 100 100",
+	})
+}
+
+func (s *BacklightSuite) TestLoadColorOk(c *C) {
+	root := c.MkDir()
+	makeRGB(c, root, "rgb:kbd_backlight")
+	makeLED(c, root, "input3::capslock")
+
+	d, err := ClassLEDs.Open(root, "rgb:kbd_backlight")
+	c.Assert(err, IsNil)
+	c.Assert(d.Channels, DeepEquals, []string{"red", "green", "blue"})
+	c.Assert(d.Intensities, DeepEquals, []int64{100, 100, 100})
+	color, err := d.Info().Color()
+	c.Assert(err, IsNil)
+	c.Assert(color, Equals, "red=100,green=100,blue=100")
+
+	d, err = ClassLEDs.Open(root, "input3::capslock")
+	c.Assert(err, IsNil)
+	c.Assert(d.Channels, HasLen, 0)
+	color, err = d.Info().Color()
+	c.Assert(err, IsNil)
+	c.Assert(color, Equals, "")
+}
+
+func (s *BacklightSuite) TestLoadColorKo(c *C) {
+	root := c.MkDir()
+	path := makeRGB(c, root, "rgb:kbd_backlight")
+	if err := ioutil.WriteFile(filepath.Join(path, "multi_intensity"), []byte("100 100\n"), 0644); err != nil {
+		c.Fatal(err)
+	}
+
+	_, err := ClassLEDs.Open(root, "rgb:kbd_backlight")
+	c.Assert(err, ErrorMatches, "Error rgb:kbd_backlight has 2 intensities for 3 channels")
+}
+
+func (s *BacklightSuite) TestParseColorOk(c *C) {
+	root := c.MkDir()
+	makeRGB(c, root, "rgb:kbd_backlight")
+	d, err := ClassLEDs.Open(root, "rgb:kbd_backlight")
+	c.Assert(err, IsNil)
+
+	for color, expected := range map[string][]int64{
+		"ff8800":                 {100, 53, 0},
+		"#000000":                {0, 0, 0},
+		"red=50,blue=0":          {50, 100, 0},
+		"green=0, red=100":       {100, 0, 100},
+		"blue=100,green=0,red=1": {1, 0, 100},
+	} {
+		intensities, err := d.ParseColor(color)
+		c.Assert(err, IsNil)
+		c.Assert(intensities, DeepEquals, expected, Commentf("color %s", color))
+	}
+	// the intensities of the device are unchanged
+	c.Assert(d.Intensities, DeepEquals, []int64{100, 100, 100})
+}
+
+func (s *BacklightSuite) TestParseColorKo(c *C) {
+	root := c.MkDir()
+	makeRGB(c, root, "rgb:kbd_backlight")
+	makeLED(c, root, "input3::capslock")
+	d, err := ClassLEDs.Open(root, "rgb:kbd_backlight")
+	c.Assert(err, IsNil)
+
+	for _, color := range []string{"", "orange", "gg8800", "red=101", "red=-1", "white=10", "red:10", "red=1=2"} {
+		_, err := d.ParseColor(color)
+		c.Assert(err, Equals, ErrColor, Commentf("color %s", color))
+	}
+
+	d, err = ClassLEDs.Open(root, "input3::capslock")
+	c.Assert(err, IsNil)
+	_, err = d.ParseColor("ff8800")
+	c.Assert(err, Equals, ErrNoColor)
+}
+
+func (s *BacklightSuite) TestColorShortKo(c *C) {
+	// a backend reading fewer intensities than channels
+	d, err := NewDevice("rgb", &Fake{Max: 100, Channels: []string{"red", "green", "blue"}, Intensities: []int64{100}})
+	c.Assert(err, IsNil)
+	_, err = d.Info().Color()
+	c.Assert(err, Equals, ErrColor)
+	_, err = d.ParseColor("ff8800")
+	c.Assert(err, Equals, ErrColor)
+	_, err = d.ParseColor("red=10")
+	c.Assert(err, Equals, ErrColor)
+}
+
+func (s *BacklightSuite) TestSetColorOk(c *C) {
+	root := c.MkDir()
+	path := makeRGB(c, root, "rgb:kbd_backlight")
+	d, err := ClassLEDs.Open(root, "rgb:kbd_backlight")
+	c.Assert(err, IsNil)
+
+	c.Assert(d.SetColor([]int64{100, 53, 0}), IsNil)
+	data, err := ioutil.ReadFile(filepath.Join(path, "multi_intensity"))
+	c.Assert(err, IsNil)
+	c.Assert(strings.TrimSpace(string(data)), Equals, "100 53 0")
+	c.Assert(d.Load(), IsNil)
+	c.Assert(d.Intensities, DeepEquals, []int64{100, 53, 0})
+	// the overall brightness is unchanged
+	c.Assert(d.Brightness, Equals, int64(100))
+}
+
+func (s *BacklightSuite) TestSetColorKo(c *C) {
+	root := c.MkDir()
+	makeRGB(c, root, "rgb:kbd_backlight")
+	d, err := ClassLEDs.Open(root, "rgb:kbd_backlight")
+	c.Assert(err, IsNil)
+
+	c.Assert(d.SetColor([]int64{100, 0}), Equals, ErrColor)
+	c.Assert(d.SetColor([]int64{100, 0, 101}), Equals, ErrColor)
+	c.Assert(d.Intensities, DeepEquals, []int64{100, 100, 100})
+}
@@ -0,0 +1,122 @@
+package backlight
+
+import (
+	"fmt"
+	"math"
+)
+
+// DefaultExponent is the exponent of the exponential curve when none is given.
+const DefaultExponent = 4.0
+
+// Curve converts percentages to raw brightness values and back.
+// Both conversions use the same mapping, so setting a percentage and reading it back round-trips,
+// as long as the max brightness has enough steps for the dim percentages of the curve, see CIE1931MinMax.
+type Curve interface {
+	// Raw returns the raw brightness value of a percentage, for a device of the given max brightness.
+	Raw(percent float64, max int64) int64
+	// Percent returns the percentage of a raw brightness value, for a device of the given max brightness.
+	Percent(raw int64, max int64) float64
+}
+
+// LinearCurve maps percentages to raw values linearly, which is what the hardware does.
+type LinearCurve struct{}
+
+// Raw implements the Curve interface.
+func (LinearCurve) Raw(percent float64, max int64) int64 {
+	return int64(math.Round(percent * float64(max) / 100))
+}
+
+// Percent implements the Curve interface.
+func (LinearCurve) Percent(raw int64, max int64) float64 {
+	if max <= 0 {
+		return 0
+	}
+	return float64(raw) * 100 / float64(max)
+}
+
+// ExponentialCurve maps percentages to raw values with a power law, so low percentages get finer steps.
+// An Exponent of 1 is linear, higher exponents give more room to the dim values.
+type ExponentialCurve struct {
+	Exponent float64
+}
+
+// Raw implements the Curve interface.
+func (e ExponentialCurve) Raw(percent float64, max int64) int64 {
+	if percent <= 0 {
+		return int64(math.Round(percent * float64(max) / 100))
+	}
+	return int64(math.Round(math.Pow(percent/100, e.exponent()) * float64(max)))
+}
+
+// Percent implements the Curve interface.
+func (e ExponentialCurve) Percent(raw int64, max int64) float64 {
+	if max <= 0 || raw <= 0 {
+		return 0
+	}
+	return math.Pow(float64(raw)/float64(max), 1/e.exponent()) * 100
+}
+
+func (e ExponentialCurve) exponent() float64 {
+	if e.Exponent <= 0 {
+		return DefaultExponent
+	}
+	return e.Exponent
+}
+
+// CIE1931Curve treats percentages as the CIE 1931 lightness L*, which follows the perceived brightness.
+type CIE1931Curve struct{}
+
+// Raw implements the Curve interface.
+func (CIE1931Curve) Raw(percent float64, max int64) int64 {
+	var y float64
+	if percent <= 8 {
+		y = percent / 903.3
+	} else {
+		y = math.Pow((percent+16)/116, 3)
+	}
+	return int64(math.Round(y * float64(max)))
+}
+
+// Percent implements the Curve interface.
+func (CIE1931Curve) Percent(raw int64, max int64) float64 {
+	if max <= 0 {
+		return 0
+	}
+	y := float64(raw) / float64(max)
+	if y <= 0.008856 {
+		return y * 903.3
+	}
+	return 116*math.Cbrt(y) - 16
+}
+
+// CIE1931MinMax is the lowest max brightness on which every percentage of the CIE1931Curve round-trips :
+// on smaller ranges, several dim percentages share the same raw value, and get reads back another percentage.
+const CIE1931MinMax = 850
+
+// ScaleCurve returns the curve suited to the scale attribute and the max brightness of a device.
+// Hardware with a linear scale gets the perceptual CIE1931Curve, while non-linear hardware
+// already follows the perceived brightness and gets the pass-through LinearCurve, like unknown scales.
+// A linear hardware with fewer than CIE1931MinMax steps gets the LinearCurve too, so the percentages round-trip.
+func ScaleCurve(scale string, max int64) Curve {
+	if scale == "linear" && max >= CIE1931MinMax {
+		return CIE1931Curve{}
+	}
+	return LinearCurve{}
+}
+
+// ParseCurve returns the curve called name : auto, linear, exponential or cie1931.
+// For auto or an empty name it returns a nil Curve, so the Device picks it from its Scale.
+// The exponent is only used by the exponential curve, DefaultExponent is used when it is zero.
+func ParseCurve(name string, exponent float64) (Curve, error) {
+	switch name {
+	case "auto", "":
+		return nil, nil
+	case "linear":
+		return LinearCurve{}, nil
+	case "exponential":
+		return ExponentialCurve{Exponent: exponent}, nil
+	case "cie1931":
+		return CIE1931Curve{}, nil
+	}
+	return nil, fmt.Errorf("Error unknown curve %s", name)
+}
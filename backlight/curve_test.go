@@ -0,0 +1,169 @@
+package backlight
+
+import (
+	"io/ioutil"
+	"math"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+)
+
+var curves = map[string]Curve{
+	"linear":        LinearCurve{},
+	"exponential":   ExponentialCurve{},
+	"exponential-2": ExponentialCurve{Exponent: 2},
+	"cie1931":       CIE1931Curve{},
+}
+
+func (s *BacklightSuite) TestCurvesRoundTripOk(c *C) {
+	tests := []struct {
+		curve string
+		max   int64
+		from  int
+	}{
+		{"linear", 100, 1},
+		{"linear", 255, 1},
+		{"linear", 1000, 1},
+		{"linear", 120000, 1},
+		{"cie1931", CIE1931MinMax, 1},
+		{"cie1931", 1000, 1},
+		{"cie1931", 120000, 1},
+		{"exponential-2", 120000, 1},
+		{"exponential", 120000, 10},
+	}
+	for _, t := range tests {
+		curve := curves[t.curve]
+		for p := t.from; p <= 100; p++ {
+			raw := curve.Raw(float64(p), t.max)
+			back := int(math.Round(curve.Percent(raw, t.max)))
+			c.Assert(back, Equals, p, Commentf("curve %s, max %d", t.curve, t.max))
+		}
+	}
+}
+
+func (s *BacklightSuite) TestCurvesRoundTripKo(c *C) {
+	// below CIE1931MinMax, the dim percentages of the CIE1931Curve share their raw values
+	for _, max := range []int64{100, 255, CIE1931MinMax - 1} {
+		missed := 0
+		for p := 1; p <= 100; p++ {
+			if int(math.Round(CIE1931Curve{}.Percent(CIE1931Curve{}.Raw(float64(p), max), max))) != p {
+				missed++
+			}
+		}
+		c.Assert(missed > 0, Equals, true, Commentf("max %d", max))
+	}
+}
+
+func (s *BacklightSuite) TestCurvesBoundsOk(c *C) {
+	for name, curve := range curves {
+		c.Assert(curve.Raw(0, 1000), Equals, int64(0), Commentf("curve %s", name))
+		c.Assert(curve.Raw(100, 1000), Equals, int64(1000), Commentf("curve %s", name))
+		c.Assert(curve.Percent(0, 1000), Equals, float64(0), Commentf("curve %s", name))
+		c.Assert(curve.Percent(1000, 1000), Equals, float64(100), Commentf("curve %s", name))
+		c.Assert(curve.Percent(500, 0), Equals, float64(0), Commentf("curve %s", name))
+	}
+}
+
+func (s *BacklightSuite) TestCurvesPerceptualOk(c *C) {
+	c.Assert(LinearCurve{}.Raw(50, 1000), Equals, int64(500))
+	c.Assert(ExponentialCurve{Exponent: 2}.Raw(50, 1000), Equals, int64(250))
+	c.Assert(ExponentialCurve{}.Raw(50, 1000), Equals, int64(63))
+	c.Assert(CIE1931Curve{}.Raw(50, 1000), Equals, int64(184))
+	c.Assert(CIE1931Curve{}.Raw(5, 1000), Equals, int64(6))
+}
+
+func (s *BacklightSuite) TestParseCurveOk(c *C) {
+	curve, err := ParseCurve("exponential", 2.2)
+	c.Assert(err, IsNil)
+	c.Assert(curve, Equals, ExponentialCurve{Exponent: 2.2})
+
+	curve, err = ParseCurve("cie1931", 0)
+	c.Assert(err, IsNil)
+	c.Assert(curve, Equals, CIE1931Curve{})
+
+	curve, err = ParseCurve("linear", 0)
+	c.Assert(err, IsNil)
+	c.Assert(curve, Equals, LinearCurve{})
+
+	curve, err = ParseCurve("auto", 0)
+	c.Assert(err, IsNil)
+	c.Assert(curve, IsNil)
+}
+
+func (s *BacklightSuite) TestScaleCurveOk(c *C) {
+	c.Assert(ScaleCurve("linear", 1000), Equals, CIE1931Curve{})
+	c.Assert(ScaleCurve("non-linear", 1000), Equals, LinearCurve{})
+	c.Assert(ScaleCurve("unknown", 1000), Equals, LinearCurve{})
+	c.Assert(ScaleCurve("", 1000), Equals, LinearCurve{})
+	// the small ranges keep the linear curve, on which every percentage round-trips
+	c.Assert(ScaleCurve("linear", CIE1931MinMax), Equals, CIE1931Curve{})
+	c.Assert(ScaleCurve("linear", 255), Equals, LinearCurve{})
+	c.Assert(ScaleCurve("linear", 100), Equals, LinearCurve{})
+}
+
+func (s *BacklightSuite) TestSetScaleOk(c *C) {
+	if err := ioutil.WriteFile(filepath.Join(s.path, "scale"), []byte("linear\n"), 0644); err != nil {
+		c.Fatal(err)
+	}
+	d, err := Open(s.root, "intel_backlight")
+	if err != nil {
+		c.Fatal(err)
+	}
+
+	c.Assert(d.Scale, Equals, "linear")
+	c.Assert(d.Set(50), IsNil)
+	c.Assert(d.Brightness, Equals, int64(184))
+
+	d.Curve = LinearCurve{}
+	c.Assert(d.Set(50), IsNil)
+	c.Assert(d.Brightness, Equals, int64(500))
+}
+
+func (s *BacklightSuite) TestSetScaleNonLinearOk(c *C) {
+	if err := ioutil.WriteFile(filepath.Join(s.path, "scale"), []byte("non-linear\n"), 0644); err != nil {
+		c.Fatal(err)
+	}
+	d, err := Open(s.root, "intel_backlight")
+	if err != nil {
+		c.Fatal(err)
+	}
+
+	c.Assert(d.Scale, Equals, "non-linear")
+	c.Assert(d.Set(50), IsNil)
+	c.Assert(d.Brightness, Equals, int64(500))
+}
+
+func (s *BacklightSuite) TestParseCurveKo(c *C) {
+	curve, err := ParseCurve("gamma", 0)
+
+	c.Assert(err, ErrorMatches, "Error unknown curve gamma")
+	c.Assert(curve, IsNil)
+}
+
+func (s *BacklightSuite) TestSetCurveRoundTripOk(c *C) {
+	d, err := Open(s.root, "intel_backlight")
+	if err != nil {
+		c.Fatal(err)
+	}
+	d.Curve = CIE1931Curve{}
+
+	c.Assert(d.Set(50), IsNil)
+	c.Assert(s.syncActual(c, d), IsNil)
+	c.Assert(d.Brightness, Equals, int64(184))
+	c.Assert(d.Get(), Equals, 50)
+}
+
+func (s *BacklightSuite) TestDecCurveOk(c *C) {
+	d, err := Open(s.root, "intel_backlight")
+	if err != nil {
+		c.Fatal(err)
+	}
+	d.Curve = CIE1931Curve{}
+
+	c.Assert(d.Set(10), IsNil)
+	c.Assert(s.syncActual(c, d), IsNil)
+	c.Assert(d.Dec(5), IsNil)
+	c.Assert(s.syncActual(c, d), IsNil)
+	c.Assert(d.Brightness, Equals, int64(5))
+	c.Assert(d.Get(), Equals, 5)
+}
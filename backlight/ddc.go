@@ -0,0 +1,364 @@
+package backlight
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultDRMRoot is the sysfs folder of the kernel drm class, where the connectors of the displays link their DDC bus.
+	DefaultDRMRoot = "/sys/class/drm/"
+
+	// DefaultDDCDelay is the delay the monitors need between a request and its reply, and between two requests.
+	DefaultDDCDelay = 50 * time.Millisecond
+	// DefaultDDCRetries is the number of times a request is sent again when the reply is missing or corrupted.
+	DefaultDDCRetries = 3
+
+	// VCPBrightness is the VCP feature of the brightness of a monitor, the luminance of the MCCS standard.
+	VCPBrightness = 0x10
+
+	// ddcAddress is the I2C address of the DDC/CI of the monitors.
+	ddcAddress = 0x37
+	// ddcHost is the source address of the requests, and ddcDisplay the one of the replies.
+	ddcHost    = 0x51
+	ddcDisplay = 0x6e
+	// ddcReplyChecksum is the virtual host address the checksum of the replies starts from.
+	ddcReplyChecksum = 0x50
+
+	vcpGet      = 0x01
+	vcpGetReply = 0x02
+	vcpSet      = 0x03
+
+	// devRoot is the folder of the I2C bus devices.
+	devRoot = "/dev"
+)
+
+var (
+	// ErrDDCReply is returned when a monitor answers a reply which is not the one of the request.
+	ErrDDCReply = errors.New("Error invalid DDC/CI reply")
+	// ErrDDCChecksum is returned when the checksum of a reply doesn't match.
+	ErrDDCChecksum = errors.New("Error DDC/CI checksum mismatch")
+	// ErrDDCNull is returned when a monitor has no reply ready, it answers the null message.
+	ErrDDCNull = errors.New("Error DDC/CI null reply, the monitor is busy")
+	// ErrVCPUnsupported is returned when a monitor doesn't support the VCP feature.
+	ErrVCPUnsupported = errors.New("Error VCP feature not supported by the monitor")
+	// ErrNoDisplay is returned when no connector of the root has a DDC bus.
+	ErrNoDisplay = errors.New("Error no display with a DDC bus found")
+)
+
+// I2C is the transport of DDC/CI : an I2C bus, like /dev/i2c-4, bound to the DDC/CI address 0x37.
+// Write sends the bytes of a request, and Read fills buf with the bytes of the reply.
+type I2C interface {
+	Write(data []byte) error
+	Read(buf []byte) error
+	Close() error
+}
+
+// Display is a connector of a display with a DDC bus, like DP-1 for the connector card0-DP-1, and the path of its I2C bus.
+type Display struct {
+	Name      string `json:"name"`
+	Connector string `json:"connector"`
+	Bus       string `json:"bus"`
+}
+
+// Displays returns the connected displays of the drm root which have a DDC bus, ordered by name.
+// The connectors without a status file are kept, and the ones without a ddc link are skipped.
+func Displays(root string) ([]Display, error) {
+	entries, err := ioutil.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+	displays := []Display{}
+	for _, e := range entries {
+		parts := strings.SplitN(e.Name(), "-", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		path := filepath.Join(root, e.Name())
+		if status := readAttribute(path, "status"); status != "" && status != "connected" {
+			continue
+		}
+		link, err := os.Readlink(filepath.Join(path, "ddc"))
+		if err != nil {
+			continue
+		}
+		displays = append(displays, Display{Name: parts[1], Connector: e.Name(), Bus: filepath.Join(devRoot, filepath.Base(link))})
+	}
+	return displays, nil
+}
+
+// OpenDDC returns the display called name in the drm root, like DP-1, driven over DDC/CI on the I2C bus given by open,
+// with its values loaded. When name is Auto, the first display is picked.
+// It returns ErrNoDisplay when the display doesn't exist, or the error of the bus.
+func OpenDDC(root string, name string, open func(bus string) (I2C, error)) (*Device, error) {
+	displays, err := Displays(root)
+	if err != nil {
+		return nil, err
+	}
+	for _, display := range displays {
+		if name != Auto && name != display.Name && name != display.Connector {
+			continue
+		}
+		bus, err := open(display.Bus)
+		if err != nil {
+			return nil, err
+		}
+		d := &Device{Name: display.Name, Path: display.Bus, Class: ClassDDC, Backend: &DDC{Bus: bus}}
+		if err := d.Load(); err != nil {
+			bus.Close()
+			return nil, err
+		}
+		return d, nil
+	}
+	return nil, ErrNoDisplay
+}
+
+// DDCDevices returns the attributes of the displays of the drm root, through the I2C buses given by open.
+// The displays which don't answer DDC/CI, like when it is disabled in their menu, are skipped.
+func DDCDevices(root string, open func(bus string) (I2C, error)) ([]Info, error) {
+	displays, err := Displays(root)
+	if err != nil {
+		return nil, err
+	}
+	devices := []Info{}
+	for _, display := range displays {
+		d, err := OpenDDC(root, display.Connector, open)
+		if err != nil {
+			continue
+		}
+		devices = append(devices, d.Info())
+		d.Close()
+	}
+	return devices, nil
+}
+
+// DDC is the Backend of an external monitor, reading and writing the VCP feature of the brightness over DDC/CI.
+// Delay is the delay between a request and its reply, and between two requests, DefaultDDCDelay when zero,
+// and Retries is the number of retries of a missing or corrupted reply, DefaultDDCRetries when zero.
+type DDC struct {
+	Bus     I2C
+	Delay   time.Duration
+	Retries int
+
+	mu   sync.Mutex
+	last time.Time
+}
+
+// Read reads the current and the maximum value of VCPBrightness, the brightness last written being the current one.
+func (b *DDC) Read() (Reading, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var err error
+	for i := 0; i <= b.retries(); i++ {
+		var current, max uint16
+		if current, max, err = b.get(VCPBrightness); err == nil {
+			return Reading{Brightness: int64(current), Actual: int64(current), Max: int64(max)}, nil
+		}
+		if err == ErrVCPUnsupported {
+			break
+		}
+	}
+	return Reading{}, err
+}
+
+// Write sets the value of VCPBrightness, the monitors don't acknowledge it.
+func (b *DDC) Write(raw int64) error {
+	if raw < 0 || raw > 0xffff {
+		return ErrRange
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var err error
+	for i := 0; i <= b.retries(); i++ {
+		if err = b.send(vcpSet, VCPBrightness, byte(raw>>8), byte(raw)); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// Describe returns the attributes of the monitor : it has no files to watch, and can always be written.
+func (b *DDC) Describe() Description {
+	return Description{Type: "ddc", Writable: true}
+}
+
+// Close closes the I2C bus.
+func (b *DDC) Close() error {
+	return b.Bus.Close()
+}
+
+func (b *DDC) delay() time.Duration {
+	if b.Delay <= 0 {
+		return DefaultDDCDelay
+	}
+	return b.Delay
+}
+
+func (b *DDC) retries() int {
+	if b.Retries <= 0 {
+		return DefaultDDCRetries
+	}
+	return b.Retries
+}
+
+// pace returns the delay between two requests, and the time left before the next one can be sent,
+// so a fade paces its frames without holding the device during the sleep of send.
+func (b *DDC) pace() (time.Duration, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.delay(), b.delay() - time.Since(b.last)
+}
+
+// send sends a request, after the delay since the previous one.
+func (b *DDC) send(payload ...byte) error {
+	if wait := b.delay() - time.Since(b.last); wait > 0 {
+		time.Sleep(wait)
+	}
+	defer func() { b.last = time.Now() }()
+	return b.Bus.Write(ddcRequest(payload...))
+}
+
+// get sends a Get VCP Feature request, and reads the reply after the delay.
+func (b *DDC) get(feature byte) (uint16, uint16, error) {
+	if err := b.send(vcpGet, feature); err != nil {
+		return 0, 0, err
+	}
+	time.Sleep(b.delay())
+	reply := make([]byte, 11)
+	err := b.Bus.Read(reply)
+	b.last = time.Now()
+	if err != nil {
+		return 0, 0, err
+	}
+	return parseVCPReply(reply, feature)
+}
+
+// ddcRequest returns the bytes of a request with its payload : the source address, the length and the checksum,
+// which starts from the destination address.
+func ddcRequest(payload ...byte) []byte {
+	data := append([]byte{ddcHost, 0x80 | byte(len(payload))}, payload...)
+	return append(data, checksum(ddcAddress<<1, data))
+}
+
+// parseVCPReply returns the current and the maximum value of the reply of a Get VCP Feature request.
+func parseVCPReply(reply []byte, feature byte) (uint16, uint16, error) {
+	if len(reply) < 3 || reply[0] != ddcDisplay || reply[1]&0x80 == 0 {
+		return 0, 0, ErrDDCReply
+	}
+	n := int(reply[1] & 0x7f)
+	if n+3 > len(reply) {
+		return 0, 0, ErrDDCReply
+	}
+	if checksum(ddcReplyChecksum, reply[:n+2]) != reply[n+2] {
+		return 0, 0, ErrDDCChecksum
+	}
+	if n == 0 {
+		return 0, 0, ErrDDCNull
+	}
+	payload := reply[2 : n+2]
+	if n != 8 || payload[0] != vcpGetReply || payload[2] != feature {
+		return 0, 0, ErrDDCReply
+	}
+	if payload[1] != 0 {
+		return 0, 0, ErrVCPUnsupported
+	}
+	return uint16(payload[6])<<8 | uint16(payload[7]), uint16(payload[4])<<8 | uint16(payload[5]), nil
+}
+
+// checksum returns the xor of the bytes, starting from the address.
+func checksum(address byte, data []byte) byte {
+	sum := address
+	for _, b := range data {
+		sum ^= b
+	}
+	return sum
+}
+
+// FakeMonitor is a simulated monitor answering DDC/CI on the I2C interface, for the tests of the programs using the package.
+// Brightness and Max are the values of VCPBrightness, Corrupt is the number of next replies sent with a wrong checksum,
+// and Delay is the delay it needs before a reply is ready, it answers the null message when it is read sooner.
+type FakeMonitor struct {
+	Brightness uint16
+	Max        uint16
+	Corrupt    int
+	Delay      time.Duration
+
+	mu      sync.Mutex
+	reply   []byte
+	written time.Time
+	sets    []uint16
+	closed  bool
+}
+
+// Write receives a request, and prepares the reply of a Get VCP Feature request.
+// It returns ErrDDCChecksum when the checksum of the request doesn't match, like a monitor ignoring it.
+func (m *FakeMonitor) Write(data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return ErrClosed
+	}
+	m.reply, m.written = nil, time.Now()
+	if len(data) < 3 || data[0] != ddcHost || int(data[1]&0x7f)+3 != len(data) || checksum(ddcAddress<<1, data[:len(data)-1]) != data[len(data)-1] {
+		return ErrDDCChecksum
+	}
+	payload := data[2 : len(data)-1]
+	switch {
+	case len(payload) == 2 && payload[0] == vcpGet:
+		reply := []byte{ddcDisplay, 0x88, vcpGetReply, 1, payload[1], 0, 0, 0, 0, 0}
+		if payload[1] == VCPBrightness {
+			reply = []byte{ddcDisplay, 0x88, vcpGetReply, 0, VCPBrightness, 0, byte(m.Max >> 8), byte(m.Max), byte(m.Brightness >> 8), byte(m.Brightness)}
+		}
+		m.reply = append(reply, checksum(ddcReplyChecksum, reply))
+	case len(payload) == 4 && payload[0] == vcpSet && payload[1] == VCPBrightness:
+		v := uint16(payload[2])<<8 | uint16(payload[3])
+		if v > m.Max {
+			v = m.Max
+		}
+		m.Brightness = v
+		m.sets = append(m.sets, v)
+	}
+	return nil
+}
+
+// Read sends the reply of the last request, or the null message when there is none or when it is not ready.
+func (m *FakeMonitor) Read(buf []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return ErrClosed
+	}
+	reply := []byte{ddcDisplay, 0x80, checksum(ddcReplyChecksum, []byte{ddcDisplay, 0x80})}
+	if m.reply != nil && time.Since(m.written) >= m.Delay {
+		reply, m.reply = m.reply, nil
+		if m.Corrupt > 0 {
+			m.Corrupt--
+			reply[len(reply)-1] ^= 0xff
+		}
+	}
+	for i := range buf {
+		buf[i] = 0
+	}
+	copy(buf, reply)
+	return nil
+}
+
+// Close closes the monitor, its reads and writes return ErrClosed afterwards.
+func (m *FakeMonitor) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+	return nil
+}
+
+// Sets returns the values of VCPBrightness set on the monitor, in order.
+func (m *FakeMonitor) Sets() []uint16 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]uint16(nil), m.sets...)
+}
@@ -0,0 +1,42 @@
+package backlight
+
+import (
+	"os"
+	"syscall"
+)
+
+// i2cSlave is the ioctl of i2c-dev binding the file to the address of a device of the bus.
+const i2cSlave = 0x0703
+
+// i2cDev is an I2C bus of i2c-dev, like /dev/i2c-4.
+type i2cDev struct {
+	f *os.File
+}
+
+// OpenI2C opens the I2C bus device at path, bound to the DDC/CI address of the monitors.
+// It needs the i2c-dev module, and the permission to open the device, like the users of the i2c group.
+func OpenI2C(path string) (I2C, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), i2cSlave, ddcAddress); errno != 0 {
+		f.Close()
+		return nil, &os.PathError{Op: "ioctl", Path: path, Err: errno}
+	}
+	return &i2cDev{f: f}, nil
+}
+
+func (b *i2cDev) Write(data []byte) error {
+	_, err := b.f.Write(data)
+	return err
+}
+
+func (b *i2cDev) Read(buf []byte) error {
+	_, err := b.f.Read(buf)
+	return err
+}
+
+func (b *i2cDev) Close() error {
+	return b.f.Close()
+}
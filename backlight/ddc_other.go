@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package backlight
+
+import "errors"
+
+// OpenI2C returns an error, i2c-dev is only available on Linux.
+func OpenI2C(path string) (I2C, error) {
+	return nil, errors.New("Error I2C buses are only supported on Linux")
+}
@@ -0,0 +1,236 @@
+package backlight
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+// makeConnector creates a drm connector in root, with a ddc link to its I2C bus when bus is not empty.
+func makeConnector(c *C, root string, name string, status string, bus string) {
+	path := makeSensor(c, root, name, map[string]string{"status": status})
+	if bus == "" {
+		return
+	}
+	if err := os.Symlink(filepath.Join("..", "..", "i2c", bus), filepath.Join(path, "ddc")); err != nil {
+		c.Fatal(err)
+	}
+}
+
+// fakeDDC returns a monitor at half brightness, and its backend with a short delay.
+func fakeDDC() (*FakeMonitor, *DDC) {
+	m := &FakeMonitor{Brightness: 50, Max: 100}
+	return m, &DDC{Bus: m, Delay: time.Millisecond}
+}
+
+func (s *BacklightSuite) TestDisplaysOk(c *C) {
+	root := c.MkDir()
+	makeConnector(c, root, "card0-eDP-1", "connected", "i2c-3")
+	makeConnector(c, root, "card0-DP-1", "connected", "i2c-4")
+	makeConnector(c, root, "card0-DP-2", "disconnected", "i2c-5")
+	makeConnector(c, root, "card0-HDMI-A-1", "connected", "")
+	makeSensor(c, root, "version", map[string]string{})
+
+	displays, err := Displays(root)
+	c.Assert(err, IsNil)
+	c.Assert(displays, DeepEquals, []Display{
+		{Name: "DP-1", Connector: "card0-DP-1", Bus: "/dev/i2c-4"},
+		{Name: "eDP-1", Connector: "card0-eDP-1", Bus: "/dev/i2c-3"},
+	})
+
+	name, err := ClassDDC.Discover(root)
+	c.Assert(err, IsNil)
+	c.Assert(name, Equals, "DP-1")
+	_, err = ClassDDC.Discover(c.MkDir())
+	c.Assert(err, Equals, ErrNoDisplay)
+}
+
+func (s *BacklightSuite) TestDDCRequestOk(c *C) {
+	// the request of ddcutil getvcp 10
+	c.Assert(ddcRequest(vcpGet, VCPBrightness), DeepEquals, []byte{0x51, 0x82, 0x01, 0x10, 0xac})
+	c.Assert(ddcRequest(vcpSet, VCPBrightness, 0, 40), DeepEquals, []byte{0x51, 0x84, 0x03, 0x10, 0x00, 0x28, 0x80})
+}
+
+func (s *BacklightSuite) TestParseVCPReplyOk(c *C) {
+	reply := []byte{0x6e, 0x88, 0x02, 0x00, 0x10, 0x00, 0x00, 0x64, 0x00, 0x32}
+	current, max, err := parseVCPReply(append(reply, checksum(ddcReplyChecksum, reply)), VCPBrightness)
+	c.Assert(err, IsNil)
+	c.Assert(current, Equals, uint16(50))
+	c.Assert(max, Equals, uint16(100))
+}
+
+func (s *BacklightSuite) TestParseVCPReplyKo(c *C) {
+	reply := []byte{0x6e, 0x88, 0x02, 0x00, 0x10, 0x00, 0x00, 0x64, 0x00, 0x32}
+	sum := checksum(ddcReplyChecksum, reply)
+	unsupported := []byte{0x6e, 0x88, 0x02, 0x01, 0x10, 0, 0, 0, 0, 0}
+	for _, t := range []struct {
+		reply []byte
+		err   error
+	}{
+		{append(append([]byte{}, reply...), sum^1), ErrDDCChecksum},
+		{[]byte{0x6e, 0x80, 0xbe}, ErrDDCNull},
+		{[]byte{0x6f, 0x80, 0xbf}, ErrDDCReply},
+		{[]byte{0x6e, 0x88, 0x02}, ErrDDCReply},
+		{append(unsupported, checksum(ddcReplyChecksum, unsupported)), ErrVCPUnsupported},
+	} {
+		_, _, err := parseVCPReply(t.reply, VCPBrightness)
+		c.Assert(err, Equals, t.err, Commentf("reply % x", t.reply))
+	}
+	_, _, err := parseVCPReply(append(append([]byte{}, reply...), sum), 0x12)
+	c.Assert(err, Equals, ErrDDCReply)
+}
+
+func (s *BacklightSuite) TestDDCOk(c *C) {
+	m, b := fakeDDC()
+	d, err := NewDevice("DP-1", b)
+	c.Assert(err, IsNil)
+	c.Assert(d.Get(), Equals, 50)
+	c.Assert(d.MaxBrightness, Equals, int64(100))
+	c.Assert(d.Writable(), Equals, true)
+
+	c.Assert(d.Set(40), IsNil)
+	c.Assert(d.Inc(25), IsNil)
+	c.Assert(m.Sets(), DeepEquals, []uint16{40, 65})
+	c.Assert(d.Load(), IsNil)
+	c.Assert(d.Get(), Equals, 65)
+	c.Assert(d.Close(), IsNil)
+	c.Assert(d.Load(), Equals, ErrClosed)
+}
+
+func (s *BacklightSuite) TestDDCRetryOk(c *C) {
+	m, b := fakeDDC()
+	m.Corrupt = DefaultDDCRetries
+	r, err := b.Read()
+	c.Assert(err, IsNil)
+	c.Assert(r.Actual, Equals, int64(50))
+
+	m.Corrupt = DefaultDDCRetries + 1
+	_, err = b.Read()
+	c.Assert(err, Equals, ErrDDCChecksum)
+}
+
+func (s *BacklightSuite) TestDDCTimingOk(c *C) {
+	// the monitor has no reply ready before its delay
+	m, b := fakeDDC()
+	m.Delay = 20 * time.Millisecond
+	_, err := b.Read()
+	c.Assert(err, Equals, ErrDDCNull)
+
+	b.Delay = 25 * time.Millisecond
+	start := time.Now()
+	r, err := b.Read()
+	c.Assert(err, IsNil)
+	c.Assert(r.Max, Equals, int64(100))
+
+	// the requests are spaced by the delay
+	c.Assert(b.Write(10), IsNil)
+	c.Assert(b.Write(20), IsNil)
+	c.Assert(time.Since(start) >= 3*b.Delay, Equals, true)
+	c.Assert(m.Sets(), DeepEquals, []uint16{10, 20})
+}
+
+func (s *BacklightSuite) TestDDCFadeOk(c *C) {
+	m, b := fakeDDC()
+	b.Delay = 50 * time.Millisecond
+	d, err := NewDevice("DP-1", b)
+	c.Assert(err, IsNil)
+	d.Fade = Fade{Duration: 250 * time.Millisecond, Interval: time.Millisecond}
+
+	done := make(chan error, 1)
+	go func() { done <- d.Set(90) }()
+	// the device is not held while the fade waits for the monitor
+	var longest time.Duration
+	for i := 0; i < 20; i++ {
+		start := time.Now()
+		d.Get()
+		if elapsed := time.Since(start); elapsed > longest {
+			longest = elapsed
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	c.Assert(<-done, IsNil)
+	c.Assert(longest < b.Delay/2, Equals, true, Commentf("Get waited %s", longest))
+	// the frames are spaced by the delay of the monitor, not by the interval
+	sets := m.Sets()
+	c.Assert(len(sets) <= 6, Equals, true, Commentf("sets %v", sets))
+	c.Assert(sets[len(sets)-1], Equals, uint16(90))
+}
+
+func (s *BacklightSuite) TestDDCKo(c *C) {
+	_, b := fakeDDC()
+	_, _, err := b.get(0x12)
+	c.Assert(err, Equals, ErrVCPUnsupported)
+	c.Assert(b.Write(-1), Equals, ErrRange)
+	c.Assert(b.Write(0x10000), Equals, ErrRange)
+
+	m := &FakeMonitor{Max: 100}
+	c.Assert(m.Write([]byte{0x51, 0x82, 0x01, 0x10, 0x00}), Equals, ErrDDCChecksum)
+}
+
+func (s *BacklightSuite) TestOpenDDCOk(c *C) {
+	root := c.MkDir()
+	makeConnector(c, root, "card0-DP-1", "connected", "i2c-4")
+	makeConnector(c, root, "card1-DP-2", "connected", "i2c-7")
+	var buses []string
+	open := func(bus string) (I2C, error) {
+		buses = append(buses, bus)
+		return &FakeMonitor{Brightness: 30, Max: 100}, nil
+	}
+
+	d, err := OpenDDC(root, "DP-2", open)
+	c.Assert(err, IsNil)
+	c.Assert(d.Name, Equals, "DP-2")
+	c.Assert(d.Info().Class, Equals, ClassDDC)
+	c.Assert(d.Get(), Equals, 30)
+	c.Assert(buses, DeepEquals, []string{"/dev/i2c-7"})
+	// the monitors may go down to 0 without the floor
+	lower, _ := d.Limits()
+	c.Assert(lower, Equals, int64(0))
+
+	devices, err := DDCDevices(root, open)
+	c.Assert(err, IsNil)
+	c.Assert(devices, HasLen, 2)
+	c.Assert(devices[0].Name, Equals, "DP-1")
+
+	_, err = OpenDDC(root, "HDMI-A-1", open)
+	c.Assert(err, Equals, ErrNoDisplay)
+}
+
+func (s *BacklightSuite) TestOpenDDCKo(c *C) {
+	root := c.MkDir()
+	makeConnector(c, root, "card0-DP-1", "connected", "i2c-4")
+	var monitor *FakeMonitor
+	open := func(bus string) (I2C, error) {
+		monitor = &FakeMonitor{Brightness: 30, Max: 100, Corrupt: 100}
+		return monitor, nil
+	}
+
+	_, err := OpenDDC(root, Auto, open)
+	c.Assert(err, Equals, ErrDDCChecksum)
+	// the bus is closed
+	c.Assert(monitor.Write(ddcRequest(vcpGet, VCPBrightness)), Equals, ErrClosed)
+
+	// the monitors which don't answer are skipped by DDCDevices
+	devices, err := DDCDevices(root, open)
+	c.Assert(err, IsNil)
+	c.Assert(devices, HasLen, 0)
+}
+
+func (s *BacklightSuite) TestDDCWatchOk(c *C) {
+	_, b := fakeDDC()
+	probe := &probeBackend{Backend: b}
+	d, err := NewDevice("DP-1", probe)
+	c.Assert(err, IsNil)
+	d.Class = ClassDDC
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	events := make(chan Event, 16)
+	c.Assert(d.Watch(ctx, time.Millisecond, events), Equals, context.DeadlineExceeded)
+	// the monitor is polled every DDCWatchInterval, not every millisecond
+	c.Assert(probe.Reads(), Equals, 2)
+	c.Assert(len(events), Equals, 1)
+}
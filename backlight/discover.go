@@ -0,0 +1,65 @@
+package backlight
+
+import (
+	"math"
+)
+
+// typePriority ranks the kernel backlight types the way systemd-backlight does,
+// the lowest value is preferred.
+var typePriority = map[string]int{"firmware": 0, "platform": 1, "raw": 2}
+
+// Info describes a backlight device, as returned by Devices.
+// BlPower is nil when the device has no bl_power file, and HwChanged when it has no brightness_hw_changed value.
+// Channels and Intensities are the color channels of a multicolor LED, empty for the other devices.
+type Info struct {
+	Name             string   `json:"name"`
+	Class            Class    `json:"class"`
+	Type             string   `json:"type"`
+	Scale            string   `json:"scale"`
+	Brightness       int64    `json:"brightness"`
+	MaxBrightness    int64    `json:"max_brightness"`
+	ActualBrightness int64    `json:"actual_brightness"`
+	Percent          int      `json:"percent"`
+	BlPower          *int64   `json:"bl_power"`
+	HwChanged        *int64   `json:"brightness_hw_changed"`
+	Channels         []string `json:"multi_index,omitempty"`
+	Intensities      []int64  `json:"multi_intensity,omitempty"`
+	Writable         bool     `json:"writable"`
+}
+
+// Discover scans the sysfs root and returns the name of the preferred backlight device.
+// Devices are ranked on their type attribute : firmware first, then platform, then raw.
+// Devices of the same rank are ordered by name, so the choice is deterministic.
+// It returns ErrNoDevice when no folder of the root contains the driver files.
+func Discover(root string) (string, error) {
+	return ClassBacklight.Discover(root)
+}
+
+// Devices walks the sysfs root and returns the attributes of every backlight device, ordered by name.
+// Folders of the root which don't contain the driver files are skipped.
+// It returns an error when the driver files of a device could not be read.
+func Devices(root string) ([]Info, error) {
+	return ClassBacklight.Devices(root)
+}
+
+// Info returns the attributes of the device, as printed by the get, list and info actions.
+// Writable is the one of the last Load, so Info doesn't ask the Backend, like logind over D-Bus.
+func (d *Device) Info() Info {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return Info{
+		Name:             d.Name,
+		Class:            d.class(),
+		Type:             d.Type,
+		Scale:            d.Scale,
+		Brightness:       d.Brightness,
+		MaxBrightness:    d.MaxBrightness,
+		ActualBrightness: d.ActualBrightness,
+		Percent:          int(math.Round(d.percent(d.ActualBrightness))),
+		BlPower:          d.BlPower,
+		HwChanged:        d.HwChanged,
+		Channels:         append([]string(nil), d.Channels...),
+		Intensities:      append([]int64(nil), d.Intensities...),
+		Writable:         d.writable,
+	}
+}
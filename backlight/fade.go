@@ -0,0 +1,152 @@
+package backlight
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// DefaultFadeInterval is the frame interval of a Fade which doesn't set one, 50 frames per second.
+const DefaultFadeInterval = 20 * time.Millisecond
+
+// Easing maps the elapsed part of a transition, between 0 and 1, to the part of the brightness change to apply.
+type Easing func(t float64) float64
+
+var (
+	// EaseLinear changes the brightness at a constant speed.
+	EaseLinear Easing = func(t float64) float64 { return t }
+	// EaseInOut starts and ends the transition slowly.
+	EaseInOut Easing = func(t float64) float64 {
+		if t < 0.5 {
+			return 4 * t * t * t
+		}
+		return 1 - math.Pow(-2*t+2, 3)/2
+	}
+	// EaseExponential starts the transition very slowly, and speeds up to the end.
+	EaseExponential Easing = func(t float64) float64 {
+		if t <= 0 {
+			return 0
+		}
+		return math.Pow(2, 10*t-10)
+	}
+
+	// Easings maps the names of the easings to their function.
+	Easings = map[string]Easing{
+		"linear":      EaseLinear,
+		"ease-in-out": EaseInOut,
+		"exponential": EaseExponential,
+	}
+)
+
+// Fade configures the transitions of a Device.
+// With a zero Duration the target brightness is written at once.
+type Fade struct {
+	Duration time.Duration
+	Interval time.Duration
+	Easing   Easing
+}
+
+// steps returns the number of frames of the transition, at least one.
+func (f Fade) steps() int {
+	interval := f.Interval
+	if interval <= 0 {
+		interval = DefaultFadeInterval
+	}
+	if n := int(f.Duration / interval); n > 1 {
+		return n
+	}
+	return 1
+}
+
+// frames returns the brightness values written by the transition from one value to another.
+// The last frame is always the target, and consecutive duplicates are dropped.
+func (f Fade) frames(from int64, to int64) []int64 {
+	easing := f.Easing
+	if easing == nil {
+		easing = EaseLinear
+	}
+	steps := f.steps()
+	frames := make([]int64, 0, steps)
+	last := from
+	for i := 1; i <= steps; i++ {
+		v := to
+		if i < steps {
+			v = from + int64(math.Round(float64(to-from)*easing(float64(i)/float64(steps))))
+		}
+		if v != last {
+			frames = append(frames, v)
+			last = v
+		}
+	}
+	return frames
+}
+
+// pacer is a Backend which needs a delay between two writes, like a monitor over DDC/CI.
+// pace returns that delay, and the time left before the backend accepts the next write.
+type pacer interface {
+	pace() (time.Duration, time.Duration)
+}
+
+// fade writes the frames of the transition t from a value to its target, one per interval,
+// and at least one per delay of a paced backend, waiting for it without holding the device.
+// It stops when a newer write replaced t, and returns nil, or when ctx is done, leaving the brightness where it was,
+// and returns the context error.
+func (d *Device) fade(ctx context.Context, t *transition, from int64) error {
+	f := t.fade
+	if f.Interval <= 0 {
+		f.Interval = DefaultFadeInterval
+	}
+	b := d.backend()
+	if delay, _ := pace(b); f.Interval < delay {
+		f.Interval = delay
+	}
+	ticker := time.NewTicker(f.Interval)
+	defer ticker.Stop()
+
+	for _, v := range f.frames(from, t.target) {
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
+		}
+		ready(ctx, b)
+		d.mu.Lock()
+		if d.transition != t {
+			d.mu.Unlock()
+			return nil
+		}
+		err := ctx.Err()
+		if err == nil {
+			err = d.writeValue(v)
+		}
+		if err != nil {
+			d.transition = nil
+			d.mu.Unlock()
+			return err
+		}
+		d.mu.Unlock()
+	}
+	d.mu.Lock()
+	if d.transition == t {
+		d.transition = nil
+	}
+	d.mu.Unlock()
+	return nil
+}
+
+// ready waits until the backend b accepts the next write, or until ctx is done, so the device is not held meanwhile.
+func ready(ctx context.Context, b Backend) {
+	if _, wait := pace(b); wait > 0 {
+		select {
+		case <-ctx.Done():
+		case <-time.After(wait):
+		}
+	}
+}
+
+// pace returns the delay and the wait of the backend b, both zero when it is not a pacer.
+func pace(b Backend) (time.Duration, time.Duration) {
+	if p, ok := b.(pacer); ok {
+		return p.pace()
+	}
+	return 0, 0
+}
@@ -0,0 +1,79 @@
+package backlight
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *BacklightSuite) TestEasingsBoundsOk(c *C) {
+	for name, easing := range Easings {
+		c.Assert(easing(0), Equals, float64(0), Commentf("easing %s", name))
+		c.Assert(easing(1), Equals, float64(1), Commentf("easing %s", name))
+		c.Assert(easing(0.5) > 0 && easing(0.5) < 1, Equals, true, Commentf("easing %s", name))
+	}
+}
+
+func (s *BacklightSuite) TestFadeFramesLinearOk(c *C) {
+	f := Fade{Duration: 40 * time.Millisecond, Interval: 10 * time.Millisecond}
+
+	c.Assert(f.frames(0, 100), DeepEquals, []int64{25, 50, 75, 100})
+	c.Assert(f.frames(100, 20), DeepEquals, []int64{80, 60, 40, 20})
+}
+
+func (s *BacklightSuite) TestFadeFramesTargetOk(c *C) {
+	for name, easing := range Easings {
+		f := Fade{Duration: 300 * time.Millisecond, Interval: 7 * time.Millisecond, Easing: easing}
+		frames := f.frames(13, 977)
+
+		c.Assert(frames[len(frames)-1], Equals, int64(977), Commentf("easing %s", name))
+		for i := 1; i < len(frames); i++ {
+			c.Assert(frames[i] > frames[i-1], Equals, true, Commentf("easing %s", name))
+		}
+	}
+}
+
+func (s *BacklightSuite) TestFadeFramesDuplicatesOk(c *C) {
+	f := Fade{Duration: 100 * time.Millisecond, Interval: 10 * time.Millisecond}
+
+	c.Assert(f.frames(10, 12), DeepEquals, []int64{11, 12})
+	c.Assert(f.frames(10, 10), HasLen, 0)
+}
+
+func (s *BacklightSuite) TestFadeFramesShortOk(c *C) {
+	f := Fade{Duration: time.Millisecond}
+
+	c.Assert(f.frames(500, 250), DeepEquals, []int64{250})
+}
+
+func (s *BacklightSuite) TestSetFadeOk(c *C) {
+	d, err := Open(s.root, "intel_backlight")
+	if err != nil {
+		c.Fatal(err)
+	}
+	d.Fade = Fade{Duration: 5 * time.Millisecond, Interval: time.Millisecond, Easing: EaseInOut}
+
+	err = d.Set(25)
+	c.Assert(err, IsNil)
+	c.Assert(d.Brightness, Equals, int64(250))
+	c.Assert(readValue(c, filepath.Join(s.path, "brightness")), Equals, int64(250))
+}
+
+func (s *BacklightSuite) TestSetFadeCancelKo(c *C) {
+	d, err := Open(s.root, "intel_backlight")
+	if err != nil {
+		c.Fatal(err)
+	}
+	d.Fade = Fade{Duration: time.Second, Interval: 10 * time.Millisecond}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 35*time.Millisecond)
+	defer cancel()
+	err = d.SetContext(ctx, 25)
+
+	c.Assert(err, Equals, context.DeadlineExceeded)
+	v := readValue(c, filepath.Join(s.path, "brightness"))
+	c.Assert(v <= 500 && v > 250, Equals, true)
+	c.Assert(d.Brightness, Equals, v)
+}
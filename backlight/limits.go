@@ -0,0 +1,58 @@
+package backlight
+
+// Limits returns the range of raw values Set, Inc and Dec may write to the device.
+// The lower bound is the highest of Min and Floor, and at least 1 so the panel is never switched off,
+// or 0 for a LED, like a keyboard backlight, or an external monitor, which stays lit at 0.
+// AllowOff ignores Floor, and lets the lower bound go to 0 on every device.
+// The upper bound is the lowest of Max and Cap, where zero or a value above MaxBrightness stands for MaxBrightness.
+func (d *Device) Limits() (int64, int64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.limits()
+}
+
+func (d *Device) limits() (int64, int64) {
+	lower, upper := int64(1), d.MaxBrightness
+	if d.AllowOff || d.Class == ClassLEDs || d.Class == ClassDDC {
+		lower = 0
+	}
+	if !d.AllowOff && d.Floor > lower {
+		lower = d.Floor
+	}
+	if d.Min > lower {
+		lower = d.Min
+	}
+	if d.Max > 0 && d.Max < upper {
+		upper = d.Max
+	}
+	if d.Cap > 0 && d.Cap < upper {
+		upper = d.Cap
+	}
+	if lower > upper {
+		lower = upper
+	}
+	return lower, upper
+}
+
+// SetCap sets the Cap of the device to a percentage, through the Curve, or removes it when the percentage is 0.
+// It doesn't write the brightness, the Cap bounds the next writes.
+func (d *Device) SetCap(percent float64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.Cap = 0
+	if percent > 0 {
+		d.Cap = d.raw(percent)
+	}
+}
+
+// clamp returns the value moved into the Limits, and whether it had to be moved.
+func (d *Device) clamp(value int64) (int64, bool) {
+	lower, upper := d.limits()
+	switch {
+	case value < lower:
+		return lower, true
+	case value > upper:
+		return upper, true
+	}
+	return value, false
+}
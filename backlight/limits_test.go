@@ -0,0 +1,167 @@
+package backlight
+
+import (
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *BacklightSuite) TestLimitsOk(c *C) {
+	d := Device{MaxBrightness: 1000}
+	lower, upper := d.Limits()
+	c.Assert(lower, Equals, int64(1))
+	c.Assert(upper, Equals, int64(1000))
+
+	d = Device{MaxBrightness: 1000, Min: 50, Max: 900}
+	lower, upper = d.Limits()
+	c.Assert(lower, Equals, int64(50))
+	c.Assert(upper, Equals, int64(900))
+
+	d = Device{MaxBrightness: 1000, Min: 950, Max: 900}
+	lower, upper = d.Limits()
+	c.Assert(lower, Equals, int64(900))
+	c.Assert(upper, Equals, int64(900))
+
+	d = Device{MaxBrightness: 1000, Max: 5000}
+	_, upper = d.Limits()
+	c.Assert(upper, Equals, int64(1000))
+
+	d = Device{MaxBrightness: 1000, Max: 900, Cap: 600}
+	_, upper = d.Limits()
+	c.Assert(upper, Equals, int64(600))
+}
+
+func (s *BacklightSuite) TestSetCapOk(c *C) {
+	d := Device{MaxBrightness: 1000}
+	d.SetCap(40)
+	c.Assert(d.Cap, Equals, int64(400))
+	d.SetCap(0)
+	c.Assert(d.Cap, Equals, int64(0))
+}
+
+func (s *BacklightSuite) TestIncClampedOk(c *C) {
+	d, err := Open(s.root, "intel_backlight")
+	if err != nil {
+		c.Fatal(err)
+	}
+	d.ActualBrightness = 970
+
+	c.Assert(d.Inc(5), IsNil)
+	c.Assert(d.Clamped, Equals, true)
+	c.Assert(readValue(c, filepath.Join(s.path, "brightness")), Equals, int64(1000))
+
+	d.ActualBrightness = 500
+	c.Assert(d.Inc(5), IsNil)
+	c.Assert(d.Clamped, Equals, false)
+	c.Assert(readValue(c, filepath.Join(s.path, "brightness")), Equals, int64(550))
+}
+
+func (s *BacklightSuite) TestDecClampedOk(c *C) {
+	d, err := Open(s.root, "intel_backlight")
+	if err != nil {
+		c.Fatal(err)
+	}
+	d.ActualBrightness = 30
+
+	c.Assert(d.Dec(5), IsNil)
+	c.Assert(d.Clamped, Equals, true)
+	c.Assert(readValue(c, filepath.Join(s.path, "brightness")), Equals, int64(1))
+}
+
+func (s *BacklightSuite) TestSetClampedOk(c *C) {
+	d, err := Open(s.root, "intel_backlight")
+	if err != nil {
+		c.Fatal(err)
+	}
+	d.Min, d.Max = d.Raw(10), d.Raw(80)
+
+	c.Assert(d.Set(90), IsNil)
+	c.Assert(d.Clamped, Equals, true)
+	c.Assert(readValue(c, filepath.Join(s.path, "brightness")), Equals, int64(800))
+
+	c.Assert(d.Set(5), IsNil)
+	c.Assert(d.Clamped, Equals, true)
+	c.Assert(readValue(c, filepath.Join(s.path, "brightness")), Equals, int64(100))
+
+	c.Assert(d.Set(50), IsNil)
+	c.Assert(d.Clamped, Equals, false)
+	c.Assert(readValue(c, filepath.Join(s.path, "brightness")), Equals, int64(500))
+}
+
+func (s *BacklightSuite) TestSetNoMaxKo(c *C) {
+	// a device without a maximum brightness is not written, and says so
+	f := &Fake{}
+	d := Device{Name: "fake", Backend: f}
+	c.Assert(d.Set(50), Equals, ErrRange)
+	c.Assert(d.Inc(10), Equals, ErrRange)
+	c.Assert(f.Writes(), HasLen, 0)
+}
+
+func (s *BacklightSuite) TestLimitsFloorOk(c *C) {
+	d := Device{MaxBrightness: 1000, Floor: 30}
+	lower, _ := d.Limits()
+	c.Assert(lower, Equals, int64(30))
+
+	d = Device{MaxBrightness: 1000, Floor: 30, Min: 100}
+	lower, _ = d.Limits()
+	c.Assert(lower, Equals, int64(100))
+
+	d = Device{MaxBrightness: 1000, Floor: 30, AllowOff: true}
+	lower, _ = d.Limits()
+	c.Assert(lower, Equals, int64(0))
+}
+
+func (s *BacklightSuite) TestLimitsClassOk(c *C) {
+	for _, t := range []struct {
+		class Class
+		lower int64
+	}{
+		{ClassBacklight, 1},
+		{ClassLEDs, 0},
+		{ClassDDC, 0},
+	} {
+		// the implicit lower bound depends on the class, the floor applies to all of them
+		d := Device{Class: t.class, MaxBrightness: 1000}
+		lower, _ := d.Limits()
+		c.Assert(lower, Equals, t.lower, Commentf("class %s", t.class))
+
+		d = Device{Class: t.class, MaxBrightness: 1000, Floor: 30}
+		lower, _ = d.Limits()
+		c.Assert(lower, Equals, int64(30), Commentf("class %s", t.class))
+
+		d = Device{Class: t.class, MaxBrightness: 1000, Floor: 30, AllowOff: true}
+		lower, _ = d.Limits()
+		c.Assert(lower, Equals, int64(0), Commentf("class %s", t.class))
+	}
+}
+
+func (s *BacklightSuite) TestDecFloorOk(c *C) {
+	d, err := Open(s.root, "intel_backlight")
+	if err != nil {
+		c.Fatal(err)
+	}
+	d.Floor = 20
+	d.ActualBrightness = 40
+
+	c.Assert(d.Dec(5), IsNil)
+	c.Assert(d.Clamped, Equals, true)
+	c.Assert(readValue(c, filepath.Join(s.path, "brightness")), Equals, int64(20))
+
+	c.Assert(d.Set(1), IsNil)
+	c.Assert(d.Clamped, Equals, true)
+	c.Assert(readValue(c, filepath.Join(s.path, "brightness")), Equals, int64(20))
+}
+
+func (s *BacklightSuite) TestDecAllowOffOk(c *C) {
+	d, err := Open(s.root, "intel_backlight")
+	if err != nil {
+		c.Fatal(err)
+	}
+	d.Floor = 20
+	d.AllowOff = true
+	d.ActualBrightness = 40
+
+	c.Assert(d.Dec(5), IsNil)
+	c.Assert(d.Clamped, Equals, true)
+	c.Assert(readValue(c, filepath.Join(s.path, "brightness")), Equals, int64(0))
+}
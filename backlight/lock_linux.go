@@ -0,0 +1,23 @@
+package backlight
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockState takes the exclusive lock of a state file, with flock on a lock file beside it :
+// the state file itself is replaced on each save. The returned function releases the lock.
+func lockState(path string) (func(), error) {
+	f, err := os.OpenFile(path+".lock", os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
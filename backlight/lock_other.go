@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package backlight
+
+// lockState doesn't lock the state file, flock is only used on Linux.
+func lockState(path string) (func(), error) {
+	return func() {}, nil
+}
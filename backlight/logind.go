@@ -0,0 +1,213 @@
+package backlight
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	// DefaultSystemBus is the D-Bus address of the system bus, where logind listens.
+	DefaultSystemBus = "unix:path=/run/dbus/system_bus_socket"
+
+	// logindName is the name of logind on the bus.
+	logindName = "org.freedesktop.login1"
+	// logindSession is the object of the session of the caller, the one logind lets change the brightness.
+	logindSession = "/org/freedesktop/login1/session/auto"
+	// logindInterface is the interface of the sessions, with the SetBrightness method and the Active property.
+	logindInterface = "org.freedesktop.login1.Session"
+
+	// busTimeout bounds each call to the bus.
+	busTimeout = 5 * time.Second
+)
+
+// busConn is a connection to the bus, a *dbus.Conn, replaced by a fake in the tests.
+type busConn interface {
+	Object(dest string, path dbus.ObjectPath) dbus.BusObject
+	Close() error
+}
+
+// dialBus connects to the bus at address, authenticated with the uid of the process.
+func dialBus(address string) (busConn, error) {
+	conn, err := dbus.Connect(address)
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+// replyError is the error answered by logind, or by the bus on its behalf, with its name and its message.
+type replyError struct {
+	Name    string
+	Message string
+}
+
+func (e *replyError) Error() string {
+	if e.Message == "" {
+		return "Error " + e.Name
+	}
+	return "Error " + e.Name + " : " + e.Message
+}
+
+// Logind is a Backend writing the brightness through the SetBrightness method of the logind session of the user, over D-Bus,
+// when the write of its Backend is denied, like on a system without the udev rules of the video group.
+// Subsystem and Name are the class and the name of the device, and Address the D-Bus address of the system bus,
+// DefaultSystemBus when empty. The reads are the ones of its Backend, which may be nil to only call Active.
+//
+// logind only lets the active session change the brightness, the other ones get the error of the bus.
+// Whether the session is active is asked once and cached, then refreshed by the writes through logind and by Active,
+// so loading the device doesn't call the bus.
+type Logind struct {
+	Backend
+	Subsystem string
+	Name      string
+	Address   string
+
+	mu      sync.Mutex
+	conn    busConn
+	dial    func(address string) (busConn, error)
+	checked bool
+	active  bool
+}
+
+// Write writes the brightness with the Backend, then through logind when the Backend is denied.
+// The connection to the bus is kept for the next writes, like the steps of a fade.
+// It returns the error of logind when it refused the write, and the error of the Backend when the bus could not be reached.
+func (l *Logind) Write(raw int64) error {
+	denied := l.Backend.Write(raw)
+	if !os.IsPermission(denied) {
+		return denied
+	}
+	err := l.SetBrightness(raw)
+	if _, ok := err.(*replyError); err != nil && !ok {
+		return denied
+	}
+	return err
+}
+
+// SetBrightness sets the brightness through logind, without trying the Backend first.
+// It returns the error of the bus, like when the session of the user is not active.
+func (l *Logind) SetBrightness(raw int64) error {
+	if raw < 0 {
+		return ErrRange
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err := l.call(logindInterface+".SetBrightness", l.Subsystem, l.Name, uint32(raw))
+	// a refused write tells the session is not active anymore
+	l.checked, l.active = true, err == nil
+	return err
+}
+
+// Active asks logind whether the session of the user is active, so logind lets it change the brightness,
+// and caches the answer for Describe.
+// It returns false when the bus or logind can't be reached.
+func (l *Logind) Active() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.ask()
+}
+
+// ask asks logind whether the session is active, and caches the answer. It is called with mu held.
+func (l *Logind) ask() bool {
+	l.checked, l.active = true, false
+	body, err := l.call("org.freedesktop.DBus.Properties.Get", logindInterface, "Active")
+	if err != nil || len(body) != 1 {
+		return false
+	}
+	v, ok := body[0].(dbus.Variant)
+	if !ok {
+		return false
+	}
+	l.active, _ = v.Value().(bool)
+	return l.active
+}
+
+// cached returns whether the session is active, asking logind only the first time.
+func (l *Logind) cached() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.checked {
+		return l.ask()
+	}
+	return l.active
+}
+
+// call calls a method of the session of the user, and returns the body of the reply.
+// The bus is dialed on the first call, and again after the connection broke. It is called with mu held.
+func (l *Logind) call(method string, args ...interface{}) ([]interface{}, error) {
+	if l.conn == nil {
+		address, dial := l.Address, l.dial
+		if address == "" {
+			address = DefaultSystemBus
+		}
+		if dial == nil {
+			dial = dialBus
+		}
+		conn, err := dial(address)
+		if err != nil {
+			return nil, err
+		}
+		l.conn = conn
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), busTimeout)
+	defer cancel()
+	call := l.conn.Object(logindName, logindSession).CallWithContext(ctx, method, 0, args...)
+	if e, ok := call.Err.(dbus.Error); ok {
+		reply := &replyError{Name: e.Name}
+		if len(e.Body) > 0 {
+			reply.Message, _ = e.Body[0].(string)
+		}
+		return nil, reply
+	}
+	if call.Err != nil {
+		// the connection is broken, the next call dials again
+		l.conn.Close()
+		l.conn = nil
+		return nil, call.Err
+	}
+	return call.Body, nil
+}
+
+// Describe returns the attributes of the Backend, the device being writable through logind too
+// when the session of the user is active, as last known : the bus is only called the first time.
+func (l *Logind) Describe() Description {
+	desc := l.Backend.Describe()
+	if !desc.Writable {
+		desc.Writable = l.cached()
+	}
+	return desc
+}
+
+// ReadColor reads the channels of a multicolor LED with the Backend, the channels are empty when it has no colors.
+func (l *Logind) ReadColor() ([]string, []int64, error) {
+	if b, ok := l.Backend.(ColorBackend); ok {
+		return b.ReadColor()
+	}
+	return nil, nil, nil
+}
+
+// WriteColor writes the channels of a multicolor LED with the Backend, logind has no method for them.
+func (l *Logind) WriteColor(intensities []int64) error {
+	if b, ok := l.Backend.(ColorBackend); ok {
+		return b.WriteColor(intensities)
+	}
+	return ErrNoColor
+}
+
+// Close closes the connection to the bus, and the Backend.
+func (l *Logind) Close() error {
+	l.mu.Lock()
+	if l.conn != nil {
+		l.conn.Close()
+		l.conn = nil
+	}
+	l.mu.Unlock()
+	if l.Backend == nil {
+		return nil
+	}
+	return l.Backend.Close()
+}
@@ -0,0 +1,230 @@
+package backlight
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+	. "gopkg.in/check.v1"
+)
+
+// fakeBus is a connection to a bus serving the SetBrightness method and the Active property of the session of logind.
+// SetBrightness answers the error Denied when it is set, and records its arguments otherwise.
+// Broken fails the next call like a broken connection, and Down fails the dials.
+type fakeBus struct {
+	Denied string
+	Active bool
+	Broken bool
+	Down   bool
+
+	mu    sync.Mutex
+	calls [][]interface{}
+	dials int
+	gets  int
+}
+
+func (b *fakeBus) dial(address string) (busConn, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.Down {
+		return nil, errors.New("dial unix /run/dbus/system_bus_socket: connect: no such file or directory")
+	}
+	b.dials++
+	return b, nil
+}
+
+func (b *fakeBus) Object(dest string, path dbus.ObjectPath) dbus.BusObject {
+	return &fakeObject{bus: b, dest: dest, path: path}
+}
+
+func (b *fakeBus) Close() error {
+	return nil
+}
+
+func (b *fakeBus) Calls() ([][]interface{}, int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.calls, b.dials
+}
+
+// fakeObject is an object of a fakeBus, only its calls are implemented.
+type fakeObject struct {
+	dbus.BusObject
+	bus  *fakeBus
+	dest string
+	path dbus.ObjectPath
+}
+
+func (o *fakeObject) CallWithContext(ctx context.Context, method string, flags dbus.Flags, args ...interface{}) *dbus.Call {
+	b := o.bus
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	call := &dbus.Call{Destination: o.dest, Path: o.path, Method: method, Args: args}
+	switch {
+	case b.Broken:
+		b.Broken = false
+		call.Err = dbus.ErrClosed
+	case o.dest != logindName || o.path != logindSession:
+		call.Err = dbus.Error{Name: "org.freedesktop.DBus.Error.UnknownObject"}
+	case method == logindInterface+".SetBrightness" && b.Denied != "":
+		call.Err = dbus.Error{Name: "org.freedesktop.DBus.Error.AccessDenied", Body: []interface{}{b.Denied}}
+	case method == logindInterface+".SetBrightness":
+		b.calls = append(b.calls, args)
+	case method == "org.freedesktop.DBus.Properties.Get" && len(args) == 2 && args[0] == logindInterface && args[1] == "Active":
+		b.gets++
+		call.Body = []interface{}{dbus.MakeVariant(b.Active)}
+	default:
+		call.Err = dbus.Error{Name: "org.freedesktop.DBus.Error.UnknownMethod", Body: []interface{}{"Unknown method"}}
+	}
+	return call
+}
+
+// readOnly makes the brightness file of the suite read only, and skips the test when it is writable anyway.
+func (s *BacklightSuite) readOnly(c *C) {
+	if err := os.Chmod(filepath.Join(s.path, "brightness"), 0444); err != nil {
+		c.Fatal(err)
+	}
+	if canWrite(filepath.Join(s.path, "brightness")) {
+		c.Skip("the brightness file is writable anyway, like by root")
+	}
+}
+
+func (s *BacklightSuite) TestLogindOk(c *C) {
+	s.readOnly(c)
+	bus := &fakeBus{}
+	backend := &Logind{Backend: SysfsBacklight{Path: s.path}, Subsystem: "backlight", Name: "intel_backlight", dial: bus.dial}
+	d, err := NewDevice("intel_backlight", backend)
+	c.Assert(err, IsNil)
+	c.Assert(d.Set(40), IsNil)
+	c.Assert(d.Set(60), IsNil)
+	calls, dials := bus.Calls()
+	c.Assert(calls, DeepEquals, [][]interface{}{
+		{"backlight", "intel_backlight", uint32(d.Raw(40))},
+		{"backlight", "intel_backlight", uint32(d.Raw(60))},
+	})
+	c.Assert(d.Brightness, Equals, d.Raw(60))
+	// the connection is kept between the writes
+	c.Assert(dials, Equals, 1)
+	c.Assert(d.Close(), IsNil)
+}
+
+func (s *BacklightSuite) TestLogindWritableOk(c *C) {
+	bus := &fakeBus{Down: true}
+	backend := &Logind{Backend: SysfsBacklight{Path: s.path}, Subsystem: "backlight", Name: "intel_backlight", dial: bus.dial}
+	d, err := NewDevice("intel_backlight", backend)
+	c.Assert(err, IsNil)
+	c.Assert(d.Set(40), IsNil)
+	c.Assert(readValue(c, filepath.Join(s.path, "brightness")), Equals, d.Raw(40))
+	c.Assert(d.Info().Writable, Equals, true)
+}
+
+func (s *BacklightSuite) TestLogindDescribeOk(c *C) {
+	s.readOnly(c)
+	// the device is writable through logind in the active session only
+	bus := &fakeBus{Active: true}
+	backend := &Logind{Backend: SysfsBacklight{Path: s.path}, Subsystem: "backlight", Name: "intel_backlight", dial: bus.dial}
+	d, err := NewDevice("intel_backlight", backend)
+	c.Assert(err, IsNil)
+	c.Assert(d.Info().Writable, Equals, true)
+	c.Assert(d.Writable(), Equals, true)
+
+	bus.mu.Lock()
+	bus.Active = false
+	bus.mu.Unlock()
+	// the loads reuse the last answer of logind, Writable asks it again
+	for i := 0; i < 3; i++ {
+		c.Assert(d.Load(), IsNil)
+	}
+	c.Assert(d.Info().Writable, Equals, true)
+	bus.mu.Lock()
+	c.Assert(bus.gets, Equals, 2)
+	bus.mu.Unlock()
+	c.Assert(d.Writable(), Equals, false)
+	c.Assert(d.Load(), IsNil)
+	c.Assert(d.Info().Writable, Equals, false)
+
+	// a write refused by logind marks the device not writable, a write accepted writable again
+	bus.mu.Lock()
+	bus.Denied = "Not in active session"
+	bus.mu.Unlock()
+	backend = &Logind{Backend: SysfsBacklight{Path: s.path}, Subsystem: "backlight", Name: "intel_backlight", dial: bus.dial}
+	c.Assert(backend.Write(500), NotNil)
+	c.Assert(backend.Describe().Writable, Equals, false)
+	bus.mu.Lock()
+	bus.Denied = ""
+	bus.mu.Unlock()
+	c.Assert(backend.Write(500), IsNil)
+	c.Assert(backend.Describe().Writable, Equals, true)
+	bus.mu.Lock()
+	c.Assert(bus.gets, Equals, 3)
+	bus.mu.Unlock()
+
+	// and not without the bus
+	backend = &Logind{Backend: SysfsBacklight{Path: s.path}, dial: (&fakeBus{Down: true}).dial}
+	c.Assert(backend.Describe().Writable, Equals, false)
+	c.Assert((&Logind{dial: bus.dial}).Close(), IsNil)
+}
+
+func (s *BacklightSuite) TestLogindKo(c *C) {
+	bus := &fakeBus{Denied: "Not in active session"}
+	backend := &Logind{Backend: SysfsBacklight{Path: s.path}, Subsystem: "backlight", Name: "intel_backlight", dial: bus.dial}
+	err := backend.SetBrightness(500)
+	c.Assert(err, ErrorMatches, "Error org.freedesktop.DBus.Error.AccessDenied : Not in active session")
+	c.Assert(backend.SetBrightness(-1), Equals, ErrRange)
+
+	// a broken connection is dialed again on the next call
+	bus.mu.Lock()
+	bus.Denied, bus.Broken = "", true
+	bus.mu.Unlock()
+	c.Assert(backend.SetBrightness(500), Equals, dbus.ErrClosed)
+	c.Assert(backend.SetBrightness(500), IsNil)
+	calls, dials := bus.Calls()
+	c.Assert(calls, HasLen, 1)
+	c.Assert(dials, Equals, 2)
+}
+
+func (s *BacklightSuite) TestLogindDeniedKo(c *C) {
+	s.readOnly(c)
+	// the error of logind is returned when it refuses the write
+	bus := &fakeBus{Denied: "Not in active session"}
+	backend := &Logind{Backend: SysfsBacklight{Path: s.path}, Subsystem: "backlight", Name: "intel_backlight", dial: bus.dial}
+	c.Assert(backend.Write(500), ErrorMatches, ".*AccessDenied : Not in active session")
+
+	// and the error of the file when the bus is not running
+	backend = &Logind{Backend: SysfsBacklight{Path: s.path}, Subsystem: "backlight", Name: "intel_backlight", Address: "unix:path=/nonexistent"}
+	c.Assert(os.IsPermission(backend.Write(500)), Equals, true)
+}
+
+func (s *BacklightSuite) TestDBusDaemonOk(c *C) {
+	daemon, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		c.Skip("dbus-daemon is not installed")
+	}
+	// a private bus, with the configuration of the session buses
+	cmd := exec.Command(daemon, "--session", "--nofork", "--print-address")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		c.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		c.Skip("dbus-daemon doesn't start : " + err.Error())
+	}
+	defer cmd.Wait()
+	defer cmd.Process.Kill()
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		c.Skip("dbus-daemon doesn't start : " + err.Error())
+	}
+
+	// logind is not on the private bus
+	backend := &Logind{Backend: SysfsBacklight{Path: s.path}, Subsystem: "backlight", Name: "intel_backlight", Address: strings.TrimSpace(address)}
+	defer backend.Close()
+	c.Assert(backend.SetBrightness(500), ErrorMatches, "Error org.freedesktop.DBus.Error.ServiceUnknown : .*")
+	c.Assert(backend.Active(), Equals, false)
+}
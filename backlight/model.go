@@ -0,0 +1,182 @@
+package backlight
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// MaxSamples is the number of samples kept by a Model, the oldest ones are dropped.
+	MaxSamples = 500
+
+	// luxWidth is the width of the lux kernel, in natural logarithm of lux : a factor e of the illuminance.
+	luxWidth = 1.0
+	// hourWidth is the width of the time of day kernel, in hours.
+	hourWidth = 3.0
+	// powerWeight is the weight of the samples recorded on another power source.
+	powerWeight = 0.3
+	// halfLife is the age at which a sample counts half, so the model follows the changes of habits.
+	halfLife = 30 * 24 * time.Hour
+)
+
+// Conditions are the conditions of a brightness choice : the time, the power source,
+// and the illuminance in lux, nil when there is no ambient light sensor.
+type Conditions struct {
+	Time  time.Time   `json:"time"`
+	Lux   *float64    `json:"lux"`
+	Power PowerSource `json:"power"`
+}
+
+// Sample is a brightness percentage chosen by the user in some conditions.
+type Sample struct {
+	Conditions
+	Percent float64 `json:"percent"`
+}
+
+// Model learns the brightness preferred by the user from the samples of the manual changes.
+// It predicts the brightness of new conditions with the average of the samples, weighted by their closeness :
+// the closer the illuminance, on a logarithmic scale, and the time of day, the more a sample counts,
+// the samples of the same power source count more, and the older ones less.
+type Model struct {
+	Samples []Sample `json:"samples"`
+}
+
+// LoadModel reads the model saved in the state file, an empty model when the file doesn't exist yet.
+func LoadModel(path string) (*Model, error) {
+	m := &Model{Samples: []Sample{}}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Save writes the model to the state file, creating its folder.
+// The file is replaced at once, so a reader never sees it half written, and each writer writes its own temporary file.
+func (m *Model) Save(path string) error {
+	return saveState(path, m)
+}
+
+// saveState writes v as json to a state file, creating its folder, and replaces the file at once.
+func saveState(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// UpdateModel loads the model of the state file, changes it with update and saves it,
+// holding the lock of the state file so the concurrent updates and resets don't lose samples.
+func UpdateModel(path string, update func(m *Model)) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	unlock, err := lockState(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	m, err := LoadModel(path)
+	if err != nil {
+		return err
+	}
+	update(m)
+	return m.Save(path)
+}
+
+// ResetModel removes the state file, forgetting all the samples, while holding its lock.
+func ResetModel(path string) error {
+	if _, err := os.Stat(filepath.Dir(path)); os.IsNotExist(err) {
+		return nil
+	}
+	unlock, err := lockState(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Add records a sample, dropping the oldest ones beyond MaxSamples.
+func (m *Model) Add(s Sample) {
+	m.Samples = append(m.Samples, s)
+	if len(m.Samples) > MaxSamples {
+		m.Samples = m.Samples[len(m.Samples)-MaxSamples:]
+	}
+}
+
+// Predict returns the brightness percentage the user would choose in the conditions,
+// and false when the model has no samples yet.
+func (m *Model) Predict(c Conditions) (float64, bool) {
+	if len(m.Samples) == 0 {
+		return 0, false
+	}
+	var sum, total, recent, recentTotal float64
+	for _, s := range m.Samples {
+		age := c.Time.Sub(s.Time)
+		if age < 0 {
+			age = 0
+		}
+		w := math.Pow(0.5, float64(age)/float64(halfLife))
+		recent += w * s.Percent
+		recentTotal += w
+
+		if c.Power != s.Power {
+			w *= powerWeight
+		}
+		if c.Lux != nil && s.Lux != nil {
+			d := math.Log1p(*c.Lux) - math.Log1p(*s.Lux)
+			w *= math.Exp(-d * d / (2 * luxWidth * luxWidth))
+		}
+		d := hours(c.Time, s.Time)
+		w *= math.Exp(-d * d / (2 * hourWidth * hourWidth))
+		sum += w * s.Percent
+		total += w
+	}
+	// conditions far from all the samples fall back on the recent choices
+	if total < 1e-9 {
+		return recent / recentTotal, true
+	}
+	return sum / total, true
+}
+
+// hours returns the distance between the times of day of two times, in hours, at most 12.
+func hours(a time.Time, b time.Time) float64 {
+	ha := float64(a.Hour()) + float64(a.Minute())/60
+	hb := float64(b.Hour()) + float64(b.Minute())/60
+	d := math.Abs(ha - hb)
+	return math.Min(d, 24-d)
+}
@@ -0,0 +1,136 @@
+package backlight
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+// sample returns a sample of the percentage, chosen at the hour of the day, in lux, on the power source.
+func sample(hour int, lux float64, power PowerSource, percent float64) Sample {
+	return Sample{
+		Conditions: Conditions{Time: time.Date(2024, 3, 1, hour, 0, 0, 0, time.Local), Lux: &lux, Power: power},
+		Percent:    percent,
+	}
+}
+
+func (s *BacklightSuite) TestModelPredictEmpty(c *C) {
+	m := &Model{}
+	_, ok := m.Predict(Conditions{Time: time.Now()})
+	c.Assert(ok, Equals, false)
+}
+
+func (s *BacklightSuite) TestModelPredictLux(c *C) {
+	m := &Model{}
+	m.Add(sample(12, 5, PowerAC, 10))
+	m.Add(sample(12, 10000, PowerAC, 90))
+
+	dark := sample(12, 8, PowerAC, 0).Conditions
+	p, ok := m.Predict(dark)
+	c.Assert(ok, Equals, true)
+	c.Assert(p < 15, Equals, true, Commentf("%f", p))
+
+	bright := sample(12, 8000, PowerAC, 0).Conditions
+	p, _ = m.Predict(bright)
+	c.Assert(p > 85, Equals, true, Commentf("%f", p))
+
+	middle := sample(12, 245, PowerAC, 0).Conditions
+	p, _ = m.Predict(middle)
+	c.Assert(p > 40 && p < 60, Equals, true, Commentf("%f", p))
+}
+
+func (s *BacklightSuite) TestModelPredictTimeAndPower(c *C) {
+	m := &Model{}
+	m.Add(Sample{Conditions: Conditions{Time: time.Date(2024, 3, 1, 23, 0, 0, 0, time.Local), Power: PowerAC}, Percent: 20})
+	m.Add(Sample{Conditions: Conditions{Time: time.Date(2024, 3, 1, 14, 0, 0, 0, time.Local), Power: PowerAC}, Percent: 80})
+	m.Add(Sample{Conditions: Conditions{Time: time.Date(2024, 3, 1, 14, 0, 0, 0, time.Local), Power: PowerBattery}, Percent: 40})
+
+	// no sensor, the hour across midnight is close to the night sample
+	p, _ := m.Predict(Conditions{Time: time.Date(2024, 3, 2, 1, 0, 0, 0, time.Local), Power: PowerAC})
+	c.Assert(p < 25, Equals, true, Commentf("%f", p))
+
+	p, _ = m.Predict(Conditions{Time: time.Date(2024, 3, 2, 14, 0, 0, 0, time.Local), Power: PowerBattery})
+	c.Assert(p > 40 && p < 60, Equals, true, Commentf("%f", p))
+}
+
+func (s *BacklightSuite) TestModelPredictRecent(c *C) {
+	m := &Model{}
+	m.Add(sample(12, 100, PowerAC, 30))
+	recent := sample(12, 100, PowerAC, 70)
+	recent.Time = recent.Time.Add(365 * 24 * time.Hour)
+	m.Add(recent)
+
+	p, _ := m.Predict(recent.Conditions)
+	c.Assert(p > 69, Equals, true, Commentf("%f", p))
+}
+
+func (s *BacklightSuite) TestModelAddMaxSamples(c *C) {
+	m := &Model{}
+	for i := 0; i < MaxSamples+10; i++ {
+		m.Add(sample(12, 100, PowerAC, float64(i%100)))
+	}
+	c.Assert(m.Samples, HasLen, MaxSamples)
+	c.Assert(m.Samples[0].Percent, Equals, float64(10))
+}
+
+func (s *BacklightSuite) TestModelSaveLoadOk(c *C) {
+	path := filepath.Join(c.MkDir(), "gobacklight", "model.json")
+	m, err := LoadModel(path)
+	c.Assert(err, IsNil)
+	c.Assert(m.Samples, HasLen, 0)
+
+	m.Add(sample(8, 250, PowerBattery, 35))
+	m.Add(Sample{Conditions: Conditions{Time: time.Now(), Power: PowerAC}, Percent: 60})
+	c.Assert(m.Save(path), IsNil)
+
+	loaded, err := LoadModel(path)
+	c.Assert(err, IsNil)
+	c.Assert(loaded.Samples, HasLen, 2)
+	c.Assert(*loaded.Samples[0].Lux, Equals, float64(250))
+	c.Assert(loaded.Samples[0].Power, Equals, PowerBattery)
+	c.Assert(loaded.Samples[0].Time.Equal(m.Samples[0].Time), Equals, true)
+	c.Assert(loaded.Samples[1].Lux, IsNil)
+	c.Assert(loaded.Samples[1].Percent, Equals, float64(60))
+}
+
+func (s *BacklightSuite) TestModelUpdateOk(c *C) {
+	path := filepath.Join(c.MkDir(), "gobacklight", "model.json")
+	// the concurrent updates don't lose samples
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(percent float64) {
+			defer wg.Done()
+			c.Check(UpdateModel(path, func(m *Model) {
+				m.Add(Sample{Conditions: Conditions{Time: time.Now(), Power: PowerAC}, Percent: percent})
+			}), IsNil)
+		}(float64(i))
+	}
+	wg.Wait()
+	m, err := LoadModel(path)
+	c.Assert(err, IsNil)
+	c.Assert(m.Samples, HasLen, 20)
+	// and leave no temporary files
+	files, err := filepath.Glob(path + ".*.tmp")
+	c.Assert(err, IsNil)
+	c.Assert(files, HasLen, 0)
+
+	c.Assert(ResetModel(path), IsNil)
+	_, err = os.Stat(path)
+	c.Assert(os.IsNotExist(err), Equals, true)
+	c.Assert(ResetModel(path), IsNil)
+	c.Assert(ResetModel(filepath.Join(c.MkDir(), "missing", "model.json")), IsNil)
+}
+
+func (s *BacklightSuite) TestModelLoadKo(c *C) {
+	path := filepath.Join(c.MkDir(), "model.json")
+	if err := ioutil.WriteFile(path, []byte("{"), 0644); err != nil {
+		c.Fatal(err)
+	}
+	_, err := LoadModel(path)
+	c.Assert(err, NotNil)
+}
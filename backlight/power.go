@@ -0,0 +1,76 @@
+package backlight
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+)
+
+// DefaultPowerRoot is the sysfs folder of the kernel power_supply class.
+const DefaultPowerRoot = "/sys/class/power_supply/"
+
+// PowerSource is the source powering the system.
+type PowerSource string
+
+const (
+	// PowerAC is the mains, through an adapter.
+	PowerAC PowerSource = "ac"
+	// PowerBattery is the battery, no adapter being online.
+	PowerBattery PowerSource = "battery"
+)
+
+// ReadPowerSource scans the power_supply root and returns the source powering the system.
+// It is PowerAC when a Mains or USB supply is online, or when there is no battery, like on a desktop,
+// and PowerBattery otherwise.
+func ReadPowerSource(root string) (PowerSource, error) {
+	entries, err := ioutil.ReadDir(root)
+	if err != nil {
+		return "", err
+	}
+	battery := false
+	for _, e := range entries {
+		path := filepath.Join(root, e.Name())
+		switch readAttribute(path, "type") {
+		case "Mains", "USB":
+			if readAttribute(path, "online") == "1" {
+				return PowerAC, nil
+			}
+		case "Battery":
+			battery = true
+		}
+	}
+	if battery {
+		return PowerBattery, nil
+	}
+	return PowerAC, nil
+}
+
+// WatchPower sends the power source read from root on sources, first the current one, then each time it changes,
+// polling root every interval until ctx is done. It closes sources when it returns.
+// It returns ctx.Err() when ctx is done, or the error of a read.
+func WatchPower(ctx context.Context, root string, interval time.Duration, sources chan<- PowerSource) error {
+	defer close(sources)
+	var last PowerSource
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		source, err := ReadPowerSource(root)
+		if err != nil {
+			return err
+		}
+		if source != last {
+			select {
+			case sources <- source:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			last = source
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package backlight
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *BacklightSuite) TestReadPowerSourceOk(c *C) {
+	root := c.MkDir()
+	makeSensor(c, root, "BAT0", map[string]string{"type": "Battery", "capacity": "80"})
+	makeSensor(c, root, "AC", map[string]string{"type": "Mains", "online": "0"})
+	makeSensor(c, root, "ucsi-source-psy-USBC000:001", map[string]string{"type": "USB", "online": "0"})
+
+	power, err := ReadPowerSource(root)
+	c.Assert(err, IsNil)
+	c.Assert(power, Equals, PowerBattery)
+
+	overwrite(c, filepath.Join(root, "ucsi-source-psy-USBC000:001", "online"), "1")
+	power, err = ReadPowerSource(root)
+	c.Assert(err, IsNil)
+	c.Assert(power, Equals, PowerAC)
+}
+
+func (s *BacklightSuite) TestReadPowerSourceDesktopOk(c *C) {
+	power, err := ReadPowerSource(c.MkDir())
+	c.Assert(err, IsNil)
+	c.Assert(power, Equals, PowerAC)
+}
+
+func (s *BacklightSuite) TestReadPowerSourceKo(c *C) {
+	_, err := ReadPowerSource(filepath.Join(c.MkDir(), "missing"))
+	c.Assert(err, NotNil)
+}
+
+func (s *BacklightSuite) TestWatchPowerOk(c *C) {
+	root := c.MkDir()
+	makeSensor(c, root, "BAT0", map[string]string{"type": "Battery"})
+	makeSensor(c, root, "AC", map[string]string{"type": "Mains", "online": "1"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sources := make(chan PowerSource)
+	done := make(chan error, 1)
+	go func() { done <- WatchPower(ctx, root, 5*time.Millisecond, sources) }()
+
+	c.Assert(<-sources, Equals, PowerAC)
+	overwrite(c, filepath.Join(root, "AC", "online"), "0")
+	c.Assert(<-sources, Equals, PowerBattery)
+
+	cancel()
+	for range sources {
+	}
+	c.Assert(<-done, Equals, context.Canceled)
+}
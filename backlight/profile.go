@@ -0,0 +1,43 @@
+package backlight
+
+import "context"
+
+// Profile is the brightness of a power source : Target is applied when the system switches to the source,
+// nil for none, and Cap is the highest percentage reached on the source, 0 for none.
+type Profile struct {
+	Target *Value
+	Cap    float64
+}
+
+// Profiles are the profiles of the power sources, like a target of 100% on AC and a cap of 60% on battery.
+type Profiles map[PowerSource]Profile
+
+// Limit sets the Cap of the device to the cap of the power source, or removes it when the source has none.
+// It doesn't write the brightness, the Cap bounds the next writes.
+func (p Profiles) Limit(d *Device, source PowerSource) {
+	d.SetCap(p[source].Cap)
+}
+
+// Apply switches the device to the profile of the power source : it sets the Cap with Limit, then enters the profile with Enter.
+// It returns an error if the target is out of the device range, or if it could not write the brightness file.
+func (p Profiles) Apply(ctx context.Context, d *Device, source PowerSource) error {
+	p.Limit(d, source)
+	return p.Enter(ctx, d, source)
+}
+
+// Enter applies the Target of the power source, or moves the brightness below the Cap of the device when it is above it.
+// It doesn't change the Cap, so a lower one set after Limit, like for the battery level, is kept.
+// It returns an error if the target is out of the device range, or if it could not write the brightness file.
+func (p Profiles) Enter(ctx context.Context, d *Device, source PowerSource) error {
+	if target := p[source].Target; target != nil {
+		return d.ApplyContext(ctx, *target)
+	}
+	d.mu.Lock()
+	capped := d.Cap > 0
+	d.mu.Unlock()
+	if capped {
+		// a zero relative value moves the brightness into the limits, and leaves it alone otherwise
+		return d.ApplyContext(ctx, Value{Unit: Raw, Relative: true})
+	}
+	return nil
+}
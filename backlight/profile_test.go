@@ -0,0 +1,77 @@
+package backlight
+
+import (
+	"context"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *BacklightSuite) TestProfilesLimitOk(c *C) {
+	d, err := Open(s.root, "intel_backlight")
+	if err != nil {
+		c.Fatal(err)
+	}
+	profiles := Profiles{PowerBattery: {Cap: 60}}
+
+	profiles.Limit(d, PowerBattery)
+	c.Assert(d.Cap, Equals, int64(600))
+	c.Assert(readValue(c, filepath.Join(s.path, "brightness")), Equals, int64(500))
+
+	c.Assert(d.Set(80), IsNil)
+	c.Assert(d.Clamped, Equals, true)
+	c.Assert(readValue(c, filepath.Join(s.path, "brightness")), Equals, int64(600))
+
+	profiles.Limit(d, PowerAC)
+	c.Assert(d.Cap, Equals, int64(0))
+}
+
+func (s *BacklightSuite) TestProfilesApplyTargetOk(c *C) {
+	d, err := Open(s.root, "intel_backlight")
+	if err != nil {
+		c.Fatal(err)
+	}
+	full, dim := Value{Unit: Max}, Value{Unit: Percent, Amount: 40}
+	profiles := Profiles{PowerAC: {Target: &full}, PowerBattery: {Target: &dim, Cap: 60}}
+
+	c.Assert(profiles.Apply(context.Background(), d, PowerBattery), IsNil)
+	c.Assert(readValue(c, filepath.Join(s.path, "brightness")), Equals, int64(400))
+
+	c.Assert(profiles.Apply(context.Background(), d, PowerAC), IsNil)
+	c.Assert(readValue(c, filepath.Join(s.path, "brightness")), Equals, int64(1000))
+}
+
+func (s *BacklightSuite) TestProfilesApplyCapOk(c *C) {
+	d, err := Open(s.root, "intel_backlight")
+	if err != nil {
+		c.Fatal(err)
+	}
+	profiles := Profiles{PowerBattery: {Cap: 30}}
+
+	c.Assert(profiles.Apply(context.Background(), d, PowerAC), IsNil)
+	c.Assert(readValue(c, filepath.Join(s.path, "brightness")), Equals, int64(500))
+
+	c.Assert(profiles.Apply(context.Background(), d, PowerBattery), IsNil)
+	c.Assert(readValue(c, filepath.Join(s.path, "brightness")), Equals, int64(300))
+
+	// a higher cap doesn't raise the brightness back
+	profiles = Profiles{PowerBattery: {Cap: 80}}
+	c.Assert(profiles.Apply(context.Background(), d, PowerBattery), IsNil)
+	c.Assert(d.Clamped, Equals, false)
+	c.Assert(readValue(c, filepath.Join(s.path, "brightness")), Equals, int64(300))
+}
+
+func (s *BacklightSuite) TestProfilesEnterOk(c *C) {
+	d, err := Open(s.root, "intel_backlight")
+	if err != nil {
+		c.Fatal(err)
+	}
+	profiles := Profiles{PowerBattery: {Cap: 60}}
+
+	// the lower cap set after Limit is kept
+	profiles.Limit(d, PowerBattery)
+	d.SetCap(40)
+	c.Assert(profiles.Enter(context.Background(), d, PowerBattery), IsNil)
+	c.Assert(d.Cap, Equals, int64(400))
+	c.Assert(readValue(c, filepath.Join(s.path, "brightness")), Equals, int64(400))
+}
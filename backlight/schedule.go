@@ -0,0 +1,195 @@
+package backlight
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultScheduleInterval is the interval of a Scheduler when none is given.
+const DefaultScheduleInterval = time.Minute
+
+var (
+	// ErrSchedule is returned by ParseSchedule when the string is not a list of time=percent points.
+	ErrSchedule = errors.New("Error schedule must be like 07:00=80%,sunset-30m=50%,22:00=30%")
+	// ErrLocation is returned when a schedule has sunrise or sunset points without a location.
+	ErrLocation = errors.New("Error sunrise and sunset points need a latitude and a longitude")
+)
+
+// Clock tells the time, time.Now when nil, so the schedule can be run at any time in tests.
+type Clock func() time.Time
+
+// SchedulePoint is a point of a Schedule, the brightness percentage at a time of the day :
+// a fixed time, At after midnight, or the sunrise or sunset Event moved by the Offset.
+// Spec is the point as given, like 22:00 or sunset-30m.
+type SchedulePoint struct {
+	Spec    string
+	At      time.Duration
+	Event   string
+	Offset  time.Duration
+	Percent float64
+}
+
+// Schedule sets the brightness along the day, interpolating it linearly between its points,
+// across midnight too. The sunrise and sunset points need the Location.
+type Schedule struct {
+	Points   []SchedulePoint
+	Location *Location
+}
+
+// TimelineEntry is a point of a Schedule on a given day.
+type TimelineEntry struct {
+	Time    time.Time `json:"time"`
+	Point   string    `json:"point"`
+	Percent float64   `json:"percent"`
+}
+
+// ParseSchedule parses a schedule like 07:00=80%,sunset-30m=50%,22:00=30%, made of time=percent points.
+// A time is a fixed time of the day like 22:00, or sunrise or sunset, with an optional offset like +1h or -30m.
+// It returns ErrLocation when the schedule has sunrise or sunset points and the location is nil.
+func ParseSchedule(s string, location *Location) (Schedule, error) {
+	schedule := Schedule{Location: location}
+	for _, p := range strings.Split(s, ",") {
+		fields := strings.Split(strings.TrimSpace(p), "=")
+		if len(fields) != 2 {
+			return Schedule{}, ErrSchedule
+		}
+		point, err := parsePoint(strings.TrimSpace(fields[0]))
+		if err != nil {
+			return Schedule{}, err
+		}
+		percent, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(fields[1]), "%"), 64)
+		if err != nil || percent < 0 || percent > 100 {
+			return Schedule{}, ErrSchedule
+		}
+		point.Percent = percent
+		if point.Event != "" && location == nil {
+			return Schedule{}, ErrLocation
+		}
+		schedule.Points = append(schedule.Points, point)
+	}
+	return schedule, nil
+}
+
+// parsePoint parses the time of a point, like 22:00, sunrise or sunset-30m.
+func parsePoint(spec string) (SchedulePoint, error) {
+	point := SchedulePoint{Spec: spec}
+	for _, event := range []string{"sunrise", "sunset"} {
+		if !strings.HasPrefix(spec, event) {
+			continue
+		}
+		point.Event = event
+		if offset := strings.TrimPrefix(spec, event); offset != "" {
+			d, err := time.ParseDuration(offset)
+			if err != nil || (offset[0] != '+' && offset[0] != '-') {
+				return SchedulePoint{}, ErrSchedule
+			}
+			point.Offset = d
+		}
+		return point, nil
+	}
+	t, err := time.Parse("15:04", spec)
+	if err != nil {
+		return SchedulePoint{}, ErrSchedule
+	}
+	point.At = time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	return point, nil
+}
+
+// Timeline returns the points of the schedule on the day, in the time zone of day, ordered by time.
+// The sunrise and sunset points are missing on the days the sun doesn't rise or set.
+func (s Schedule) Timeline(day time.Time) []TimelineEntry {
+	y, m, d := day.Date()
+	var sunrise, sunset time.Time
+	sun := false
+	if s.Location != nil {
+		sunrise, sunset, sun = s.Location.Sun(day)
+	}
+	var timeline []TimelineEntry
+	for _, p := range s.Points {
+		var t time.Time
+		switch p.Event {
+		case "sunrise", "sunset":
+			if !sun {
+				continue
+			}
+			t = sunrise
+			if p.Event == "sunset" {
+				t = sunset
+			}
+			t = t.Add(p.Offset)
+		default:
+			// read on the clock, so 22:00 stays 22:00 on the days of a daylight saving change
+			t = time.Date(y, m, d, int(p.At/time.Hour), int(p.At%time.Hour/time.Minute), 0, 0, day.Location())
+		}
+		timeline = append(timeline, TimelineEntry{Time: t, Point: p.Spec, Percent: p.Percent})
+	}
+	sort.SliceStable(timeline, func(i, j int) bool { return timeline[i].Time.Before(timeline[j].Time) })
+	return timeline
+}
+
+// Percent returns the brightness percentage of the schedule at t, interpolated between the points around it,
+// those of the day before and the day after included. It returns false when the schedule has no point.
+func (s Schedule) Percent(t time.Time) (float64, bool) {
+	var timeline []TimelineEntry
+	for _, day := range []int{-1, 0, 1} {
+		timeline = append(timeline, s.Timeline(t.AddDate(0, 0, day))...)
+	}
+	if len(timeline) == 0 {
+		return 0, false
+	}
+	for i := 1; i < len(timeline); i++ {
+		from, to := timeline[i-1], timeline[i]
+		if t.Before(from.Time) || !t.Before(to.Time) {
+			continue
+		}
+		span := to.Time.Sub(from.Time)
+		if span <= 0 {
+			return to.Percent, true
+		}
+		ratio := float64(t.Sub(from.Time)) / float64(span)
+		return from.Percent + ratio*(to.Percent-from.Percent), true
+	}
+	// a single point, on polar days for instance
+	return timeline[len(timeline)-1].Percent, true
+}
+
+// Scheduler sets the brightness of the Schedule, every Interval, at the time told by the Clock.
+type Scheduler struct {
+	Schedule Schedule
+	Interval time.Duration
+	Clock    Clock
+}
+
+// Run applies the brightness of the schedule to the device until ctx is done, when it changes by a percent at least.
+// It returns ctx.Err() when ctx is done, or the error of a write.
+func (s *Scheduler) Run(ctx context.Context, d *Device) error {
+	interval := s.Interval
+	if interval <= 0 {
+		interval = DefaultScheduleInterval
+	}
+	clock := s.Clock
+	if clock == nil {
+		clock = time.Now
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	last := -1.0
+	for {
+		if percent, ok := s.Schedule.Percent(clock()); ok && (last < 0 || math.Abs(percent-last) >= 1) {
+			if err := d.ApplyContext(ctx, Value{Unit: Percent, Amount: percent}); err != nil {
+				return err
+			}
+			last = percent
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package backlight
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+var paris = &Location{48.8566, 2.3522}
+
+func (s *BacklightSuite) TestParseScheduleOk(c *C) {
+	schedule, err := ParseSchedule("07:00=80%, sunset-30m=50, sunrise+1h30m=60%,22:15=30%", paris)
+	c.Assert(err, IsNil)
+	c.Assert(schedule.Points, DeepEquals, []SchedulePoint{
+		{Spec: "07:00", At: 7 * time.Hour, Percent: 80},
+		{Spec: "sunset-30m", Event: "sunset", Offset: -30 * time.Minute, Percent: 50},
+		{Spec: "sunrise+1h30m", Event: "sunrise", Offset: 90 * time.Minute, Percent: 60},
+		{Spec: "22:15", At: 22*time.Hour + 15*time.Minute, Percent: 30},
+	})
+}
+
+func (s *BacklightSuite) TestParseScheduleKo(c *C) {
+	for _, schedule := range []string{"", "07:00", "07:00=", "7h=50%", "25:00=50%", "07:00=150%", "sunset30m=50%", "sunset+half=50%", "noon=50%"} {
+		_, err := ParseSchedule(schedule, paris)
+		c.Assert(err, Equals, ErrSchedule, Commentf(schedule))
+	}
+	_, err := ParseSchedule("sunset=50%", nil)
+	c.Assert(err, Equals, ErrLocation)
+}
+
+func (s *BacklightSuite) TestScheduleTimelineOk(c *C) {
+	schedule, err := ParseSchedule("22:00=30%,07:00=80%,sunset-30m=50%", paris)
+	if err != nil {
+		c.Fatal(err)
+	}
+	cest := time.FixedZone("CEST", 2*3600)
+	timeline := schedule.Timeline(time.Date(2024, 6, 21, 15, 0, 0, 0, cest))
+
+	c.Assert(timeline, HasLen, 3)
+	c.Assert(timeline[0].Point, Equals, "07:00")
+	c.Assert(timeline[0].Time.Equal(time.Date(2024, 6, 21, 7, 0, 0, 0, cest)), Equals, true)
+	c.Assert(timeline[1].Point, Equals, "sunset-30m")
+	near(c, timeline[1].Time, 21, 28)
+	c.Assert(timeline[2].Point, Equals, "22:00")
+}
+
+func (s *BacklightSuite) TestSchedulePercentOk(c *C) {
+	schedule, err := ParseSchedule("08:00=80%,20:00=40%", nil)
+	if err != nil {
+		c.Fatal(err)
+	}
+	at := func(hour int, minute int) float64 {
+		p, ok := schedule.Percent(time.Date(2024, 3, 1, hour, minute, 0, 0, time.UTC))
+		c.Assert(ok, Equals, true)
+		return p
+	}
+	c.Assert(at(8, 0), Equals, float64(80))
+	c.Assert(at(14, 0), Equals, float64(60))
+	c.Assert(at(20, 0), Equals, float64(40))
+	// across midnight, from 40% at 20:00 to 80% at 08:00
+	c.Assert(at(2, 0), Equals, float64(60))
+	c.Assert(at(23, 0), Equals, float64(50))
+
+	_, ok := Schedule{}.Percent(time.Now())
+	c.Assert(ok, Equals, false)
+
+	single, _ := ParseSchedule("12:00=70%", nil)
+	p, ok := single.Percent(time.Now())
+	c.Assert(ok, Equals, true)
+	c.Assert(p, Equals, float64(70))
+}
+
+func (s *BacklightSuite) TestSchedulerRunOk(c *C) {
+	d, err := Open(s.root, "intel_backlight")
+	if err != nil {
+		c.Fatal(err)
+	}
+	schedule, _ := ParseSchedule("08:00=80%,20:00=40%", nil)
+	scheduler := &Scheduler{
+		Schedule: schedule,
+		Interval: 5 * time.Millisecond,
+		Clock:    func() time.Time { return time.Date(2024, 3, 1, 14, 0, 0, 0, time.UTC) },
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	c.Assert(scheduler.Run(ctx, d), Equals, context.DeadlineExceeded)
+	c.Assert(readValue(c, filepath.Join(s.path, "brightness")), Equals, int64(600))
+}
@@ -0,0 +1,99 @@
+package backlight
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DefaultIIORoot is the sysfs folder of the kernel industrial I/O devices, where the ambient light sensors are.
+const DefaultIIORoot = "/sys/bus/iio/devices/"
+
+// ErrNoSensor is returned when no ambient light sensor could be found in the IIO root.
+var ErrNoSensor = errors.New("Error no ambient light sensor found")
+
+// Sensor is an ambient light sensor, an IIO device exposing the illuminance.
+type Sensor struct {
+	Name string
+	Path string
+}
+
+// sensorFiles are the files holding the illuminance, the processed one in lux first.
+var sensorFiles = [2]string{"in_illuminance_input", "in_illuminance_raw"}
+
+// isSensor reports whether the IIO device folder exposes the illuminance.
+func isSensor(path string) bool {
+	for _, f := range sensorFiles {
+		if readAttribute(path, f) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// DiscoverSensor scans the IIO root and returns the name of the first device exposing the illuminance, in name order.
+// It returns ErrNoSensor when there is none.
+func DiscoverSensor(root string) (string, error) {
+	entries, err := ioutil.ReadDir(root)
+	if err != nil {
+		return "", err
+	}
+	for _, e := range entries {
+		if isSensor(filepath.Join(root, e.Name())) {
+			return e.Name(), nil
+		}
+	}
+	return "", ErrNoSensor
+}
+
+// OpenSensor returns the sensor called name in the IIO root, like iio:device0.
+// When name is Auto, it uses DiscoverSensor to pick the sensor of the root.
+// It returns ErrNoSensor if the device doesn't expose the illuminance.
+func OpenSensor(root string, name string) (*Sensor, error) {
+	if name == Auto {
+		sensor, err := DiscoverSensor(root)
+		if err != nil {
+			return nil, err
+		}
+		name = sensor
+	}
+	s := &Sensor{Name: name, Path: filepath.Join(root, name)}
+	if !isSensor(s.Path) {
+		return nil, ErrNoSensor
+	}
+	return s, nil
+}
+
+// Lux returns the illuminance measured by the sensor, in lux.
+// It reads in_illuminance_input when the driver processes the value, and computes
+// (in_illuminance_raw + in_illuminance_offset) * in_illuminance_scale otherwise, without offset and scale when they are absent.
+func (s *Sensor) Lux() (float64, error) {
+	if v := readAttribute(s.Path, "in_illuminance_input"); v != "" {
+		return strconv.ParseFloat(v, 64)
+	}
+	raw, err := readFile(filepath.Join(s.Path, "in_illuminance_raw"))
+	if err != nil {
+		return 0, err
+	}
+	lux, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+	if err != nil {
+		return 0, err
+	}
+	if v := readAttribute(s.Path, "in_illuminance_offset"); v != "" {
+		offset, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return 0, err
+		}
+		lux += offset
+	}
+	if v := readAttribute(s.Path, "in_illuminance_scale"); v != "" {
+		scale, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return 0, err
+		}
+		lux *= scale
+	}
+	return lux, nil
+}
@@ -0,0 +1,79 @@
+package backlight
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+)
+
+// makeSensor creates a fake IIO device in root, with the given files and values.
+func makeSensor(c *C, root string, name string, files map[string]string) string {
+	path := filepath.Join(root, name)
+	if err := os.MkdirAll(path, 0755); err != nil {
+		c.Fatal(err)
+	}
+	for file, value := range files {
+		if err := ioutil.WriteFile(filepath.Join(path, file), []byte(value+"\n"), 0644); err != nil {
+			c.Fatal(err)
+		}
+	}
+	return path
+}
+
+func (s *BacklightSuite) TestOpenSensorAutoOk(c *C) {
+	root := c.MkDir()
+	makeSensor(c, root, "iio:device0", map[string]string{"in_accel_x_raw": "12"})
+	makeSensor(c, root, "iio:device1", map[string]string{"in_illuminance_raw": "40"})
+
+	sensor, err := OpenSensor(root, Auto)
+	c.Assert(err, IsNil)
+	c.Assert(sensor.Name, Equals, "iio:device1")
+
+	lux, err := sensor.Lux()
+	c.Assert(err, IsNil)
+	c.Assert(lux, Equals, float64(40))
+}
+
+func (s *BacklightSuite) TestOpenSensorKo(c *C) {
+	root := c.MkDir()
+	makeSensor(c, root, "iio:device0", map[string]string{"in_accel_x_raw": "12"})
+
+	_, err := OpenSensor(root, Auto)
+	c.Assert(err, Equals, ErrNoSensor)
+
+	_, err = OpenSensor(root, "iio:device0")
+	c.Assert(err, Equals, ErrNoSensor)
+}
+
+func (s *BacklightSuite) TestSensorLuxOk(c *C) {
+	root := c.MkDir()
+	makeSensor(c, root, "iio:device0", map[string]string{
+		"in_illuminance_raw":    "100",
+		"in_illuminance_offset": "-20",
+		"in_illuminance_scale":  "0.5",
+	})
+	makeSensor(c, root, "iio:device1", map[string]string{
+		"in_illuminance_input": "321.5",
+		"in_illuminance_raw":   "100",
+	})
+
+	for name, expected := range map[string]float64{"iio:device0": 40, "iio:device1": 321.5} {
+		sensor, err := OpenSensor(root, name)
+		c.Assert(err, IsNil)
+		lux, err := sensor.Lux()
+		c.Assert(err, IsNil)
+		c.Assert(lux, Equals, expected)
+	}
+}
+
+func (s *BacklightSuite) TestSensorLuxKo(c *C) {
+	root := c.MkDir()
+	makeSensor(c, root, "iio:device0", map[string]string{"in_illuminance_raw": "dark"})
+
+	sensor, err := OpenSensor(root, "iio:device0")
+	c.Assert(err, IsNil)
+	_, err = sensor.Lux()
+	c.Assert(err, Not(IsNil))
+}
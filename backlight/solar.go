@@ -0,0 +1,51 @@
+package backlight
+
+import (
+	"math"
+	"time"
+)
+
+// Location is a place on Earth, in degrees, north and east being positive.
+type Location struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// Sun returns the sunrise and the sunset of the day at the location, in the time zone of day.
+// They are computed offline with the sunrise equation, accurate to a minute or two.
+// It returns false when the sun doesn't rise or doesn't set that day, near the poles.
+func (l Location) Sun(day time.Time) (time.Time, time.Time, bool) {
+	const j2000 = 2451545.0
+	rad := math.Pi / 180
+
+	// the days since J2000 at noon of the civil date
+	y, m, dd := day.Date()
+	n := math.Round(julian(time.Date(y, m, dd, 12, 0, 0, 0, time.UTC)) - j2000)
+
+	mean := n - l.Longitude/360
+	anomaly := math.Mod(357.5291+0.98560028*mean, 360)
+	center := 1.9148*math.Sin(anomaly*rad) + 0.02*math.Sin(2*anomaly*rad) + 0.0003*math.Sin(3*anomaly*rad)
+	ecliptic := math.Mod(anomaly+center+180+102.9372, 360)
+	transit := j2000 + mean + 0.0053*math.Sin(anomaly*rad) - 0.0069*math.Sin(2*ecliptic*rad)
+	declination := math.Asin(math.Sin(ecliptic*rad) * math.Sin(23.4397*rad))
+
+	// the sun is at -0.833° at sunrise and sunset, with the refraction and its radius
+	cos := (math.Sin(-0.833*rad) - math.Sin(l.Latitude*rad)*math.Sin(declination)) /
+		(math.Cos(l.Latitude*rad) * math.Cos(declination))
+	if cos < -1 || cos > 1 {
+		return time.Time{}, time.Time{}, false
+	}
+	hour := math.Acos(cos) / rad
+	loc := day.Location()
+	return fromJulian(transit - hour/360).In(loc), fromJulian(transit + hour/360).In(loc), true
+}
+
+// julian returns the Julian date of t.
+func julian(t time.Time) float64 {
+	return float64(t.Unix())/86400 + 2440587.5
+}
+
+// fromJulian returns the time of a Julian date, to the second.
+func fromJulian(j float64) time.Time {
+	return time.Unix(int64(math.Round((j-2440587.5)*86400)), 0)
+}
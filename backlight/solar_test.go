@@ -0,0 +1,42 @@
+package backlight
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+// near checks that t is at most two minutes away from the hour and minute.
+func near(c *C, t time.Time, hour int, minute int) {
+	expected := time.Date(t.Year(), t.Month(), t.Day(), hour, minute, 0, 0, t.Location())
+	d := t.Sub(expected)
+	c.Assert(d < 2*time.Minute && d > -2*time.Minute, Equals, true, Commentf("%s instead of %02d:%02d", t.Format("15:04"), hour, minute))
+}
+
+func (s *BacklightSuite) TestLocationSunOk(c *C) {
+	paris := time.FixedZone("CEST", 2*3600)
+	sunrise, sunset, ok := Location{48.8566, 2.3522}.Sun(time.Date(2024, 6, 21, 0, 0, 0, 0, paris))
+	c.Assert(ok, Equals, true)
+	near(c, sunrise, 5, 47)
+	near(c, sunset, 21, 58)
+
+	newYork := time.FixedZone("EST", -5*3600)
+	sunrise, sunset, ok = Location{40.7128, -74.0060}.Sun(time.Date(2024, 12, 21, 0, 0, 0, 0, newYork))
+	c.Assert(ok, Equals, true)
+	near(c, sunrise, 7, 17)
+	near(c, sunset, 16, 32)
+
+	sydney := time.FixedZone("AEDT", 11*3600)
+	sunrise, sunset, ok = Location{-33.8688, 151.2093}.Sun(time.Date(2024, 3, 20, 0, 0, 0, 0, sydney))
+	c.Assert(ok, Equals, true)
+	near(c, sunrise, 6, 58)
+	near(c, sunset, 19, 7)
+}
+
+func (s *BacklightSuite) TestLocationSunPolar(c *C) {
+	svalbard := Location{78.22, 15.65}
+	_, _, ok := svalbard.Sun(time.Date(2024, 6, 21, 0, 0, 0, 0, time.UTC))
+	c.Assert(ok, Equals, false)
+	_, _, ok = svalbard.Sun(time.Date(2024, 12, 21, 0, 0, 0, 0, time.UTC))
+	c.Assert(ok, Equals, false)
+}
@@ -0,0 +1,80 @@
+package backlight
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+func readFile(file string) (string, error) {
+	value, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	return string(value), nil
+}
+
+func readInt(file string) (int64, error) {
+	value, err := readFile(file)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.Trim(value, "\n"), 10, 0)
+}
+
+func writeStringToFile(file string, value string) error {
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.WriteString(value); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+func canWrite(file string) bool {
+	f, err := os.OpenFile(file, os.O_WRONLY, 0644)
+	if err != nil {
+		return false
+	}
+	f.Close()
+	return true
+}
+
+func checkDevice(path string) ([]os.FileInfo, error) {
+	return checkFiles(path, driverFiles[:])
+}
+
+// checkFiles returns the driver files of the device folder, or ErrDriverFiles when one of them is missing.
+func checkFiles(path string, driver []string) ([]os.FileInfo, error) {
+	var result []os.FileInfo
+	files, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		for _, e := range driver {
+			if e == f.Name() {
+				result = append(result, f)
+			}
+		}
+	}
+
+	if len(result) == len(driver) {
+		return result, nil
+	}
+	return nil, ErrDriverFiles
+}
+
+// readAttribute returns the trimmed content of an optional attribute of a device, empty when it is absent.
+func readAttribute(path string, name string) string {
+	v, err := readFile(filepath.Join(path, name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(v)
+}
@@ -0,0 +1,60 @@
+package backlight
+
+import "context"
+
+// transition is the write in progress on a device, with the target it goes to.
+// A newer write replaces it : its remaining frames are not written, and the newer one starts from the last of them.
+type transition struct {
+	target int64
+	fade   Fade
+	cancel context.CancelFunc
+}
+
+// apply is the single path changing the brightness of the device, with the fade, or the Fade of the device when nil.
+// Values out of the Limits are clamped, and Clamped reports it.
+// It retargets the transition in progress, which returns nil to its caller.
+// It returns ErrRange when MaxBrightness is unknown, the device having no range to write in.
+func (d *Device) apply(ctx context.Context, v Value, fade *Fade) error {
+	ready(ctx, d.backend())
+	d.mu.Lock()
+	if d.MaxBrightness <= 0 {
+		d.mu.Unlock()
+		return ErrRange
+	}
+	value, err := d.target(v)
+	if err != nil {
+		d.mu.Unlock()
+		return err
+	}
+	from := d.ActualBrightness
+	if d.transition != nil {
+		d.transition.cancel()
+		d.transition, from = nil, d.Brightness
+	}
+	if fade == nil {
+		fade = &d.Fade
+	}
+	value, d.Clamped = d.clamp(value)
+	if fade.Duration <= 0 {
+		defer d.mu.Unlock()
+		return d.writeValue(value)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	t := &transition{target: value, fade: *fade, cancel: cancel}
+	d.transition = t
+	d.mu.Unlock()
+	return d.fade(ctx, t, from)
+}
+
+// LastWrite returns the raw value of the last write, or the target of the transition in progress,
+// and whether it was clamped to the Limits.
+func (d *Device) LastWrite() (int64, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.transition != nil {
+		return d.transition.target, d.Clamped
+	}
+	return d.Brightness, d.Clamped
+}
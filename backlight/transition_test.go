@@ -0,0 +1,115 @@
+package backlight
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+// waitBrightness waits until the brightness file of the device leaves the value, and returns the new one.
+// The file being truncated before each write of a fade, an empty read is skipped.
+func waitBrightness(c *C, file string, value int64) int64 {
+	for i := 0; i < 200; i++ {
+		if v, err := readInt(file); err == nil && v != value {
+			return v
+		}
+		time.Sleep(time.Millisecond)
+	}
+	c.Fatalf("brightness stayed at %d", value)
+	return value
+}
+
+func (s *BacklightSuite) TestApplyRetargetOk(c *C) {
+	d, err := Open(s.root, "intel_backlight")
+	if err != nil {
+		c.Fatal(err)
+	}
+	file := filepath.Join(s.path, "brightness")
+	d.Fade = Fade{Duration: time.Second, Interval: 5 * time.Millisecond}
+
+	done := make(chan error)
+	go func() { done <- d.Set(90) }()
+	waitBrightness(c, file, 500)
+
+	err = d.ApplyFade(context.Background(), Value{Unit: Percent, Amount: 10}, Fade{Duration: 20 * time.Millisecond, Interval: 5 * time.Millisecond})
+	c.Assert(err, IsNil)
+	// the first transition gave up as soon as it was retargeted
+	select {
+	case err := <-done:
+		c.Assert(err, IsNil)
+	case <-time.After(100 * time.Millisecond):
+		c.Fatal("the first transition is still running")
+	}
+	time.Sleep(20 * time.Millisecond)
+	c.Assert(readValue(c, file), Equals, int64(100))
+	c.Assert(d.Brightness, Equals, int64(100))
+}
+
+func (s *BacklightSuite) TestApplyRetargetInstantOk(c *C) {
+	d, err := Open(s.root, "intel_backlight")
+	if err != nil {
+		c.Fatal(err)
+	}
+	file := filepath.Join(s.path, "brightness")
+	d.Fade = Fade{Duration: time.Second, Interval: 5 * time.Millisecond}
+
+	done := make(chan error)
+	go func() { done <- d.Set(100) }()
+	waitBrightness(c, file, 500)
+
+	// an instant write stops the transition, which doesn't write its next frames
+	err = d.ApplyFade(context.Background(), Value{Unit: Raw, Amount: 300}, Fade{})
+	c.Assert(err, IsNil)
+	c.Assert(<-done, IsNil)
+	time.Sleep(20 * time.Millisecond)
+	c.Assert(readValue(c, file), Equals, int64(300))
+}
+
+func (s *BacklightSuite) TestIncRetargetOk(c *C) {
+	d, err := Open(s.root, "intel_backlight")
+	if err != nil {
+		c.Fatal(err)
+	}
+	d.Fade = Fade{Duration: 200 * time.Millisecond, Interval: 5 * time.Millisecond}
+
+	// holding the key : the increments add up to the target in progress, not to the current brightness
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.Check(d.Inc(2), IsNil)
+		}()
+	}
+	wg.Wait()
+
+	raw, clamped := d.LastWrite()
+	c.Assert(raw, Equals, int64(700))
+	c.Assert(clamped, Equals, false)
+	c.Assert(readValue(c, filepath.Join(s.path, "brightness")), Equals, int64(700))
+}
+
+func (s *BacklightSuite) TestLastWriteOk(c *C) {
+	d, err := Open(s.root, "intel_backlight")
+	if err != nil {
+		c.Fatal(err)
+	}
+	d.Fade = Fade{Duration: time.Second, Interval: 5 * time.Millisecond}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- d.ApplyContext(ctx, Value{Unit: Max}) }()
+	waitBrightness(c, filepath.Join(s.path, "brightness"), 500)
+
+	raw, clamped := d.LastWrite()
+	c.Assert(raw, Equals, int64(1000))
+	c.Assert(clamped, Equals, false)
+
+	cancel()
+	c.Assert(<-done, Equals, context.Canceled)
+	raw, _ = d.LastWrite()
+	c.Assert(raw, Equals, readValue(c, filepath.Join(s.path, "brightness")))
+}
@@ -0,0 +1,173 @@
+package backlight
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Unit is the unit of a Value.
+type Unit int
+
+const (
+	// Raw values are in the units of the brightness file.
+	Raw Unit = iota
+	// Percent values are percentages, converted to raw values through the Curve.
+	Percent
+	// Min stands for the lower bound of the Limits.
+	Min
+	// Max stands for the upper bound of the Limits.
+	Max
+)
+
+var (
+	// ErrValue is returned by ParseValue when the string is not a brightness value.
+	ErrValue = errors.New("Error value must be like 50%, +5%, -10%, 750, +100, 0.4, max or min")
+	// ErrRange is returned when an absolute value is out of the range of the device.
+	ErrRange = errors.New("Error value out of the device range")
+)
+
+// Value is a brightness value, absolute or relative to the current brightness.
+// Amount is a percentage for Percent values, and a raw value for Raw values.
+type Value struct {
+	Unit     Unit
+	Relative bool
+	Amount   float64
+}
+
+// ParseValue parses a brightness value, it understands :
+//   - 50% : a percentage,
+//   - +5% or -10% : a percentage added to the current one,
+//   - 750 : a raw value,
+//   - +100 or -100 : a raw value added to the current one,
+//   - 0.4 or +0.1 : a fraction, handled as the matching percentage,
+//   - max or min : the limits of the device.
+//
+// It returns ErrValue when the string is none of them, or ErrRange for a percentage above 100.
+func ParseValue(s string) (Value, error) {
+	switch s {
+	case "max":
+		return Value{Unit: Max}, nil
+	case "min":
+		return Value{Unit: Min}, nil
+	}
+	v := Value{Unit: Raw}
+	if strings.HasPrefix(s, "+") || strings.HasPrefix(s, "-") {
+		v.Relative = true
+	}
+	number := s
+	switch {
+	case strings.HasSuffix(s, "%"):
+		v.Unit = Percent
+		number = strings.TrimSuffix(s, "%")
+	case strings.Contains(s, "."):
+		v.Unit = Percent
+	}
+	amount, err := parseAmount(number, v.Unit)
+	if err != nil {
+		return Value{}, err
+	}
+	if v.Unit == Percent && !strings.HasSuffix(s, "%") {
+		if math.Abs(amount) > 1 {
+			return Value{}, ErrValue
+		}
+		amount *= 100
+	}
+	if !v.Relative && v.Unit == Percent && amount > 100 {
+		return Value{}, ErrRange
+	}
+	v.Amount = amount
+	return v, nil
+}
+
+// parseAmount parses the number of a value : an integer for a Raw value, and a decimal number for a Percent one,
+// without the exponents, the underscores or the hexadecimal digits the strconv functions accept.
+func parseAmount(number string, unit Unit) (float64, error) {
+	if unit == Raw {
+		raw, err := strconv.ParseInt(number, 10, 64)
+		if err != nil {
+			return 0, ErrValue
+		}
+		return float64(raw), nil
+	}
+	digits := strings.Replace(strings.TrimLeft(number, "+-"), ".", "", 1)
+	if digits == "" || strings.Trim(digits, "0123456789") != "" {
+		return 0, ErrValue
+	}
+	amount, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, ErrValue
+	}
+	return amount, nil
+}
+
+// Target returns the raw value the device would be set to by the value, before clamping to the Limits.
+// Relative values are added to the current brightness, percentages through the Curve,
+// or to the target of the transition in progress, so the writes in a row add up.
+// A relative percentage moves by at least one raw value, even when the Curve rounds it back to the current one.
+// It returns ErrRange when an absolute value is negative or above MaxBrightness.
+func (d *Device) Target(v Value) (int64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.target(v)
+}
+
+func (d *Device) target(v Value) (int64, error) {
+	base := d.ActualBrightness
+	if d.transition != nil {
+		base = d.transition.target
+	}
+	lower, upper := d.limits()
+	switch v.Unit {
+	case Min:
+		return lower, nil
+	case Max:
+		return upper, nil
+	case Percent:
+		if v.Relative {
+			return step(base, d.raw(d.percent(base)+v.Amount), v.Amount), nil
+		}
+		if v.Amount < 0 || v.Amount > 100 {
+			return 0, ErrRange
+		}
+		return d.raw(v.Amount), nil
+	}
+	if v.Relative {
+		return base + int64(v.Amount), nil
+	}
+	if v.Amount < 0 || int64(v.Amount) > d.MaxBrightness {
+		return 0, ErrRange
+	}
+	return int64(v.Amount), nil
+}
+
+// step returns the target of a relative percentage from base, moved by at least one raw value in the direction of amount :
+// on a small MaxBrightness, a step rounding back to base would never move.
+func step(base int64, target int64, amount float64) int64 {
+	switch {
+	case amount > 0 && target <= base:
+		return base + 1
+	case amount < 0 && target >= base:
+		return base - 1
+	}
+	return target
+}
+
+// Apply changes the brightness of the device to the value.
+// The target is clamped to the Limits, and Clamped reports it.
+// It returns an error if the value is out of the device range, or if it could not write the brightness file.
+func (d *Device) Apply(v Value) error {
+	return d.ApplyContext(context.Background(), v)
+}
+
+// ApplyContext is like Apply, and stops the transition when ctx is done.
+func (d *Device) ApplyContext(ctx context.Context, v Value) error {
+	return d.apply(ctx, v, nil)
+}
+
+// ApplyFade is like ApplyContext, with the fade instead of the Fade of the device.
+func (d *Device) ApplyFade(ctx context.Context, v Value, fade Fade) error {
+	return d.apply(ctx, v, &fade)
+}
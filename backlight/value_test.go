@@ -0,0 +1,123 @@
+package backlight
+
+import (
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *BacklightSuite) TestParseValueOk(c *C) {
+	tests := map[string]Value{
+		"50%":  {Unit: Percent, Amount: 50},
+		"+5%":  {Unit: Percent, Relative: true, Amount: 5},
+		"-10%": {Unit: Percent, Relative: true, Amount: -10},
+		"750":  {Unit: Raw, Amount: 750},
+		"+100": {Unit: Raw, Relative: true, Amount: 100},
+		"-100": {Unit: Raw, Relative: true, Amount: -100},
+		"0.4":  {Unit: Percent, Amount: 40},
+		"+0.1": {Unit: Percent, Relative: true, Amount: 10},
+		"1.0":  {Unit: Percent, Amount: 100},
+		"max":  {Unit: Max},
+		"min":  {Unit: Min},
+	}
+	for s, expected := range tests {
+		v, err := ParseValue(s)
+		c.Assert(err, IsNil, Commentf("value %s", s))
+		c.Assert(v, Equals, expected, Commentf("value %s", s))
+	}
+}
+
+func (s *BacklightSuite) TestParseValueKo(c *C) {
+	for _, s := range []string{
+		"", "+", "%", "abc", "5.5.5", "12.5", "1.5", "NaN", "Inf", "maximum", "50%%",
+		"1e3", "1_000", "+1e2", "0x10", "+-5", "1e1%", "5_0%", "0x1p-2", ".%", "--0.5",
+	} {
+		_, err := ParseValue(s)
+		c.Assert(err, Equals, ErrValue, Commentf("value %s", s))
+	}
+	_, err := ParseValue("150%")
+	c.Assert(err, Equals, ErrRange)
+}
+
+func (s *BacklightSuite) TestTargetOk(c *C) {
+	d := Device{ActualBrightness: 500, MaxBrightness: 1000, Curve: LinearCurve{}, Min: 100, Max: 900}
+	tests := map[string]int64{
+		"50%":  500,
+		"+5%":  550,
+		"-10%": 400,
+		"750":  750,
+		"+100": 600,
+		"-600": -100,
+		"0.4":  400,
+		"max":  900,
+		"min":  100,
+	}
+	for s, expected := range tests {
+		v, err := ParseValue(s)
+		if err != nil {
+			c.Fatal(err)
+		}
+		target, err := d.Target(v)
+		c.Assert(err, IsNil, Commentf("value %s", s))
+		c.Assert(target, Equals, expected, Commentf("value %s", s))
+	}
+}
+
+func (s *BacklightSuite) TestTargetKo(c *C) {
+	d := Device{ActualBrightness: 500, MaxBrightness: 1000}
+
+	_, err := d.Target(Value{Unit: Raw, Amount: 1001})
+	c.Assert(err, Equals, ErrRange)
+
+	_, err = d.Target(Value{Unit: Percent, Amount: -5})
+	c.Assert(err, Equals, ErrRange)
+}
+
+func (s *BacklightSuite) TestApplyOk(c *C) {
+	d, err := Open(s.root, "intel_backlight")
+	if err != nil {
+		c.Fatal(err)
+	}
+
+	c.Assert(d.Apply(Value{Unit: Raw, Relative: true, Amount: -600}), IsNil)
+	c.Assert(d.Clamped, Equals, true)
+	c.Assert(readValue(c, filepath.Join(s.path, "brightness")), Equals, int64(1))
+
+	c.Assert(d.Apply(Value{Unit: Max}), IsNil)
+	c.Assert(d.Clamped, Equals, false)
+	c.Assert(readValue(c, filepath.Join(s.path, "brightness")), Equals, int64(1000))
+}
+
+func (s *BacklightSuite) TestApplySmallMaxOk(c *C) {
+	fake := &Fake{Brightness: 1, Max: 15}
+	d, err := NewDevice("fake", fake)
+	if err != nil {
+		c.Fatal(err)
+	}
+	d.Curve = CIE1931Curve{}
+
+	// the steps rounding back to the current raw value still move by one
+	for i := 0; i < 3; i++ {
+		c.Assert(d.Inc(5), IsNil)
+	}
+	for i := 0; i < 3; i++ {
+		c.Assert(d.Dec(5), IsNil)
+	}
+	c.Assert(fake.Writes(), DeepEquals, []int64{2, 3, 4, 3, 2, 1})
+
+	// and stay in the limits
+	c.Assert(d.Dec(5), IsNil)
+	c.Assert(d.Clamped, Equals, true)
+	c.Assert(d.Brightness, Equals, int64(1))
+}
+
+func (s *BacklightSuite) TestApplyRangeKo(c *C) {
+	d, err := Open(s.root, "intel_backlight")
+	if err != nil {
+		c.Fatal(err)
+	}
+
+	c.Assert(d.Apply(Value{Unit: Raw, Amount: 1500}), Equals, ErrRange)
+	c.Assert(d.Set(150), Equals, ErrRange)
+	c.Assert(readValue(c, filepath.Join(s.path, "brightness")), Equals, int64(500))
+}
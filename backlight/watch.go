@@ -0,0 +1,122 @@
+package backlight
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// DefaultWatchInterval is the polling interval of Watch when none is given.
+	// Polling is needed because the kernel doesn't notify every change of actual_brightness, like the ones of the hotkeys.
+	DefaultWatchInterval = 250 * time.Millisecond
+	// DDCWatchInterval is the shortest polling interval of Watch for the monitors over DDC/CI :
+	// a read takes more than 100ms of traffic on the I2C bus, and the monitors don't notify their changes.
+	DDCWatchInterval = 5 * time.Second
+)
+
+// Source tells who changed the brightness of an Event.
+type Source string
+
+const (
+	// SourceInitial is the source of the first event of Watch, holding the brightness when it started.
+	SourceInitial Source = "initial"
+	// SourceSoftware is the source of the changes written to sysfs, by gobacklight or another tool.
+	SourceSoftware Source = "software"
+	// SourceHardware is the source of the changes made by the firmware, reported by brightness_hw_changed.
+	SourceHardware Source = "hardware"
+)
+
+// Event is a change of the brightness of a device, sent by Watch with the attributes of the device.
+type Event struct {
+	Time   time.Time `json:"time"`
+	Source Source    `json:"source"`
+	Info
+}
+
+// Watch sends an Event with the current attributes of the device, then one more each time actual_brightness changes,
+// or brightness for the LEDs.
+// A change comes from the hardware when the value of brightness_hw_changed changed with it, or when the kernel
+// notified brightness_hw_changed before it, even with the same value as the previous time, and from the software otherwise.
+// The notifications are read out before each read of the device, so the order of the wakes of the files doesn't matter.
+// The files of the device are watched with inotify when it is available, and polled every interval anyway,
+// at least DDCWatchInterval for a monitor.
+// Watch reloads the device, so the other users of the device see the changes too.
+// It closes events when it returns : with ctx.Err() when ctx is done, or with the error of a failed read.
+func (d *Device) Watch(ctx context.Context, interval time.Duration, events chan<- Event) error {
+	defer close(events)
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+	if d.class() == ClassDDC && interval < DDCWatchInterval {
+		interval = DDCWatchInterval
+	}
+	// wake stays nil when inotify is not available, or when the backend has no files like Fake, and the watch only polls.
+	var wake <-chan string
+	if files := d.backend().Describe().Files; len(files) > 0 {
+		wake, _ = notify(ctx, files...)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last, lastHw, source := int64(-1), hwChanged(d.Info()), SourceInitial
+	for {
+		// the wakes queued meanwhile are read out before the device, so the notification of the firmware
+		// tags the change it came with, even behind the wake of another file
+		if pending(wake) && source != SourceInitial {
+			source = SourceHardware
+		}
+		if err := d.Load(); err != nil {
+			return err
+		}
+		info := d.Info()
+		if hw := hwChanged(info); hw != lastHw {
+			lastHw = hw
+			if source != SourceInitial {
+				source = SourceHardware
+			}
+		}
+		if info.ActualBrightness != last {
+			last = info.ActualBrightness
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case events <- Event{Time: time.Now(), Source: source, Info: info}:
+			}
+			source = SourceSoftware
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		case file := <-wake:
+			if filepath.Base(file) == hwChangedFile {
+				source = SourceHardware
+			}
+		}
+	}
+}
+
+// pending reads out the files named by the wakes queued on wake, without waiting,
+// and reports whether brightness_hw_changed was one of them.
+func pending(wake <-chan string) bool {
+	hw := false
+	for {
+		select {
+		case file := <-wake:
+			if filepath.Base(file) == hwChangedFile {
+				hw = true
+			}
+		default:
+			return hw
+		}
+	}
+}
+
+// hwChanged returns the value of brightness_hw_changed of the device, -1 when it has none.
+func hwChanged(info Info) int64 {
+	if info.HwChanged == nil {
+		return -1
+	}
+	return *info.HwChanged
+}
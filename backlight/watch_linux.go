@@ -0,0 +1,54 @@
+package backlight
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// notify returns a channel receiving the name of each file modified, in the order of the modifications, using inotify.
+// A name is dropped when the channel is full, the caller reading the files again anyway.
+// The inotify instance is closed when ctx is done.
+func notify(ctx context.Context, files ...string) (<-chan string, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+	watches := map[int32]string{}
+	for _, file := range files {
+		// a write, or a notification of the kernel, is a single IN_MODIFY event
+		wd, err := syscall.InotifyAddWatch(fd, file, syscall.IN_MODIFY)
+		if err != nil {
+			syscall.Close(fd)
+			return nil, err
+		}
+		watches[int32(wd)] = file
+	}
+	// the file is non blocking, so Close unblocks the pending Read.
+	f := os.NewFile(uintptr(fd), "inotify")
+	go func() {
+		<-ctx.Done()
+		f.Close()
+	}()
+
+	wake := make(chan string, 16)
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, err := f.Read(buf)
+			if err != nil {
+				return
+			}
+			for i := 0; i+syscall.SizeofInotifyEvent <= n; {
+				e := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[i]))
+				select {
+				case wake <- watches[e.Wd]:
+				default:
+				}
+				i += syscall.SizeofInotifyEvent + int(e.Len)
+			}
+		}
+	}()
+	return wake, nil
+}
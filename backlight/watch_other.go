@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package backlight
+
+import (
+	"context"
+	"errors"
+)
+
+// notify is only implemented with inotify, the watch polls the files on other systems.
+func notify(ctx context.Context, files ...string) (<-chan string, error) {
+	return nil, errors.New("Error file notifications are not supported")
+}
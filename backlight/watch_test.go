@@ -0,0 +1,212 @@
+package backlight
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+// overwrite replaces the value of a file in place, like sysfs does, so a reader never sees it empty.
+// The value must have the length of the previous one.
+func overwrite(c *C, file string, value string) {
+	f, err := os.OpenFile(file, os.O_WRONLY, 0644)
+	if err != nil {
+		c.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(value + "\n"); err != nil {
+		c.Fatal(err)
+	}
+}
+
+// nextEvent returns the next event sent by Watch, it fails the test after a second.
+func nextEvent(c *C, events <-chan Event) Event {
+	select {
+	case e, ok := <-events:
+		c.Assert(ok, Equals, true)
+		return e
+	case <-time.After(time.Second):
+		c.Fatal("no event received")
+	}
+	return Event{}
+}
+
+func (s *BacklightSuite) TestWatchPollOk(c *C) {
+	d, err := Open(s.root, "intel_backlight")
+	if err != nil {
+		c.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	events := make(chan Event)
+	done := make(chan error)
+	go func() { done <- d.Watch(ctx, 5*time.Millisecond, events) }()
+
+	e := nextEvent(c, events)
+	c.Assert(e.ActualBrightness, Equals, int64(500))
+	c.Assert(e.Percent, Equals, 50)
+	c.Assert(e.Source, Equals, SourceInitial)
+	c.Assert(e.Time.IsZero(), Equals, false)
+
+	overwrite(c, filepath.Join(s.path, "actual_brightness"), "250")
+	e = nextEvent(c, events)
+	c.Assert(e.ActualBrightness, Equals, int64(250))
+	c.Assert(e.Percent, Equals, 25)
+	c.Assert(e.Source, Equals, SourceSoftware)
+
+	cancel()
+	c.Assert(<-done, Equals, context.Canceled)
+	_, ok := <-events
+	c.Assert(ok, Equals, false)
+}
+
+// notifyBackend is a Backend watching the brightness and brightness_hw_changed files only, like sysfs notifies them :
+// the changes of actual_brightness wake the watch through one of them, once per change.
+type notifyBackend struct {
+	Backend
+	path string
+}
+
+func (b notifyBackend) Describe() Description {
+	desc := b.Backend.Describe()
+	desc.Files = []string{filepath.Join(b.path, "brightness"), filepath.Join(b.path, hwChangedFile)}
+	return desc
+}
+
+func (s *BacklightSuite) TestWatchHwChangedOk(c *C) {
+	if runtime.GOOS != "linux" {
+		c.Skip("inotify is only available on linux")
+	}
+	if err := ioutil.WriteFile(filepath.Join(s.path, "brightness_hw_changed"), []byte("500\n"), 0644); err != nil {
+		c.Fatal(err)
+	}
+	d, err := NewDevice("intel_backlight", notifyBackend{SysfsBacklight{Path: s.path}, s.path})
+	if err != nil {
+		c.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := make(chan Event)
+	// the notifications are enough, polling could read a change before the notification of the firmware
+	go d.Watch(ctx, time.Hour, events)
+	c.Assert(nextEvent(c, events).Source, Equals, SourceInitial)
+
+	// the firmware changes actual_brightness, then the kernel notifies brightness_hw_changed
+	firmware := func(value string) {
+		overwrite(c, filepath.Join(s.path, "actual_brightness"), value)
+		overwrite(c, filepath.Join(s.path, "brightness_hw_changed"), value)
+	}
+	// the software writes brightness, which actual_brightness follows
+	software := func(value string) {
+		overwrite(c, filepath.Join(s.path, "actual_brightness"), value)
+		overwrite(c, filepath.Join(s.path, "brightness"), value)
+	}
+
+	firmware("800")
+	e := nextEvent(c, events)
+	c.Assert(e.ActualBrightness, Equals, int64(800))
+	c.Assert(e.Source, Equals, SourceHardware)
+	c.Assert(*e.HwChanged, Equals, int64(800))
+
+	software("400")
+	e = nextEvent(c, events)
+	c.Assert(e.ActualBrightness, Equals, int64(400))
+	c.Assert(e.Source, Equals, SourceSoftware)
+
+	// the firmware sets the same value again, the notification tells it apart from a software change
+	firmware("800")
+	e = nextEvent(c, events)
+	c.Assert(e.ActualBrightness, Equals, int64(800))
+	c.Assert(e.Source, Equals, SourceHardware)
+
+	software("400")
+	c.Assert(nextEvent(c, events).Source, Equals, SourceSoftware)
+}
+
+func (s *BacklightSuite) TestWatchPendingOk(c *C) {
+	// the wakes queued behind another one are read out, and the one of brightness_hw_changed is found
+	wake := make(chan string, 4)
+	wake <- filepath.Join(s.path, "actual_brightness")
+	wake <- filepath.Join(s.path, hwChangedFile)
+	wake <- filepath.Join(s.path, "brightness")
+	c.Assert(pending(wake), Equals, true)
+	c.Assert(wake, HasLen, 0)
+
+	wake <- filepath.Join(s.path, "brightness")
+	c.Assert(pending(wake), Equals, false)
+	c.Assert(pending(nil), Equals, false)
+}
+
+// pollBackend is a Backend without files to watch, like on a system without inotify.
+type pollBackend struct {
+	Backend
+}
+
+func (b pollBackend) Describe() Description {
+	desc := b.Backend.Describe()
+	desc.Files = nil
+	return desc
+}
+
+func (s *BacklightSuite) TestWatchHwChangedPollOk(c *C) {
+	if err := ioutil.WriteFile(filepath.Join(s.path, "brightness_hw_changed"), []byte("500\n"), 0644); err != nil {
+		c.Fatal(err)
+	}
+	d, err := NewDevice("intel_backlight", pollBackend{SysfsBacklight{Path: s.path}})
+	if err != nil {
+		c.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := make(chan Event)
+	go d.Watch(ctx, 5*time.Millisecond, events)
+	c.Assert(nextEvent(c, events).Source, Equals, SourceInitial)
+
+	// without notifications, the value of brightness_hw_changed tells the changes of the firmware
+	overwrite(c, filepath.Join(s.path, "brightness_hw_changed"), "800")
+	overwrite(c, filepath.Join(s.path, "actual_brightness"), "800")
+	e := nextEvent(c, events)
+	c.Assert(e.ActualBrightness, Equals, int64(800))
+	c.Assert(e.Source, Equals, SourceHardware)
+
+	overwrite(c, filepath.Join(s.path, "actual_brightness"), "400")
+	c.Assert(nextEvent(c, events).Source, Equals, SourceSoftware)
+}
+
+func (s *BacklightSuite) TestWatchNotifyOk(c *C) {
+	if runtime.GOOS != "linux" {
+		c.Skip("inotify is only available on linux")
+	}
+	d, err := Open(s.root, "intel_backlight")
+	if err != nil {
+		c.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := make(chan Event)
+	go d.Watch(ctx, time.Hour, events)
+	nextEvent(c, events)
+
+	overwrite(c, filepath.Join(s.path, "actual_brightness"), "750")
+	c.Assert(nextEvent(c, events).ActualBrightness, Equals, int64(750))
+}
+
+func (s *BacklightSuite) TestWatchFileKo(c *C) {
+	d, err := Open(s.root, "intel_backlight")
+	if err != nil {
+		c.Fatal(err)
+	}
+	events := make(chan Event, 1)
+	done := make(chan error)
+	go func() { done <- d.Watch(context.Background(), 5*time.Millisecond, events) }()
+	nextEvent(c, events)
+
+	if err := ioutil.WriteFile(filepath.Join(s.path, "actual_brightness"), []byte("bright\n"), 0644); err != nil {
+		c.Fatal(err)
+	}
+	c.Assert(<-done, Not(IsNil))
+}
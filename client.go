@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/rustx/gobacklight/backlight"
+)
+
+// defaultSocket returns the path of the daemon socket, in $XDG_RUNTIME_DIR when it is set,
+// and in a folder of the user in the temporary folder otherwise, created private by the daemon.
+func defaultSocket() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "gobacklight.sock")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("gobacklight-%d", os.Getuid()), "gobacklight.sock")
+}
+
+// socket returns the path of the daemon socket, the socket option overriding sockpath.
+func (bc *BrightnessControl) socket() string {
+	if bc.Config.Socket != "" {
+		return bc.Config.Socket
+	}
+	return sockpath
+}
+
+// options returns the options of the command line changing the percentages, the limits, the caps, the fades
+// and the writes of the device.
+func (bc *BrightnessControl) options() Options {
+	o := Options{
+		Curve:       bc.Config.Curve,
+		Exponent:    bc.Config.Exponent,
+		Min:         bc.Config.Min,
+		Max:         bc.Config.Max,
+		Floor:       bc.Config.Floor,
+		AllowOff:    bc.Config.AllowOff,
+		AC:          bc.Config.AC,
+		Battery:     bc.Config.Battery,
+		ACMax:       bc.Config.ACMax,
+		BatteryMax:  bc.Config.BatteryMax,
+		BatteryCaps: bc.Config.BatteryCaps,
+		NoLogind:    bc.Config.NoLogind,
+		Easing:      bc.Config.Easing,
+	}
+	if bc.Config.FadeInterval > 0 {
+		o.FadeInterval = bc.Config.FadeInterval.String()
+	}
+	return o
+}
+
+// remote sends the request to the daemon when it is running, and returns its response.
+// It returns false when the daemon is not running, or disabled by the no-daemon option,
+// or when the daemon refuses the request, for another device or with other options,
+// so the action runs on the device directly.
+// The request is abandoned when ctx is done, the daemon going on with it.
+// A socket of another user is refused, it may pose as the daemon.
+func (bc *BrightnessControl) remote(ctx context.Context, req Request) (*Response, bool, error) {
+	if bc.Config.NoDaemon {
+		return nil, false, nil
+	}
+	info, err := os.Lstat(bc.socket())
+	if err != nil {
+		return nil, false, nil
+	}
+	if !owned(info) {
+		return nil, true, fmt.Errorf(ownerMsg, bc.socket())
+	}
+	conn, err := net.Dial("unix", bc.socket())
+	if err != nil {
+		return nil, false, nil
+	}
+	defer conn.Close()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	req.Device = bc.Config.Device
+	if name, class := splitDevice(bc.Config.Device, bc.Config.Class); backlight.Class(class) != backlight.ClassBacklight {
+		req.Device = class + ":" + name
+	}
+	if bc.Config.Fade > 0 {
+		req.Duration = bc.Config.Fade.String()
+	}
+	options := bc.options()
+	req.Options = &options
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, true, err
+	}
+	var resp Response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		if ctx.Err() != nil {
+			return nil, true, ctx.Err()
+		}
+		return nil, true, err
+	}
+	if resp.Refused {
+		return nil, false, nil
+	}
+	bc.response = &resp
+	if resp.Error != "" {
+		return nil, true, errors.New(resp.Error)
+	}
+	return &resp, true, nil
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rustx/gobacklight/backlight"
+)
+
+// ValueCommand is a command taking a brightness value, like set, and the color of a multicolor LED.
+type ValueCommand struct {
+	Color string `long:"color" description:"color of a multicolor LED written with the brightness, like ff8800 or red=255,green=128,blue=0"`
+	Args  struct {
+		Value string `positional-arg-name:"VALUE" description:"value like 50%, +5%, -10%, 750, +100, 0.4, max or min"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+// ColorCommand is the color command, printing or setting the intensities of the channels of a multicolor LED.
+type ColorCommand struct {
+	Args struct {
+		Color string `positional-arg-name:"COLOR" description:"color like ff8800, scaled to max_brightness, or intensities like red=255,green=128,blue=0, the color is printed without it"`
+	} `positional-args:"yes"`
+}
+
+// PercentCommand is a command taking a percentage, like inc and dec.
+type PercentCommand struct {
+	Args struct {
+		Percent string `positional-arg-name:"PERCENT" description:"percentage like 5 or 5%"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+// GetCommand is the get command, printing the brightness of the device.
+type GetCommand struct {
+	Output   string `short:"o" long:"output" default:"plain" choice:"plain" choice:"raw" choice:"json" choice:"template" description:"output format : the percentage, the raw brightness, the device attributes as json, or the template"`
+	Template string `long:"template" description:"text/template rendered with the device attributes, like '☀ {{.Percent}}%', implies the template output"`
+}
+
+// OutputCommand is a command printing devices attributes, like list and info.
+type OutputCommand struct {
+	Output   string `short:"o" long:"output" default:"table" choice:"table" choice:"json" choice:"template" description:"output format"`
+	Template string `long:"template" description:"text/template rendered with the attributes of each device, like '{{.Name}} {{.Percent}}%', implies the template output"`
+}
+
+// WatchCommand is the watch command, printing the brightness of the device each time it changes.
+type WatchCommand struct {
+	Output   string        `short:"o" long:"output" default:"plain" choice:"plain" choice:"raw" choice:"json" choice:"template" description:"output format of each change : the percentage, the raw brightness, a json line with the time and the device attributes, or the template"`
+	Template string        `long:"template" description:"text/template rendered with the time and the device attributes on each change, like '{{.Time.Format \"15:04:05\"}} {{.Percent}}%', implies the template output"`
+	Interval time.Duration `long:"interval" default:"250ms" description:"polling interval of actual_brightness, at least 5s for a monitor, the changes written to sysfs are noticed at once with inotify"`
+}
+
+// DaemonCommand is the daemon command, serving the requests of the clients on a Unix socket.
+type DaemonCommand struct {
+	Interval time.Duration `long:"interval" default:"250ms" description:"polling interval of actual_brightness while clients are subscribed, at least 5s for a monitor"`
+}
+
+// AutoCommand is the auto command, setting the brightness from an ambient light sensor until interrupted.
+type AutoCommand struct {
+	Sensor     string        `long:"sensor" default:"auto" description:"IIO device of the ambient light sensor, like iio:device0, auto picks the first one"`
+	LuxCurve   string        `long:"lux-curve" default:"0:5,10:20,100:40,1000:80,10000:100" description:"brightness percentages of the illuminances, as lux:percent points"`
+	Interval   time.Duration `long:"interval" default:"1s" description:"sampling interval of the sensor"`
+	Window     int           `long:"window" default:"5" description:"number of samples of the median, dropping the spikes"`
+	Alpha      float64       `long:"alpha" default:"0.3" description:"factor of the moving average of the illuminance, between 0 and 1, a lower one smoothing more"`
+	Hysteresis float64       `long:"hysteresis" default:"0.1" description:"relative change of the illuminance needed to change the brightness"`
+}
+
+// action runs a command with its argument, the value of set, inc and dec.
+// The read commands print the devices attributes in the Format of BrightnessControl.
+type action func(bc *BrightnessControl, ctx context.Context, arg string) (string, error)
+
+// actions maps the commands to their action, the legacy flags are mapped to the same commands.
+var actions = map[string]action{
+	"get":    getAction,
+	"set":    setAction,
+	"inc":    incAction,
+	"dec":    decAction,
+	"list":   listAction,
+	"info":   infoAction,
+	"watch":  watchAction,
+	"daemon": daemonAction,
+	"auto":   autoAction,
+	"adapt":  adaptAction,
+	"color":  colorAction,
+
+	"model show":  modelShowAction,
+	"model reset": modelResetAction,
+
+	"apply-profile": applyProfileAction,
+
+	"schedule show": scheduleShowAction,
+	"schedule run":  scheduleRunAction,
+}
+
+func getAction(bc *BrightnessControl, ctx context.Context, arg string) (string, error) {
+	if resp, ok, err := bc.remote(ctx, Request{Command: "get"}); ok {
+		if err != nil {
+			return "", err
+		}
+		return bc.Format.device(*resp.Device)
+	}
+	if err := bc.open(); err != nil {
+		return "", err
+	}
+	return bc.Format.device(bc.Backlight.Info())
+}
+
+func setAction(bc *BrightnessControl, ctx context.Context, arg string) (string, error) {
+	v, err := backlight.ParseValue(arg)
+	if err != nil {
+		return "", err
+	}
+	return "", bc.change(ctx, Request{Command: "set", Value: arg, Color: bc.Config.SetCommand.Color}, v)
+}
+
+func incAction(bc *BrightnessControl, ctx context.Context, arg string) (string, error) {
+	v, err := relative(arg, 1)
+	if err != nil {
+		return "", err
+	}
+	return "", bc.change(ctx, Request{Command: "inc", Value: arg}, v)
+}
+
+func decAction(bc *BrightnessControl, ctx context.Context, arg string) (string, error) {
+	v, err := relative(arg, -1)
+	if err != nil {
+		return "", err
+	}
+	return "", bc.change(ctx, Request{Command: "dec", Value: arg}, v)
+}
+
+func colorAction(bc *BrightnessControl, ctx context.Context, arg string) (string, error) {
+	if err := bc.open(); err != nil {
+		return "", err
+	}
+	if arg != "" {
+		return "", setColor(bc.Backlight, arg)
+	}
+	if len(bc.Backlight.Channels) == 0 {
+		return "", backlight.ErrNoColor
+	}
+	return bc.Backlight.Info().Color()
+}
+
+func listAction(bc *BrightnessControl, ctx context.Context, arg string) (string, error) {
+	class, root, _, err := bc.device()
+	if err != nil {
+		return "", err
+	}
+	var devices []backlight.Info
+	if class == backlight.ClassDDC {
+		devices, err = backlight.DDCDevices(root, i2copen)
+	} else {
+		devices, err = class.Devices(root)
+	}
+	if err != nil {
+		return "", err
+	}
+	// the devices the user can't write are written through logind in the active session, see Init
+	if class != backlight.ClassDDC && !bc.Config.NoLogind {
+		session := &backlight.Logind{Address: busaddr}
+		defer session.Close()
+		checked, active := false, false
+		for i := range devices {
+			if !devices[i].Writable {
+				if !checked {
+					checked, active = true, session.Active()
+				}
+				devices[i].Writable = active
+			}
+		}
+	}
+	return bc.Format.devices(devices)
+}
+
+func infoAction(bc *BrightnessControl, ctx context.Context, arg string) (string, error) {
+	if err := bc.open(); err != nil {
+		return "", err
+	}
+	// Writable asks logind again, the loads of the device reuse its last answer
+	bc.Backlight.Writable()
+	return bc.Format.devices([]backlight.Info{bc.Backlight.Info()})
+}
+
+func watchAction(bc *BrightnessControl, ctx context.Context, arg string) (string, error) {
+	if err := bc.open(); err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	events := make(chan backlight.Event)
+	done := make(chan error, 1)
+	go func() { done <- bc.Backlight.Watch(ctx, bc.Config.WatchCommand.Interval, events) }()
+
+	for e := range events {
+		line, err := bc.Format.event(e)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintln(bc.out(), line)
+	}
+	return "", <-done
+}
+
+func daemonAction(bc *BrightnessControl, ctx context.Context, arg string) (string, error) {
+	if err := bc.open(); err != nil {
+		return "", err
+	}
+	// the profiles are checked before the socket is created, so an invalid one doesn't leave it behind
+	caps, err := bc.caps()
+	if err != nil {
+		return "", err
+	}
+	l, err := listen(bc.socket())
+	if err != nil {
+		return "", err
+	}
+	dm := &Daemon{Backlight: bc.Backlight, Caps: caps, Options: bc.options(), Interval: bc.Config.DaemonCommand.Interval}
+	return "", dm.Serve(ctx, l)
+}
+
+func autoAction(bc *BrightnessControl, ctx context.Context, arg string) (string, error) {
+	options := bc.Config.AutoCommand
+	curve, err := backlight.ParseLuxCurve(options.LuxCurve)
+	if err != nil {
+		return "", err
+	}
+	sensor, err := backlight.OpenSensor(iiopath, options.Sensor)
+	if err != nil {
+		return "", err
+	}
+	if err := bc.open(); err != nil {
+		return "", err
+	}
+	ambient := &backlight.Ambient{
+		Sensor:   sensor,
+		Curve:    curve,
+		Smoother: backlight.Smoother{Window: options.Window, Alpha: options.Alpha, Hysteresis: options.Hysteresis},
+		Interval: options.Interval,
+	}
+	return "", ambient.Run(ctx, bc.Backlight)
+}
+
+// setColor writes the color of a multicolor LED, the channels it doesn't give being unchanged.
+func setColor(d *backlight.Device, color string) error {
+	intensities, err := d.ParseColor(color)
+	if err != nil {
+		return err
+	}
+	return d.SetColor(intensities)
+}
+
+// relative returns the relative percentage of inc, with a sign of 1, and of dec, with a sign of -1.
+func relative(arg string, sign float64) (backlight.Value, error) {
+	p, err := parsePercent(arg)
+	if err != nil {
+		return backlight.Value{}, err
+	}
+	return backlight.Value{Unit: backlight.Percent, Relative: true, Amount: sign * p}, nil
+}
+
+// parsePercent parses the argument of inc and dec, a positive percentage with or without the % sign.
+// It is parsed by ParseValue as a relative percentage, so the numbers are the ones of set.
+// A percentage over 100 is clamped to the range of the device when applied.
+func parsePercent(arg string) (float64, error) {
+	v, err := backlight.ParseValue("+" + strings.TrimPrefix(strings.TrimSuffix(arg, "%"), "+") + "%")
+	if err != nil || v.Unit != backlight.Percent || !v.Relative || v.Amount < 0 {
+		return 0, errors.New(percentMsg)
+	}
+	return v.Amount, nil
+}
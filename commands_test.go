@@ -0,0 +1,462 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+	"github.com/rustx/gobacklight/backlight"
+	. "gopkg.in/check.v1"
+)
+
+func (s *GobacklightSuite) TestParseCommandsOk(c *C) {
+	for _, args := range [][]string{
+		{"set", "40%"},
+		{"-v", "intel_backlight", "set", "40%"},
+		{"set", "40%", "--fade", "500ms"},
+	} {
+		var conf Config
+		parser := flags.NewParser(&conf, flags.None)
+		parser.SubcommandsOptional = true
+		_, err := parser.ParseArgs(args)
+
+		c.Assert(err, IsNil)
+		c.Assert(parser.Active, Not(IsNil))
+		c.Assert(parser.Active.Name, Equals, "set")
+		c.Assert(conf.SetCommand.Args.Value, Equals, "40%")
+	}
+}
+
+func (s *GobacklightSuite) TestParseNegativeValuesOk(c *C) {
+	for _, test := range []struct {
+		args  []string
+		value string
+	}{
+		{[]string{"set", "-10%"}, "-10%"},
+		{[]string{"set", "-100"}, "-100"},
+		{[]string{"-v", "intel_backlight", "set", "-100", "--fade", "500ms"}, "-100"},
+		{[]string{"set", "--fade", "500ms", "--", "-0.1"}, "-0.1"},
+	} {
+		var conf Config
+		parser := flags.NewParser(&conf, flags.PassDoubleDash)
+		parser.SubcommandsOptional = true
+		_, err := parser.ParseArgs(negativeArgs(parser, test.args))
+
+		c.Assert(err, IsNil, Commentf("args %v", test.args))
+		c.Assert(parser.Active, Not(IsNil))
+		c.Assert(parser.Active.Name, Equals, "set")
+		c.Assert(conf.SetCommand.Args.Value, Equals, test.value)
+	}
+
+	for _, args := range [][]string{
+		{"-t", "-10%"},
+		{"--to", "-10%"},
+		{"-v", "intel_backlight", "-t", "-10%", "--fade", "500ms"},
+	} {
+		var conf Config
+		parser := flags.NewParser(&conf, flags.PassDoubleDash)
+		parser.SubcommandsOptional = true
+		_, err := parser.ParseArgs(negativeArgs(parser, args))
+
+		c.Assert(err, IsNil, Commentf("args %v", args))
+		c.Assert(parser.Active, IsNil)
+		c.Assert(conf.To, Equals, "-10%")
+	}
+}
+
+func (s *GobacklightSuite) TestParseLegacyOk(c *C) {
+	var conf Config
+	parser := flags.NewParser(&conf, flags.None)
+	parser.SubcommandsOptional = true
+	_, err := parser.ParseArgs([]string{"-s", "25"})
+
+	c.Assert(err, IsNil)
+	c.Assert(parser.Active, IsNil)
+	c.Assert(conf.Set, Equals, uint(25))
+}
+
+func (s *GobacklightSuite) TestParseCommandKo(c *C) {
+	var conf Config
+	parser := flags.NewParser(&conf, flags.None)
+	parser.SubcommandsOptional = true
+	_, err := parser.ParseArgs([]string{"inc"})
+
+	c.Assert(err, Not(IsNil))
+}
+
+func (s *GobacklightSuite) TestRunGetCommandOk(c *C) {
+	bc := BrightnessControl{Config: &Config{}, Command: "get"}
+	v, err := bc.Run(context.Background())
+
+	c.Assert(err, IsNil)
+	c.Assert(v, Not(Equals), "")
+	c.Assert(bc.Backlight, Not(IsNil))
+}
+
+func (s *GobacklightSuite) TestRunSetCommandOk(c *C) {
+	conf := Config{}
+	conf.SetCommand.Args.Value = "750"
+	bc := BrightnessControl{Config: &conf, Command: "set"}
+	v, err := bc.Run(context.Background())
+
+	c.Assert(err, IsNil)
+	c.Assert(v, Equals, "")
+	c.Assert(bc.Backlight.Brightness, Equals, int64(750))
+}
+
+func (s *GobacklightSuite) TestRunSetCommandKo(c *C) {
+	conf := Config{}
+	conf.SetCommand.Args.Value = "bright"
+	bc := BrightnessControl{Config: &conf, Command: "set"}
+	_, err := bc.Run(context.Background())
+
+	c.Assert(err, Equals, backlight.ErrValue)
+}
+
+func (s *GobacklightSuite) TestRunIncCommandOk(c *C) {
+	conf := Config{}
+	conf.IncCommand.Args.Percent = "150%"
+	bc := BrightnessControl{Config: &conf, Command: "inc"}
+	_, err := bc.Run(context.Background())
+
+	c.Assert(err, IsNil)
+	c.Assert(bc.Backlight.Brightness, Equals, bc.Backlight.MaxBrightness)
+	c.Assert(bc.Backlight.Clamped, Equals, true)
+}
+
+func (s *GobacklightSuite) TestRunIncCommandKo(c *C) {
+	// the numbers of set only, without the exponents, the hexadecimal digits or the underscores
+	for _, value := range []string{"1e1", "0x1p3", "1_0", "+-5", "Inf", ""} {
+		conf := Config{}
+		conf.IncCommand.Args.Percent = value
+		bc := BrightnessControl{Config: &conf, Command: "inc"}
+		_, err := bc.Run(context.Background())
+
+		c.Assert(err, ErrorMatches, percentMsg)
+	}
+}
+
+func (s *GobacklightSuite) TestRunDecCommandKo(c *C) {
+	for _, value := range []string{"-5", "five", "NaN", "1e1"} {
+		conf := Config{}
+		conf.DecCommand.Args.Percent = value
+		bc := BrightnessControl{Config: &conf, Command: "dec"}
+		_, err := bc.Run(context.Background())
+
+		c.Assert(err, ErrorMatches, percentMsg)
+	}
+}
+
+func (s *GobacklightSuite) TestRunListCommandOk(c *C) {
+	defer func(path string) { syspath = path }(syspath)
+	syspath = c.MkDir() + "/"
+
+	makeDevice(c, syspath, "acpi_video0", "firmware")
+	makeDevice(c, syspath, "intel_backlight", "raw")
+
+	conf := Config{Output: "table"}
+	conf.ListCommand.Output = "json"
+	bc := BrightnessControl{Config: &conf, Command: "list"}
+	v, err := bc.Run(context.Background())
+	c.Assert(err, IsNil)
+
+	var devices []backlight.Info
+	c.Assert(json.Unmarshal([]byte(v), &devices), IsNil)
+	c.Assert(devices, HasLen, 2)
+	c.Assert(bc.Backlight, IsNil)
+}
+
+func (s *GobacklightSuite) TestRunInfoCommandOk(c *C) {
+	conf := Config{}
+	conf.InfoCommand.Output = "json"
+	bc := BrightnessControl{Config: &conf, Command: "info"}
+	v, err := bc.Run(context.Background())
+	c.Assert(err, IsNil)
+
+	var devices []backlight.Info
+	c.Assert(json.Unmarshal([]byte(v), &devices), IsNil)
+	c.Assert(devices, HasLen, 1)
+	c.Assert(devices[0].MaxBrightness, Equals, bc.Backlight.MaxBrightness)
+}
+
+func (s *GobacklightSuite) TestRunCommandCombinedKo(c *C) {
+	conf := Config{Get: true}
+	conf.SetCommand.Args.Value = "50%"
+	bc := BrightnessControl{Config: &conf, Command: "set"}
+	v, err := bc.Run(context.Background())
+
+	c.Assert(v, Equals, "")
+	c.Assert(err, ErrorMatches, combinedMsg)
+}
+
+func (s *GobacklightSuite) TestRunWatchCommandOk(c *C) {
+	defer func(path string) { syspath = path }(syspath)
+	syspath = c.MkDir() + "/"
+
+	makeDevice(c, syspath, "intel_backlight", "raw")
+
+	conf := Config{Device: "intel_backlight"}
+	conf.WatchCommand.Output = "json"
+	conf.WatchCommand.Interval = 5 * time.Millisecond
+	var out bytes.Buffer
+	bc := BrightnessControl{Config: &conf, Command: "watch", Out: &out}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	v, err := bc.Run(ctx)
+
+	c.Assert(err, Equals, context.DeadlineExceeded)
+	c.Assert(v, Equals, "")
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	c.Assert(lines, HasLen, 1)
+
+	var e backlight.Event
+	c.Assert(json.Unmarshal([]byte(lines[0]), &e), IsNil)
+	c.Assert(e.Name, Equals, "intel_backlight")
+	c.Assert(e.Percent, Equals, 50)
+}
+
+func (s *GobacklightSuite) TestRunWatchCommandTemplateKo(c *C) {
+	conf := Config{}
+	conf.WatchCommand.Output = "template"
+	bc := BrightnessControl{Config: &conf, Command: "watch", Out: ioutil.Discard}
+	_, err := bc.Run(context.Background())
+
+	c.Assert(err, ErrorMatches, templateMsg)
+}
+
+func (s *GobacklightSuite) TestRunAutoCommandOk(c *C) {
+	defer func(path, iio string) { syspath, iiopath = path, iio }(syspath, iiopath)
+	syspath, iiopath = c.MkDir()+"/", c.MkDir()
+
+	makeDevice(c, syspath, "intel_backlight", "raw")
+	sensor := filepath.Join(iiopath, "iio:device0")
+	if err := os.Mkdir(sensor, 0755); err != nil {
+		c.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(sensor, "in_illuminance_raw"), []byte("2000\n"), 0644); err != nil {
+		c.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(sensor, "in_illuminance_scale"), []byte("0.5\n"), 0644); err != nil {
+		c.Fatal(err)
+	}
+
+	conf := Config{Device: "intel_backlight"}
+	conf.AutoCommand = AutoCommand{Sensor: "auto", LuxCurve: "0:10,1000:90", Interval: 5 * time.Millisecond, Window: 1, Alpha: 1}
+	bc := BrightnessControl{Config: &conf, Command: "auto"}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	_, err := bc.Run(ctx)
+
+	c.Assert(err, Equals, context.DeadlineExceeded)
+	c.Assert(bc.Backlight.Brightness, Equals, int64(900))
+}
+
+func (s *GobacklightSuite) TestRunAutoCommandKo(c *C) {
+	defer func(iio string) { iiopath = iio }(iiopath)
+	iiopath = c.MkDir()
+
+	conf := Config{}
+	conf.AutoCommand = AutoCommand{Sensor: "auto", LuxCurve: "0:10,1000:90"}
+	bc := BrightnessControl{Config: &conf, Command: "auto"}
+	_, err := bc.Run(context.Background())
+	c.Assert(err, Equals, backlight.ErrNoSensor)
+
+	conf.AutoCommand.LuxCurve = "dark"
+	_, err = bc.Run(context.Background())
+	c.Assert(err, Equals, backlight.ErrLuxCurve)
+}
+
+func (s *GobacklightSuite) TestSplitDeviceOk(c *C) {
+	for device, expected := range map[string][2]string{
+		"intel_backlight":            {"intel_backlight", "backlight"},
+		"leds:tpacpi::kbd_backlight": {"tpacpi::kbd_backlight", "leds"},
+		"tpacpi::kbd_backlight":      {"tpacpi::kbd_backlight", "backlight"},
+		"backlight:acpi_video0":      {"acpi_video0", "backlight"},
+		"ddc:DP-1":                   {"DP-1", "ddc"},
+	} {
+		name, class := splitDevice(device, "")
+		c.Assert([2]string{name, class}, Equals, expected)
+	}
+	name, class := splitDevice("auto", "leds")
+	c.Assert([2]string{name, class}, Equals, [2]string{"auto", "leds"})
+}
+
+func (s *GobacklightSuite) TestRunLEDCommandsOk(c *C) {
+	defer func(path string) { ledpath = path }(ledpath)
+	ledpath = c.MkDir()
+	for _, name := range []string{"input3::capslock", "tpacpi::kbd_backlight"} {
+		path := filepath.Join(ledpath, name)
+		if err := os.Mkdir(path, 0755); err != nil {
+			c.Fatal(err)
+		}
+		for file, value := range map[string]string{"brightness": "1", "max_brightness": "2"} {
+			if err := ioutil.WriteFile(filepath.Join(path, file), []byte(value+"\n"), 0644); err != nil {
+				c.Fatal(err)
+			}
+		}
+	}
+
+	conf := Config{Device: "auto", Class: "leds", NoLearn: true}
+	conf.ListCommand.Output = "json"
+	bc := BrightnessControl{Config: &conf, Command: "list"}
+	v, err := bc.Run(context.Background())
+	c.Assert(err, IsNil)
+	var devices []backlight.Info
+	c.Assert(json.Unmarshal([]byte(v), &devices), IsNil)
+	c.Assert(devices, HasLen, 2)
+
+	conf.DecCommand.Args.Percent = "50"
+	bc = BrightnessControl{Config: &conf, Command: "dec"}
+	_, err = bc.Run(context.Background())
+	c.Assert(err, IsNil)
+	c.Assert(bc.Backlight.Name, Equals, "tpacpi::kbd_backlight")
+	c.Assert(bc.Backlight.Brightness, Equals, int64(0))
+
+	conf = Config{Device: "leds:input3::capslock", NoLearn: true}
+	conf.SetCommand.Args.Value = "max"
+	bc = BrightnessControl{Config: &conf, Command: "set"}
+	_, err = bc.Run(context.Background())
+	c.Assert(err, IsNil)
+	c.Assert(bc.Backlight.Brightness, Equals, int64(2))
+}
+
+func (s *GobacklightSuite) TestRunColorCommandsOk(c *C) {
+	defer func(path string) { ledpath = path }(ledpath)
+	ledpath = c.MkDir()
+	path := filepath.Join(ledpath, "rgb:kbd_backlight")
+	if err := os.Mkdir(path, 0755); err != nil {
+		c.Fatal(err)
+	}
+	for file, value := range map[string]string{"brightness": "255", "max_brightness": "255", "multi_index": "red green blue", "multi_intensity": "255 255 255"} {
+		if err := ioutil.WriteFile(filepath.Join(path, file), []byte(value+"\n"), 0644); err != nil {
+			c.Fatal(err)
+		}
+	}
+
+	conf := Config{Device: "leds:rgb:kbd_backlight", NoLearn: true}
+	conf.SetCommand.Args.Value = "50%"
+	conf.SetCommand.Color = "ff8800"
+	bc := BrightnessControl{Config: &conf, Command: "set"}
+	_, err := bc.Run(context.Background())
+	c.Assert(err, IsNil)
+	c.Assert(brightness(c, filepath.Join(path, "multi_intensity")), Equals, "255 136 0")
+	c.Assert(brightness(c, filepath.Join(path, "brightness")), Equals, "128")
+
+	bc = BrightnessControl{Config: &conf, Command: "color"}
+	v, err := bc.Run(context.Background())
+	c.Assert(err, IsNil)
+	c.Assert(v, Equals, "red=255,green=136,blue=0")
+
+	conf.ColorCommand.Args.Color = "green=0"
+	bc = BrightnessControl{Config: &conf, Command: "color"}
+	_, err = bc.Run(context.Background())
+	c.Assert(err, IsNil)
+	c.Assert(brightness(c, filepath.Join(path, "multi_intensity")), Equals, "255 0 0")
+}
+
+func (s *GobacklightSuite) TestRunColorCommandKo(c *C) {
+	conf := Config{NoLearn: true}
+	conf.SetCommand.Args.Value = "50%"
+	conf.SetCommand.Color = "ff8800"
+	bc := BrightnessControl{Config: &conf, Command: "set"}
+	_, err := bc.Run(context.Background())
+	c.Assert(err, Equals, backlight.ErrNoColor)
+	// the brightness is not written when the color is invalid
+	c.Assert(bc.Backlight.Brightness, Not(Equals), bc.Backlight.Raw(50))
+
+	bc = BrightnessControl{Config: &Config{}, Command: "color"}
+	_, err = bc.Run(context.Background())
+	c.Assert(err, Equals, backlight.ErrNoColor)
+}
+
+func (s *GobacklightSuite) TestRunFakeBackendOk(c *C) {
+	fake := &backlight.Fake{Brightness: 500, Max: 1000}
+	d, err := backlight.NewDevice("fake", fake)
+	c.Assert(err, IsNil)
+	d.Curve = backlight.LinearCurve{}
+
+	conf := Config{NoLearn: true, NoDaemon: true}
+	conf.IncCommand.Args.Percent = "10"
+	bc := BrightnessControl{Config: &conf, Command: "inc", Backlight: d}
+	_, err = bc.Run(context.Background())
+	c.Assert(err, IsNil)
+	c.Assert(fake.Writes(), DeepEquals, []int64{600})
+
+	c.Assert(d.Load(), IsNil)
+	conf.GetCommand.Output = "raw"
+	bc = BrightnessControl{Config: &conf, Command: "get", Backlight: d}
+	v, err := bc.Run(context.Background())
+	c.Assert(err, IsNil)
+	c.Assert(v, Equals, "600")
+}
+
+func (s *GobacklightSuite) TestRunSetLogindKo(c *C) {
+	if err := os.Chmod(filepath.Join(syspath, "brightness"), 0444); err != nil {
+		c.Fatal(err)
+	}
+	conf := Config{NoLearn: true, NoDaemon: true}
+	conf.SetCommand.Args.Value = "50%"
+	bc := BrightnessControl{Config: &conf, Command: "set"}
+	_, err := bc.Run(context.Background())
+	if err == nil {
+		c.Skip("the brightness file is writable anyway, like by root")
+	}
+	// the write falls back on logind, and the bus is not running
+	c.Assert(os.IsPermission(err), Equals, true)
+	_, ok := bc.Backlight.Backend.(*backlight.Logind)
+	c.Assert(ok, Equals, true)
+
+	conf.NoLogind = true
+	bc = BrightnessControl{Config: &conf, Command: "set"}
+	_, err = bc.Run(context.Background())
+	c.Assert(os.IsPermission(err), Equals, true)
+	_, ok = bc.Backlight.Backend.(*backlight.Logind)
+	c.Assert(ok, Equals, false)
+}
+
+func (s *GobacklightSuite) TestRunDDCCommandsOk(c *C) {
+	defer func(path string, open func(string) (backlight.I2C, error)) { drmpath, i2copen = path, open }(drmpath, i2copen)
+	drmpath = c.MkDir()
+	for name, bus := range map[string]string{"card0-eDP-1": "i2c-3", "card0-DP-1": "i2c-4"} {
+		path := filepath.Join(drmpath, name)
+		if err := os.Mkdir(path, 0755); err != nil {
+			c.Fatal(err)
+		}
+		if err := os.Symlink(filepath.Join("..", "i2c", bus), filepath.Join(path, "ddc")); err != nil {
+			c.Fatal(err)
+		}
+	}
+	monitors := map[string]*backlight.FakeMonitor{}
+	i2copen = func(bus string) (backlight.I2C, error) {
+		m := &backlight.FakeMonitor{Brightness: 70, Max: 100}
+		monitors[bus] = m
+		return m, nil
+	}
+
+	conf := Config{Device: "ddc:DP-1", NoLearn: true, NoDaemon: true}
+	conf.SetCommand.Args.Value = "40%"
+	bc := BrightnessControl{Config: &conf, Command: "set"}
+	_, err := bc.Run(context.Background())
+	c.Assert(err, IsNil)
+	c.Assert(bc.Backlight.Name, Equals, "DP-1")
+	c.Assert(monitors["/dev/i2c-4"].Sets(), DeepEquals, []uint16{40})
+	c.Assert(bc.Close(), IsNil)
+
+	conf = Config{Device: "auto", Class: "ddc"}
+	conf.ListCommand.Output = "json"
+	bc = BrightnessControl{Config: &conf, Command: "list"}
+	v, err := bc.Run(context.Background())
+	c.Assert(err, IsNil)
+	var devices []backlight.Info
+	c.Assert(json.Unmarshal([]byte(v), &devices), IsNil)
+	c.Assert(devices, HasLen, 2)
+	c.Assert(devices[1].Name, Equals, "eDP-1")
+	c.Assert(devices[1].Percent, Equals, 70)
+}
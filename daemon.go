@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/rustx/gobacklight/backlight"
+)
+
+// Request is a line sent by a client to the daemon, as a json object.
+// Command is get, set, inc, dec, fade or subscribe, and Value the argument of set, inc, dec and fade.
+// Duration is the fade of set, inc and dec, like 500ms, and is required by fade.
+// Color is the color of a multicolor LED written by set before the brightness, like ff8800.
+// Device is the device asked by the client, prefixed by its class or not, like leds:tpacpi::kbd_backlight,
+// and Options the options of the client, the daemon using its own when they are nil.
+// The daemon refuses the requests for another device than its own, or with other options.
+type Request struct {
+	Command  string   `json:"command"`
+	Value    string   `json:"value,omitempty"`
+	Duration string   `json:"duration,omitempty"`
+	Color    string   `json:"color,omitempty"`
+	Device   string   `json:"device,omitempty"`
+	Options  *Options `json:"options,omitempty"`
+}
+
+// Options are the options of a client changing the percentages, the limits, the caps, the fades
+// and the writes of the device. FadeInterval is a duration like 20ms.
+type Options struct {
+	Curve        string   `json:"curve,omitempty"`
+	Exponent     float64  `json:"exponent,omitempty"`
+	Min          uint     `json:"min,omitempty"`
+	Max          uint     `json:"max,omitempty"`
+	Floor        []string `json:"floor,omitempty"`
+	AllowOff     bool     `json:"allow_off,omitempty"`
+	AC           string   `json:"ac,omitempty"`
+	Battery      string   `json:"battery,omitempty"`
+	ACMax        string   `json:"ac_max,omitempty"`
+	BatteryMax   string   `json:"battery_max,omitempty"`
+	BatteryCaps  string   `json:"battery_caps,omitempty"`
+	NoLogind     bool     `json:"no_logind,omitempty"`
+	Easing       string   `json:"easing,omitempty"`
+	FadeInterval string   `json:"fade_interval,omitempty"`
+}
+
+// Response is the line answered by the daemon to a Request, as a json object.
+// Percent is the brightness after the request, Clamped reports whether the value of a write was clamped,
+// and Reason why, when a battery rule capped it.
+// Error is the error of the request, the other fields are empty then,
+// and Refused reports that the daemon doesn't serve the request, for another device or with other options.
+type Response struct {
+	Error   string          `json:"error,omitempty"`
+	Refused bool            `json:"refused,omitempty"`
+	Percent int             `json:"percent"`
+	Clamped bool            `json:"clamped"`
+	Reason  string          `json:"reason,omitempty"`
+	Device  *backlight.Info `json:"device,omitempty"`
+}
+
+// Daemon holds a device and serves the requests of the clients on a Unix socket, each connection on its own goroutine.
+// The device is reloaded and the values are parsed one request at a time, but the writes run concurrently :
+// a write doesn't wait for the fade of the previous one, it retargets it, so a single fade runs on the device,
+// and the request of the fade retargeted returns without error.
+// The device is only watched while there are subscribers, they receive the Watch events of the device, polled every Interval.
+// The device is capped by the Caps of the power source and the battery level, read again on each request.
+// Options are the options the device was opened with, the requests with other ones are refused.
+type Daemon struct {
+	Backlight *backlight.Device
+	Caps      *Caps
+	Options   Options
+	Interval  time.Duration
+
+	mu          sync.Mutex
+	subscribers map[chan backlight.Event]bool
+	unwatch     context.CancelFunc
+	watched     chan struct{}
+	smu         sync.Mutex
+}
+
+// listen returns a listener on the Unix socket path, readable by the current user only.
+// A socket left by a daemon which didn't stop cleanly is removed, but a running daemon is an error,
+// and so is a file which is not a socket, which is never removed.
+// The folder of the socket is created private when it doesn't exist.
+func listen(path string) (net.Listener, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return nil, errors.New(runningMsg)
+	}
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf(notsocketMsg, path)
+		}
+		os.Remove(path)
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// Serve accepts the connections of the clients on l until ctx is done, then closes l.
+// It returns nil when ctx is done, or the error of the listener.
+func (dm *Daemon) Serve(ctx context.Context, l net.Listener) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+
+	dm.smu.Lock()
+	dm.subscribers = map[chan backlight.Event]bool{}
+	dm.smu.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		go dm.serveConn(ctx, conn)
+	}
+}
+
+// watch watches the device for the subscribers until unwatch is called, or ctx is done,
+// then closes watched. It is called with smu held.
+func (dm *Daemon) watch(ctx context.Context) {
+	ctx, dm.unwatch = context.WithCancel(ctx)
+	events := make(chan backlight.Event)
+	watched := make(chan struct{})
+	dm.watched = watched
+	go dm.Backlight.Watch(ctx, dm.Interval, events)
+	go func() {
+		dm.broadcast(events)
+		close(watched)
+	}()
+}
+
+// broadcast sends the events to the subscribers, skipping the ones which are not reading.
+func (dm *Daemon) broadcast(events <-chan backlight.Event) {
+	for e := range events {
+		dm.smu.Lock()
+		for ch := range dm.subscribers {
+			select {
+			case ch <- e:
+			default:
+			}
+		}
+		dm.smu.Unlock()
+	}
+}
+
+// serveConn answers the requests of a connection, one per line, until the client closes it.
+func (dm *Daemon) serveConn(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+	scanner := bufio.NewScanner(conn)
+	enc := json.NewEncoder(conn)
+	for scanner.Scan() {
+		var req Request
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			if err := enc.Encode(Response{Error: requestMsg}); err != nil {
+				return
+			}
+			continue
+		}
+		if req.Command == "subscribe" {
+			dm.subscribe(ctx, scanner, enc, req)
+			return
+		}
+		if err := enc.Encode(dm.Handle(ctx, req)); err != nil {
+			return
+		}
+	}
+}
+
+// subscribe answers a subscribe request with the current state of the device,
+// then sends the events of the device as json lines until the client closes the connection.
+// The first subscriber starts the watch of the device, and the last one stops it.
+func (dm *Daemon) subscribe(ctx context.Context, scanner *bufio.Scanner, enc *json.Encoder, req Request) {
+	ch := make(chan backlight.Event, 16)
+	dm.smu.Lock()
+	dm.subscribers[ch] = true
+	if len(dm.subscribers) == 1 {
+		dm.watch(ctx)
+	}
+	dm.smu.Unlock()
+	defer func() {
+		dm.smu.Lock()
+		delete(dm.subscribers, ch)
+		if len(dm.subscribers) == 0 {
+			dm.unwatch()
+		}
+		dm.smu.Unlock()
+	}()
+
+	if err := enc.Encode(dm.Handle(ctx, Request{Command: "get", Device: req.Device})); err != nil {
+		return
+	}
+	closed := make(chan struct{})
+	go func() {
+		for scanner.Scan() {
+		}
+		close(closed)
+	}()
+	for {
+		select {
+		case <-closed:
+			return
+		case e := <-ch:
+			if err := enc.Encode(e); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Handle runs a request on the device, after reloading it to see the changes made by others.
+// The fade of a write runs after the next request is let in, so the next write retargets it.
+func (dm *Daemon) Handle(ctx context.Context, req Request) Response {
+	if err := dm.refuse(req); err != nil {
+		return Response{Error: err.Error(), Refused: true}
+	}
+	info, v, fade, reason, err := dm.load(req)
+	if err != nil {
+		return Response{Error: err.Error()}
+	}
+	if req.Command == "get" {
+		return Response{Percent: info.Percent, Device: &info}
+	}
+	d := dm.Backlight
+	if req.Color != "" {
+		if err := setColor(d, req.Color); err != nil {
+			return Response{Error: err.Error()}
+		}
+	}
+	if err := d.ApplyFade(ctx, v, fade); err != nil {
+		return Response{Error: err.Error()}
+	}
+	raw, clamped := d.LastWrite()
+	info = d.Info()
+	return Response{Percent: int(math.Round(d.Percent(raw))), Clamped: clamped, Reason: capped(d, raw, clamped, reason), Device: &info}
+}
+
+// refuse returns an error when the request is for another device than the one of the daemon, or with other options :
+// the client runs it on the device itself then.
+func (dm *Daemon) refuse(req Request) error {
+	d := dm.Backlight
+	name, class := splitDevice(req.Device, "")
+	if req.Device != "" && (backlight.Class(class) != d.Info().Class || (name != backlight.Auto && name != d.Name)) {
+		return fmt.Errorf(deviceMsg, d.Name)
+	}
+	if req.Options != nil && !reflect.DeepEqual(*req.Options, dm.Options) {
+		return errors.New(optionsMsg)
+	}
+	return nil
+}
+
+// load reloads the device for a request, caps it, and parses its value and its fade, one request at a time.
+// It returns the reason of the cap too.
+func (dm *Daemon) load(req Request) (backlight.Info, backlight.Value, backlight.Fade, string, error) {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+
+	d := dm.Backlight
+	var v backlight.Value
+	fade := d.Fade
+	if err := d.Load(); err != nil {
+		return backlight.Info{}, v, fade, "", err
+	}
+	reason := ""
+	if dm.Caps != nil {
+		reason = dm.Caps.Limit(d)
+	}
+
+	var err error
+	switch req.Command {
+	case "get":
+	case "set", "fade":
+		v, err = backlight.ParseValue(req.Value)
+	case "inc":
+		v, err = relative(req.Value, 1)
+	case "dec":
+		v, err = relative(req.Value, -1)
+	default:
+		err = errors.New(commandMsg)
+	}
+	if err == nil && (req.Duration != "" || req.Command == "fade") {
+		fade.Duration, err = time.ParseDuration(req.Duration)
+	}
+	return d.Info(), v, fade, reason, err
+}
@@ -0,0 +1,472 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/rustx/gobacklight/backlight"
+	. "gopkg.in/check.v1"
+)
+
+// countingBackend is a Backend counting its reads, and signalling them and its writes on read and written when they are set.
+type countingBackend struct {
+	backlight.Backend
+	read    chan struct{}
+	written chan int64
+
+	mu    sync.Mutex
+	reads int
+}
+
+func (b *countingBackend) Read() (backlight.Reading, error) {
+	b.mu.Lock()
+	b.reads++
+	b.mu.Unlock()
+	if b.read != nil {
+		select {
+		case b.read <- struct{}{}:
+		default:
+		}
+	}
+	return b.Backend.Read()
+}
+
+func (b *countingBackend) Write(raw int64) error {
+	err := b.Backend.Write(raw)
+	if b.written != nil {
+		select {
+		case b.written <- raw:
+		default:
+		}
+	}
+	return err
+}
+
+func (b *countingBackend) Reads() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.reads
+}
+
+// startDaemon serves a daemon for the device of a fresh sysfs root, on a socket of the test folder.
+// It returns the config of the clients, the path of the device, and a function stopping the daemon.
+func startDaemon(c *C) (Config, string, func()) {
+	root := c.MkDir()
+	makeDevice(c, root, "intel_backlight", "raw")
+	d, err := backlight.Open(root, "intel_backlight")
+	if err != nil {
+		c.Fatal(err)
+	}
+	conf, _, stop := serveDaemon(c, d)
+	conf.Device = "intel_backlight"
+	return conf, d.Path, stop
+}
+
+// serveDaemon serves a daemon for the device on a socket of the test folder.
+// It returns the config of the clients, the daemon, and a function stopping it.
+func serveDaemon(c *C, d *backlight.Device) (Config, *Daemon, func()) {
+	socket := filepath.Join(c.MkDir(), "gobacklight.sock")
+	l, err := listen(socket)
+	if err != nil {
+		c.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	dm := &Daemon{Backlight: d, Interval: 5 * time.Millisecond}
+	go func() { done <- dm.Serve(ctx, l) }()
+
+	return Config{Socket: socket}, dm, func() {
+		cancel()
+		c.Assert(<-done, IsNil)
+	}
+}
+
+func (s *GobacklightSuite) TestDaemonSetOk(c *C) {
+	conf, _, stop := startDaemon(c)
+	defer stop()
+
+	conf.SetCommand.Args.Value = "75%"
+	bc := BrightnessControl{Config: &conf, Command: "set"}
+	_, err := bc.Run(context.Background())
+	c.Assert(err, IsNil)
+	c.Assert(bc.Backlight, IsNil)
+
+	percent, clamped := bc.clamped()
+	c.Assert(percent, Equals, 75)
+	c.Assert(clamped, Equals, false)
+
+	conf.IncCommand.Args.Percent = "80"
+	bc = BrightnessControl{Config: &conf, Command: "inc"}
+	_, err = bc.Run(context.Background())
+	c.Assert(err, IsNil)
+
+	percent, clamped = bc.clamped()
+	c.Assert(percent, Equals, 100)
+	c.Assert(clamped, Equals, true)
+}
+
+func (s *GobacklightSuite) TestDaemonGetOk(c *C) {
+	conf, _, stop := startDaemon(c)
+	defer stop()
+
+	conf.GetCommand.Output = "json"
+	bc := BrightnessControl{Config: &conf, Command: "get"}
+	v, err := bc.Run(context.Background())
+	c.Assert(err, IsNil)
+
+	var info backlight.Info
+	c.Assert(json.Unmarshal([]byte(v), &info), IsNil)
+	c.Assert(info.Name, Equals, "intel_backlight")
+	c.Assert(info.Percent, Equals, 50)
+}
+
+func (s *GobacklightSuite) TestDaemonRefuseKo(c *C) {
+	root := c.MkDir()
+	makeDevice(c, root, "intel_backlight", "raw")
+	d, err := backlight.Open(root, "intel_backlight")
+	if err != nil {
+		c.Fatal(err)
+	}
+	dm := &Daemon{Backlight: d, Options: Options{Curve: "auto"}}
+
+	for _, device := range []string{"acpi_video0", "leds:auto", "backlight:acpi_video0"} {
+		resp := dm.Handle(context.Background(), Request{Command: "get", Device: device})
+		c.Assert(resp, DeepEquals, Response{Error: "Error the daemon controls the device intel_backlight", Refused: true})
+	}
+	for _, options := range []Options{
+		{Curve: "cie1931"},
+		{Curve: "auto", BatteryMax: "40%"},
+		{Curve: "auto", BatteryCaps: "20:40"},
+		{Curve: "auto", NoLogind: true},
+	} {
+		resp := dm.Handle(context.Background(), Request{Command: "set", Value: "20%", Options: &options})
+		c.Assert(resp, DeepEquals, Response{Error: optionsMsg, Refused: true}, Commentf("options %+v", options))
+	}
+	c.Assert(d.Brightness, Equals, int64(500))
+
+	// the requests without options get the ones of the daemon
+	for _, req := range []Request{
+		{Command: "get", Device: "backlight:intel_backlight"},
+		{Command: "get", Options: &Options{Curve: "auto"}},
+		{Command: "get"},
+	} {
+		resp := dm.Handle(context.Background(), req)
+		c.Assert(resp.Error, Equals, "")
+		c.Assert(resp.Refused, Equals, false)
+	}
+}
+
+func (s *GobacklightSuite) TestDaemonRefusedOk(c *C) {
+	conf, path, stop := startDaemon(c)
+	defer stop()
+	defer func(path string) { syspath = path }(syspath)
+	syspath = filepath.Dir(path) + "/"
+	makeDevice(c, syspath, "acpi_video0", "firmware")
+
+	// the requests for another device run on it directly
+	conf.Device = "acpi_video0"
+	conf.IncCommand.Args.Percent = "10"
+	bc := BrightnessControl{Config: &conf, Command: "inc"}
+	_, err := bc.Run(context.Background())
+	c.Assert(err, IsNil)
+	c.Assert(bc.response, IsNil)
+	c.Assert(bc.Backlight, NotNil)
+	c.Assert(bc.Backlight.Name, Equals, "acpi_video0")
+	c.Assert(bc.Backlight.Brightness, Equals, int64(600))
+
+	// and so do the requests with other options than the daemon
+	conf.Device = "intel_backlight"
+	conf.Curve = "cie1931"
+	conf.SetCommand.Args.Value = "30%"
+	bc = BrightnessControl{Config: &conf, Command: "set"}
+	_, err = bc.Run(context.Background())
+	c.Assert(err, IsNil)
+	c.Assert(bc.response, IsNil)
+	c.Assert(bc.Backlight, NotNil)
+	c.Assert(bc.Backlight.Brightness, Equals, backlight.CIE1931Curve{}.Raw(30, 1000))
+
+	bc = BrightnessControl{Config: &conf, Command: "get"}
+	_, err = bc.Run(context.Background())
+	c.Assert(err, IsNil)
+	c.Assert(bc.response, IsNil)
+	c.Assert(bc.Backlight, NotNil)
+
+	// the cap of the client is not lost on a daemon without caps
+	defer func(path string) { powerpath = path }(powerpath)
+	powerpath = c.MkDir()
+	makeSupplies(c, "0")
+	conf.Curve = ""
+	conf.BatteryMax = "40%"
+	conf.SetCommand.Args.Value = "100%"
+	bc = BrightnessControl{Config: &conf, Command: "set"}
+	_, err = bc.Run(context.Background())
+	c.Assert(err, IsNil)
+	c.Assert(bc.response, IsNil)
+	c.Assert(bc.Backlight, NotNil)
+	c.Assert(bc.Backlight.Brightness, Equals, int64(400))
+}
+
+func (s *GobacklightSuite) TestDaemonNoDaemonOk(c *C) {
+	conf, _, stop := startDaemon(c)
+	defer stop()
+
+	conf.Device = ""
+	conf.NoDaemon = true
+	bc := BrightnessControl{Config: &conf, Command: "get"}
+	_, err := bc.Run(context.Background())
+
+	c.Assert(err, IsNil)
+	c.Assert(bc.Backlight, Not(IsNil))
+}
+
+func (s *GobacklightSuite) TestDaemonRunningKo(c *C) {
+	conf, _, stop := startDaemon(c)
+	defer stop()
+
+	_, err := listen(conf.Socket)
+	c.Assert(err, ErrorMatches, runningMsg)
+}
+
+func (s *GobacklightSuite) TestDaemonNotSocketKo(c *C) {
+	// a mistyped socket path is not removed
+	path := filepath.Join(c.MkDir(), "notes.txt")
+	if err := ioutil.WriteFile(path, []byte("notes\n"), 0644); err != nil {
+		c.Fatal(err)
+	}
+	_, err := listen(path)
+	c.Assert(err, ErrorMatches, "Error .*notes.txt exists and is not a socket")
+	data, err := ioutil.ReadFile(path)
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Equals, "notes\n")
+}
+
+func (s *GobacklightSuite) TestDaemonStaleSocketOk(c *C) {
+	// the socket of a daemon which didn't stop cleanly is replaced
+	path := filepath.Join(c.MkDir(), "gobacklight.sock")
+	stale, err := net.Listen("unix", path)
+	c.Assert(err, IsNil)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	l, err := listen(path)
+	c.Assert(err, IsNil)
+	l.Close()
+}
+
+func (s *GobacklightSuite) TestDaemonSocketOk(c *C) {
+	defer os.Setenv("XDG_RUNTIME_DIR", os.Getenv("XDG_RUNTIME_DIR"))
+	os.Setenv("XDG_RUNTIME_DIR", "/run/user/1000")
+	c.Assert(defaultSocket(), Equals, "/run/user/1000/gobacklight.sock")
+	os.Unsetenv("XDG_RUNTIME_DIR")
+	c.Assert(defaultSocket(), Equals, filepath.Join(os.TempDir(), fmt.Sprintf("gobacklight-%d", os.Getuid()), "gobacklight.sock"))
+
+	// the folder of the socket is created private
+	path := filepath.Join(c.MkDir(), "gobacklight-1000", "gobacklight.sock")
+	l, err := listen(path)
+	c.Assert(err, IsNil)
+	defer l.Close()
+	info, err := os.Stat(filepath.Dir(path))
+	c.Assert(err, IsNil)
+	c.Assert(info.Mode().Perm(), Equals, os.FileMode(0700))
+}
+
+func (s *GobacklightSuite) TestDaemonOwnerKo(c *C) {
+	if runtime.GOOS != "linux" {
+		c.Skip("the owner of the socket is only checked on linux")
+	}
+	info, err := os.Stat(c.MkDir())
+	c.Assert(err, IsNil)
+	c.Assert(owned(info), Equals, true)
+
+	// a socket of root poses as the daemon for the other users
+	info, err = os.Stat("/")
+	c.Assert(err, IsNil)
+	if os.Getuid() == 0 {
+		c.Skip("root owns the files of the other users")
+	}
+	c.Assert(owned(info), Equals, false)
+	conf := Config{Socket: "/"}
+	bc := BrightnessControl{Config: &conf, Command: "get"}
+	_, err = bc.Run(context.Background())
+	c.Assert(err, ErrorMatches, "Error the socket / belongs to another user, try --no-daemon")
+}
+
+func (s *GobacklightSuite) TestDaemonCapsKo(c *C) {
+	root := c.MkDir()
+	makeDevice(c, root, "intel_backlight", "raw")
+	d, err := backlight.Open(root, "intel_backlight")
+	c.Assert(err, IsNil)
+
+	// an invalid profile doesn't leave a socket behind, even for a device opened already
+	conf := Config{Device: "intel_backlight", Socket: filepath.Join(c.MkDir(), "gobacklight.sock"), ACMax: "bright"}
+	bc := BrightnessControl{Config: &conf, Command: "daemon", Backlight: d}
+	_, err = bc.Run(context.Background())
+
+	c.Assert(err, ErrorMatches, "Error ac profile : "+percentMsg)
+	_, err = os.Stat(conf.Socket)
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *GobacklightSuite) TestDaemonProtocolOk(c *C) {
+	conf, _, stop := startDaemon(c)
+	defer stop()
+
+	conn, err := net.Dial("unix", conf.Socket)
+	if err != nil {
+		c.Fatal(err)
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for _, t := range []struct {
+		request  string
+		response Response
+	}{
+		{`{"command":"fade","value":"25%","duration":"5ms"}`, Response{Percent: 25}},
+		{`{"command":"dec","value":"5%"}`, Response{Percent: 45}},
+		{`{"command":"fade","value":"25%"}`, Response{Error: `time: invalid duration ""`}},
+		{`{"command":"blink"}`, Response{Error: commandMsg}},
+		{`blink`, Response{Error: requestMsg}},
+	} {
+		if _, err := conn.Write([]byte(t.request + "\n")); err != nil {
+			c.Fatal(err)
+		}
+		line, err := r.ReadBytes('\n')
+		c.Assert(err, IsNil)
+
+		var resp Response
+		c.Assert(json.Unmarshal(line, &resp), IsNil)
+		resp.Device = nil
+		c.Assert(resp, DeepEquals, t.response, Commentf("request %s", t.request))
+	}
+}
+
+func (s *GobacklightSuite) TestDaemonSubscribeOk(c *C) {
+	conf, path, stop := startDaemon(c)
+	defer stop()
+
+	conn, err := net.Dial("unix", conf.Socket)
+	if err != nil {
+		c.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(`{"command":"subscribe"}` + "\n")); err != nil {
+		c.Fatal(err)
+	}
+	dec := json.NewDecoder(conn)
+	var resp Response
+	c.Assert(dec.Decode(&resp), IsNil)
+	c.Assert(resp.Percent, Equals, 50)
+
+	// the kernel updates actual_brightness in place, the fake sysfs doesn't
+	f, err := os.OpenFile(filepath.Join(path, "actual_brightness"), os.O_WRONLY, 0644)
+	if err != nil {
+		c.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString("800\n"); err != nil {
+		c.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	var e backlight.Event
+	for e.ActualBrightness != 800 {
+		c.Assert(dec.Decode(&e), IsNil)
+	}
+	c.Assert(e.Source, Equals, backlight.SourceSoftware)
+}
+
+func (s *GobacklightSuite) TestDaemonRetargetOk(c *C) {
+	b := &countingBackend{Backend: &backlight.Fake{Brightness: 50, Max: 100}, written: make(chan int64, 1)}
+	d, err := backlight.NewDevice("fake", b)
+	if err != nil {
+		c.Fatal(err)
+	}
+	conf, _, stop := serveDaemon(c, d)
+	defer stop()
+
+	// a long fade, retargeted by the next write instead of delaying it
+	fade := make(chan error)
+	faded := conf
+	faded.Fade = time.Minute
+	faded.SetCommand.Args.Value = "100%"
+	go func() {
+		bc := BrightnessControl{Config: &faded, Command: "set"}
+		_, err := bc.Run(context.Background())
+		fade <- err
+	}()
+	select {
+	case <-b.written:
+	case <-time.After(time.Second):
+		c.Fatal("the fade didn't start")
+	}
+
+	conf.SetCommand.Args.Value = "20%"
+	bc := BrightnessControl{Config: &conf, Command: "set"}
+	_, err = bc.Run(context.Background())
+	c.Assert(err, IsNil)
+
+	select {
+	case err := <-fade:
+		c.Assert(err, IsNil)
+	case <-time.After(time.Second):
+		c.Fatal("the fade was not retargeted")
+	}
+	percent, _ := bc.clamped()
+	c.Assert(percent, Equals, 20)
+}
+
+func (s *GobacklightSuite) TestDaemonWatchOk(c *C) {
+	b := &countingBackend{Backend: &backlight.Fake{Brightness: 50, Max: 100}, read: make(chan struct{}, 1)}
+	d, err := backlight.NewDevice("fake", b)
+	if err != nil {
+		c.Fatal(err)
+	}
+	<-b.read
+	conf, dm, stop := serveDaemon(c, d)
+	defer stop()
+
+	// the device is not watched without subscribers
+	dm.smu.Lock()
+	c.Assert(dm.watched, IsNil)
+	dm.smu.Unlock()
+	c.Assert(b.Reads(), Equals, 1)
+
+	conn, err := net.Dial("unix", conf.Socket)
+	if err != nil {
+		c.Fatal(err)
+	}
+	if _, err := conn.Write([]byte(`{"command":"subscribe"}` + "\n")); err != nil {
+		c.Fatal(err)
+	}
+	var resp Response
+	c.Assert(json.NewDecoder(conn).Decode(&resp), IsNil)
+	dm.smu.Lock()
+	watched := dm.watched
+	dm.smu.Unlock()
+	c.Assert(watched, NotNil)
+	// the subscriber gets the device polled
+	for i := 0; i < 10; i++ {
+		select {
+		case <-b.read:
+		case <-time.After(time.Second):
+			c.Fatal("the device is not polled")
+		}
+	}
+
+	// and the last subscriber stops the watch
+	conn.Close()
+	select {
+	case <-watched:
+	case <-time.After(time.Second):
+		c.Fatal("the watch didn't stop")
+	}
+}
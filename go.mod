@@ -0,0 +1,15 @@
+module github.com/rustx/gobacklight
+
+go 1.20
+
+require (
+	github.com/godbus/dbus/v5 v5.2.2
+	github.com/jessevdk/go-flags v1.6.1
+	gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c
+)
+
+require (
+	github.com/kr/pretty v0.2.1 // indirect
+	github.com/kr/text v0.1.0 // indirect
+	golang.org/x/sys v0.27.0 // indirect
+)
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"io/ioutil"
 	"math/rand"
 	"os"
@@ -9,6 +11,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/rustx/gobacklight/backlight"
 	. "gopkg.in/check.v1"
 )
 
@@ -27,6 +30,10 @@ func (s *GobacklightSuite) SetUpSuite(c *C) error {
 	s.files = [3]string{"brightness", "actual_brightness", "max_brightness"}
 
 	syspath = filepath.Join(s.dir, "intel_backlight") + "/"
+	sockpath = filepath.Join(s.dir, "gobacklight.sock")
+	statepath = filepath.Join(s.dir, "state", "model.json")
+	iiopath, powerpath = c.MkDir(), c.MkDir()
+	busaddr = "unix:path=" + filepath.Join(s.dir, "system_bus_socket")
 	if err := os.Mkdir(syspath, 0755); err != nil {
 		c.Fatal(err)
 	}
@@ -61,6 +68,8 @@ func (s *GobacklightSuite) SetUpSuite(c *C) error {
 }
 
 func (s *GobacklightSuite) TearDownTest(c *C) {
+	// the state files of a test, like the battery cap held, don't leak into the next ones
+	os.RemoveAll(filepath.Dir(statepath))
 	os.Chmod(s.dir, 0755)
 	os.Chmod(syspath, 0755)
 
@@ -120,7 +129,7 @@ func (s *GobacklightSuite) TestConfig(c *C) {
 		Inc: value,
 	}
 
-	ic := BrightnessControl{Config: &conf, Path: syspath}
+	ic := BrightnessControl{Config: &conf}
 
 	c.Assert(ic.Config.Inc, Equals, value)
 	c.Assert(ic.Config.Dec, Equals, uint(0))
@@ -134,7 +143,7 @@ func (s *GobacklightSuite) TestGetConfig(c *C) {
 		Get: value,
 	}
 
-	gc := BrightnessControl{Config: &conf, Path: syspath}
+	gc := BrightnessControl{Config: &conf}
 
 	c.Assert(gc.Config.Get, Equals, value)
 	c.Assert(gc.Config.Set, Equals, uint(0))
@@ -148,7 +157,7 @@ func (s *GobacklightSuite) TestSetConfig(c *C) {
 		Set: value,
 	}
 
-	sc := BrightnessControl{Config: &conf, Path: syspath}
+	sc := BrightnessControl{Config: &conf}
 
 	c.Assert(sc.Config.Set, Equals, value)
 	c.Assert(sc.Config.Dec, Equals, uint(0))
@@ -162,7 +171,7 @@ func (s *GobacklightSuite) TestDecConfig(c *C) {
 		Dec: value,
 	}
 
-	dc := BrightnessControl{Config: &conf, Path: syspath}
+	dc := BrightnessControl{Config: &conf}
 
 	c.Assert(dc.Config.Dec, Equals, value)
 	c.Assert(dc.Config.Inc, Equals, uint(0))
@@ -178,7 +187,7 @@ func (s *GobacklightSuite) TestGetAndDecConfig(c *C) {
 		Get: true,
 	}
 
-	gd := BrightnessControl{Config: &conf, Path: syspath}
+	gd := BrightnessControl{Config: &conf}
 
 	c.Assert(gd.Config.Dec, Equals, value)
 	c.Assert(gd.Config.Inc, Equals, uint(0))
@@ -186,237 +195,13 @@ func (s *GobacklightSuite) TestGetAndDecConfig(c *C) {
 	c.Assert(gd.Config.Get, Equals, true)
 }
 
-func (s *GobacklightSuite) TestCheckDeviceFilePathOk(c *C) {
-	conf := Config{
-		Get: true,
-	}
-	bc := BrightnessControl{Config: &conf, Path: syspath}
-
-	files, err := checkDevice(syspath)
-
-	c.Assert(err, IsNil)
-	c.Assert(files, HasLen, 3)
-	c.Assert(bc.MaxBrightness, Not(Equals), 0)
-	c.Assert(bc.ActualBrightness, Not(Equals), 0)
-	c.Assert(bc.Brightness, Not(Equals), 0)
-}
-
-func (s *GobacklightSuite) TestCheckDeviceFilePathPermissionKo(c *C) {
-	if err := os.Chmod(syspath, 0300); err != nil {
-		c.Fatal("Error chmod test_data directory")
-	}
-
-	files, err := checkDevice(syspath)
-
-	c.Assert(err, Not(IsNil))
-	c.Assert(files, HasLen, 0)
-	c.Assert(err, ErrorMatches, "open .*: permission denied")
-}
-
-func (s *GobacklightSuite) TestReadFileOk(c *C) {
-	for _, f := range s.files {
-		value, err := readFile(syspath + f)
-		c.Assert(err, IsNil)
-		c.Assert(value, Not(Equals), "")
-		c.Assert(value, Not(HasLen), 0)
-	}
-}
-
-func (s *GobacklightSuite) TestReadFilePermissionKo(c *C) {
-	for _, f := range s.files {
-		if err := os.Chmod(syspath+f, 0300); err != nil {
-			c.Fatal(err)
-		}
-
-		value, err := readFile(syspath + f)
-		c.Assert(err, Not(IsNil))
-		c.Assert(value, Equals, "")
-		c.Assert(err, ErrorMatches, "open .*: permission denied")
-	}
-}
-
-func (s *GobacklightSuite) TestWriteFileToStringOk(c *C) {
-	err := writeStringToFile(syspath+"brightness", strconv.Itoa(500))
-	c.Assert(err, IsNil)
-}
-
-func (s *GobacklightSuite) TestWriteFileToStringFileKo(c *C) {
-	if err := os.Remove(syspath + "brightness"); err != nil {
-		c.Fatal("error chmod file ")
-	}
-	err := writeStringToFile(syspath+"brightness", strconv.Itoa(500))
-	c.Assert(err, Not(IsNil))
-}
-
-func (s *GobacklightSuite) TestLoadParamsFilePathOk(c *C) {
-	bc := BrightnessControl{Path: syspath}
-	files, _ := checkDevice(syspath)
-	err := bc.LoadParams(files)
-
-	c.Assert(err, IsNil)
-	c.Assert(bc.MaxBrightness, Equals, int64(1000))
-	c.Assert(bc.Brightness, Equals, bc.ActualBrightness)
-	c.Assert(bc.Brightness, Not(Equals), 0)
-	c.Assert(bc.ActualBrightness, Not(Equals), 0)
-}
-
-func (s *GobacklightSuite) TestLoadParamsFilePathKo(c *C) {
-	bc := BrightnessControl{Path: syspath}
-	files, errdev := checkDevice("/tmp")
-	errlp := bc.LoadParams(files)
-
-	c.Assert(errdev, Not(IsNil))
-	c.Assert(errdev, ErrorMatches, driverMsg)
-
-	c.Assert(errlp, Not(IsNil))
-	c.Assert(errlp, ErrorMatches, driverMsg)
-
-	c.Assert(bc.Brightness, Equals, int64(0))
-	c.Assert(bc.MaxBrightness, Equals, int64(0))
-	c.Assert(bc.ActualBrightness, Equals, int64(0))
-}
-
-func (s *GobacklightSuite) TestLoadParamsFileContentMaxKo(c *C) {
-	bc := BrightnessControl{Path: syspath}
-	files, _ := checkDevice(syspath)
-
-	for _, f := range s.files {
-		if f == "max_brightness" {
-			if f, err := os.OpenFile(bc.Path+f, os.O_WRONLY|os.O_TRUNC, 0644); err != nil {
-				c.Fatal(err)
-			} else {
-				defer f.Close()
-				if _, err := f.WriteString("a"); err != nil {
-					c.Fatal(err)
-				} else {
-					f.Sync()
-					err := bc.LoadParams(files)
-					c.Assert(err, Not(IsNil)) // strconv value type error
-					c.Assert(err, ErrorMatches, "strconv.ParseInt:.*")
-				}
-			}
-		}
-	}
-}
-
-func (s *GobacklightSuite) TestLoadParamsFileContentActualKo(c *C) {
-	bc := BrightnessControl{Path: syspath}
-	files, _ := checkDevice(syspath)
-
-	for _, f := range s.files {
-		if f == "actual_brightness" {
-			if f, err := os.OpenFile(bc.Path+f, os.O_WRONLY|os.O_TRUNC, 0644); err != nil {
-				c.Fatal(err)
-			} else {
-				defer f.Close()
-				if err := os.Truncate(f.Name(), 0); err != nil {
-					c.Fatal(err)
-				}
-				if _, err := f.WriteString("a"); err != nil {
-					c.Fatal(err)
-				} else {
-					f.Sync()
-					err := bc.LoadParams(files)
-					c.Assert(err, Not(IsNil)) // strconv value type error
-					c.Assert(err, ErrorMatches, "strconv.ParseInt:.*")
-				}
-			}
-		}
-	}
-}
-
-func (s *GobacklightSuite) TestLoadParamsFileContentBrightKo(c *C) {
-	bc := BrightnessControl{Path: syspath}
-	files, _ := checkDevice(syspath)
-
-	for _, f := range s.files {
-		if f == "brightness" {
-			if f, err := os.OpenFile(bc.Path+f, os.O_WRONLY|os.O_TRUNC, 0644); err != nil {
-				c.Fatal(err)
-			} else {
-				defer f.Close()
-				if err := os.Truncate(f.Name(), 0); err != nil {
-					c.Fatal(err)
-				}
-				if _, err := f.WriteString("a"); err != nil {
-					c.Fatal(err)
-				} else {
-					f.Sync()
-					err := bc.LoadParams(files)
-					c.Assert(err, Not(IsNil)) // strconv value type error
-					c.Assert(err, ErrorMatches, "strconv.ParseInt:.*")
-				}
-			}
-		}
-	}
-}
-
-func (s *GobacklightSuite) TestLoadParamsFileContentEmpty(c *C) {
-	bc := BrightnessControl{Path: syspath}
-	files, _ := checkDevice(syspath)
-
-	for _, f := range s.files {
-		os.Remove(bc.Path + f)
-		if _, err := os.Create(bc.Path + f); err != nil {
-			c.Fatal(err)
-		}
-		err := bc.LoadParams(files)
-
-		c.Assert(err, Not(IsNil)) // ioutil read type error
-		c.Assert(err, ErrorMatches, "strconv.ParseInt:.*")
-	}
-}
-
-func (s *GobacklightSuite) TestLoadParamsFileRenamedKo(c *C) {
-	bc := BrightnessControl{Path: syspath}
-	files, _ := checkDevice(syspath)
-
-	c.Assert(files, HasLen, 3)
-
-	for _, f := range s.files {
-		if err := os.Rename(syspath+f, syspath+f+"_test"); err != nil {
-			c.Fatal("Error chmod test_data files : ", f)
-		} else {
-			err := bc.LoadParams(files)
-
-			c.Assert(err, Not(IsNil))
-			c.Assert(err, ErrorMatches, nofileMsg)
-		}
-		if err := os.Rename(syspath+f+"_test", syspath+f); err != nil {
-			c.Fatal("Error chmod test_data files : ", f)
-		}
-	}
-}
-
-func (s *GobacklightSuite) TestLoadParamsFileAbsentKo(c *C) {
-	bc := BrightnessControl{Path: syspath}
-
-	for _, f := range s.files {
-		if err := os.Rename(syspath+f, syspath+f+"_test"); err != nil {
-			c.Fatal("Error chmod test_data files : ", f)
-		}
-	}
-
-	files, _ := ioutil.ReadDir(syspath)
-
-	err := bc.LoadParams(files)
-	c.Assert(err, Not(IsNil))
-	c.Assert(err, ErrorMatches, "Error no proper files on driver folder")
-
-	for _, f := range s.files {
-		if err := os.Rename(syspath+f+"_test", syspath+f); err != nil {
-			c.Fatal("Error chmod test_data files : ", f)
-		}
-	}
-}
-
 func (s *GobacklightSuite) TestValidateOptionsGetOk(c *C) {
 	value := true
 	conf := Config{
 		Get: value,
 	}
 
-	bc := BrightnessControl{Config: &conf, Path: syspath}
+	bc := BrightnessControl{Config: &conf}
 	err := bc.ValidateOptions("get")
 
 	c.Assert(err, IsNil)
@@ -428,7 +213,7 @@ func (s *GobacklightSuite) TestValidateOptionsIncOk(c *C) {
 		Inc: value,
 	}
 
-	bc := BrightnessControl{Config: &conf, Path: syspath}
+	bc := BrightnessControl{Config: &conf}
 	err := bc.ValidateOptions("inc")
 
 	c.Assert(err, IsNil)
@@ -440,7 +225,7 @@ func (s *GobacklightSuite) TestValidateOptionsDecOk(c *C) {
 		Dec: value,
 	}
 
-	bc := BrightnessControl{Config: &conf, Path: syspath}
+	bc := BrightnessControl{Config: &conf}
 	err := bc.ValidateOptions("dec")
 
 	c.Assert(err, IsNil)
@@ -452,7 +237,7 @@ func (s *GobacklightSuite) TestValidateOptionsSetOk(c *C) {
 		Set: value,
 	}
 
-	bc := BrightnessControl{Config: &conf, Path: syspath}
+	bc := BrightnessControl{Config: &conf}
 	err := bc.ValidateOptions("set")
 
 	c.Assert(err, IsNil)
@@ -465,7 +250,7 @@ func (s *GobacklightSuite) TestValidateOptionsGetKo(c *C) {
 		Set: uint(25),
 	}
 
-	bc := BrightnessControl{Config: &conf, Path: syspath}
+	bc := BrightnessControl{Config: &conf}
 	err := bc.ValidateOptions("get")
 
 	c.Assert(err, Not(IsNil))
@@ -479,7 +264,7 @@ func (s *GobacklightSuite) TestValidateOptionsIncKo(c *C) {
 		Get: true,
 	}
 
-	bc := BrightnessControl{Config: &conf, Path: syspath}
+	bc := BrightnessControl{Config: &conf}
 	err := bc.ValidateOptions("inc")
 
 	c.Assert(err, Not(IsNil))
@@ -493,7 +278,7 @@ func (s *GobacklightSuite) TestValidateOptionsSetKo(c *C) {
 		Get: true,
 	}
 
-	bc := BrightnessControl{Config: &conf, Path: syspath}
+	bc := BrightnessControl{Config: &conf}
 	err := bc.ValidateOptions("set")
 
 	c.Assert(err, Not(IsNil))
@@ -507,50 +292,70 @@ func (s *GobacklightSuite) TestValidateOptionsDecKo(c *C) {
 		Get: true,
 	}
 
-	bc := BrightnessControl{Config: &conf, Path: syspath}
+	bc := BrightnessControl{Config: &conf}
 	err := bc.ValidateOptions("dec")
 
 	c.Assert(err, Not(IsNil))
 	c.Assert(err, ErrorMatches, combinedMsg)
 }
 
-func (s *GobacklightSuite) TestValidateOptionsSetValueKo(c *C) {
-	value := uint(105)
+func (s *GobacklightSuite) TestValidateOptionsToOk(c *C) {
 	conf := Config{
-		Set: value,
+		To: "+5%",
 	}
 
-	bc := BrightnessControl{Config: &conf, Path: syspath}
-	err := bc.ValidateOptions("set")
+	bc := BrightnessControl{Config: &conf}
+	err := bc.ValidateOptions("to")
+
+	c.Assert(err, IsNil)
+}
+
+func (s *GobacklightSuite) TestValidateOptionsToKo(c *C) {
+	conf := Config{
+		To:  "+5%",
+		Set: uint(25),
+	}
+
+	bc := BrightnessControl{Config: &conf}
+	err := bc.ValidateOptions("to")
 
 	c.Assert(err, Not(IsNil))
-	c.Assert(err, ErrorMatches, setMsg)
+	c.Assert(err, ErrorMatches, combinedMsg)
+}
+
+func (s *GobacklightSuite) TestValidateOptionsToValueKo(c *C) {
+	conf := Config{
+		To: "bright",
+	}
+
+	bc := BrightnessControl{Config: &conf}
+	err := bc.ValidateOptions("to")
+
+	c.Assert(err, Equals, backlight.ErrValue)
 }
 
-func (s *GobacklightSuite) TestValidateOptionsIncValueKo(c *C) {
+func (s *GobacklightSuite) TestValidateOptionsIncValueOk(c *C) {
 	value := uint(105)
 	conf := Config{
 		Inc: value,
 	}
 
-	bc := BrightnessControl{Config: &conf, Path: syspath}
+	bc := BrightnessControl{Config: &conf}
 	err := bc.ValidateOptions("inc")
 
-	c.Assert(err, Not(IsNil))
-	c.Assert(err, ErrorMatches, valueMsg)
+	c.Assert(err, IsNil)
 }
 
-func (s *GobacklightSuite) TestValidateOptionsDecValueKo(c *C) {
+func (s *GobacklightSuite) TestValidateOptionsDecValueOk(c *C) {
 	value := uint(105)
 	conf := Config{
 		Dec: value,
 	}
 
-	bc := BrightnessControl{Config: &conf, Path: syspath}
+	bc := BrightnessControl{Config: &conf}
 	err := bc.ValidateOptions("dec")
 
-	c.Assert(err, Not(IsNil))
-	c.Assert(err, ErrorMatches, valueMsg)
+	c.Assert(err, IsNil)
 }
 
 func (s *GobacklightSuite) TestValidateOptionsDefaultValueKo(c *C) {
@@ -559,202 +364,183 @@ func (s *GobacklightSuite) TestValidateOptionsDefaultValueKo(c *C) {
 		Dec: value,
 	}
 
-	bc := BrightnessControl{Config: &conf, Path: syspath}
+	bc := BrightnessControl{Config: &conf}
 	err := bc.ValidateOptions("test")
 
 	c.Assert(err, Not(IsNil))
 	c.Assert(err, ErrorMatches, nilMsg)
 }
 
-func (s *GobacklightSuite) TestGetActionOk(c *C) {
-	conf := Config{
-		Get: true,
-	}
-	bc := BrightnessControl{Config: &conf, Path: syspath}
-
-	files, _ := checkDevice(bc.Path)
-
-	_ = bc.LoadParams(files)
+func (s *GobacklightSuite) TestInitOk(c *C) {
+	conf := Config{}
+	bc := BrightnessControl{Config: &conf}
+	err := bc.Init()
 
-	v, err := bc.Get()
 	c.Assert(err, IsNil)
-	c.Assert(v, Not(IsNil))
-	c.Assert(v, Not(Equals), 0)
+	c.Assert(bc.Backlight, Not(IsNil))
+	c.Assert(bc.Backlight.Path, Equals, filepath.Join(syspath, bc.Device))
+	c.Assert(bc.Backlight.MaxBrightness, Equals, int64(1000))
+	c.Assert(bc.Backlight.Brightness, Equals, bc.Backlight.ActualBrightness)
 }
 
-func (s *GobacklightSuite) TestIncActionOk(c *C) {
+func (s *GobacklightSuite) TestInitFadeOk(c *C) {
 	conf := Config{
-		Inc: uint(5),
+		Fade:         300 * time.Millisecond,
+		FadeInterval: 10 * time.Millisecond,
+		Easing:       "ease-in-out",
 	}
-	bc := BrightnessControl{Config: &conf, Path: syspath}
-
-	files, _ := checkDevice(bc.Path)
-
-	_ = bc.LoadParams(files)
+	bc := BrightnessControl{Config: &conf}
+	err := bc.Init()
 
-	err := bc.Inc()
 	c.Assert(err, IsNil)
+	c.Assert(bc.Backlight.Fade.Duration, Equals, 300*time.Millisecond)
+	c.Assert(bc.Backlight.Fade.Interval, Equals, 10*time.Millisecond)
+	c.Assert(bc.Backlight.Fade.Easing, Not(IsNil))
 }
 
-func (s *GobacklightSuite) TestDecActionOk(c *C) {
+func (s *GobacklightSuite) TestInitCurveOk(c *C) {
 	conf := Config{
-		Dec: uint(5),
+		Curve:    "exponential",
+		Exponent: 2.2,
 	}
-	bc := BrightnessControl{Config: &conf, Path: syspath}
-
-	files, _ := checkDevice(bc.Path)
-
-	_ = bc.LoadParams(files)
+	bc := BrightnessControl{Config: &conf}
+	err := bc.Init()
 
-	err := bc.Dec()
 	c.Assert(err, IsNil)
+	c.Assert(bc.Backlight.Curve, Equals, backlight.ExponentialCurve{Exponent: 2.2})
 }
 
-func (s *GobacklightSuite) TestSetActionOk(c *C) {
+func (s *GobacklightSuite) TestInitCurveAutoOk(c *C) {
 	conf := Config{
-		Set: uint(25),
+		Curve: "auto",
 	}
-	bc := BrightnessControl{Config: &conf, Path: syspath}
-
-	files, _ := checkDevice(bc.Path)
-
-	_ = bc.LoadParams(files)
+	bc := BrightnessControl{Config: &conf}
+	err := bc.Init()
 
-	err := bc.Set()
 	c.Assert(err, IsNil)
+	c.Assert(bc.Backlight.Curve, IsNil)
 }
 
-func (s *GobacklightSuite) TestIncActionFileKo(c *C) {
+func (s *GobacklightSuite) TestInitCurveKo(c *C) {
 	conf := Config{
-		Inc: uint(5),
-	}
-	bc := BrightnessControl{Config: &conf, Path: syspath}
-
-	files, _ := checkDevice(bc.Path)
-
-	_ = bc.LoadParams(files)
-
-	if err := os.Remove(syspath + "brightness"); err != nil {
-		c.Fatal("error remove file ")
+		Curve: "gamma",
 	}
+	bc := BrightnessControl{Config: &conf}
+	err := bc.Init()
 
-	err := bc.Inc()
-	c.Assert(err, Not(IsNil))
-	c.Assert(err, ErrorMatches, nofileMsg)
+	c.Assert(err, ErrorMatches, "Error unknown curve gamma")
+	c.Assert(bc.Backlight, IsNil)
 }
 
-func (s *GobacklightSuite) TestDecActionFileKo(c *C) {
+func (s *GobacklightSuite) TestInitLimitsOk(c *C) {
 	conf := Config{
-		Dec: uint(5),
+		Curve: "linear",
+		Min:   10,
+		Max:   90,
 	}
-	bc := BrightnessControl{Config: &conf, Path: syspath}
-
-	files, _ := checkDevice(bc.Path)
+	bc := BrightnessControl{Config: &conf}
+	err := bc.Init()
 
-	_ = bc.LoadParams(files)
+	c.Assert(err, IsNil)
+	c.Assert(bc.Backlight.Min, Equals, int64(100))
+	c.Assert(bc.Backlight.Max, Equals, int64(900))
+}
 
-	if err := os.Remove(syspath + "brightness"); err != nil {
-		c.Fatal("error remove file ")
+func (s *GobacklightSuite) TestInitLimitsKo(c *C) {
+	conf := Config{
+		Min: 50,
+		Max: 40,
 	}
+	bc := BrightnessControl{Config: &conf}
+	err := bc.Init()
 
-	err := bc.Dec()
-	c.Assert(err, Not(IsNil))
-	c.Assert(err, ErrorMatches, nofileMsg)
+	c.Assert(err, ErrorMatches, rangeMsg)
+	c.Assert(bc.Backlight, IsNil)
 }
 
-func (s *GobacklightSuite) TestSetActionFileKo(c *C) {
+func (s *GobacklightSuite) TestInitFloorOk(c *C) {
 	conf := Config{
-		Set: uint(25),
+		Curve:    "linear",
+		Floor:    []string{"acpi_video0=50", "2%"},
+		AllowOff: true,
 	}
-	bc := BrightnessControl{Config: &conf, Path: syspath}
+	bc := BrightnessControl{Config: &conf}
+	err := bc.Init()
 
-	files, _ := checkDevice(bc.Path)
+	c.Assert(err, IsNil)
+	c.Assert(bc.Backlight.Floor, Equals, int64(20))
+	c.Assert(bc.Backlight.AllowOff, Equals, true)
+}
 
-	_ = bc.LoadParams(files)
+func (s *GobacklightSuite) TestFloorForOk(c *C) {
+	d := &backlight.Device{Name: "intel_backlight", MaxBrightness: 1000, Curve: backlight.LinearCurve{}}
 
-	if err := os.Remove(syspath + "brightness"); err != nil {
-		c.Fatal(err)
-	}
+	floor, err := floorFor(d, nil)
+	c.Assert(err, IsNil)
+	c.Assert(floor, Equals, int64(0))
 
-	err := bc.Set()
-	c.Assert(err, Not(IsNil))
-	c.Assert(err, ErrorMatches, nofileMsg)
+	floor, err = floorFor(d, []string{"intel_backlight=30", "5%"})
+	c.Assert(err, IsNil)
+	c.Assert(floor, Equals, int64(30))
+
+	floor, err = floorFor(d, []string{"dell_backlight=30", "0.5%"})
+	c.Assert(err, IsNil)
+	c.Assert(floor, Equals, int64(5))
 }
 
-func (s *GobacklightSuite) TestInitOk(c *C) {
-	conf := Config{}
-	bc := BrightnessControl{Config: &conf}
-	bc.Init()
+func (s *GobacklightSuite) TestFloorForKo(c *C) {
+	d := &backlight.Device{Name: "intel_backlight", MaxBrightness: 1000}
 
-	c.Assert(bc, Not(IsNil))
-	c.Assert(bc.Path, Not(IsNil))
-	c.Assert(bc.Device, Not(IsNil))
-	c.Assert(bc.Path, Equals, syspath+bc.Device+"/")
-	c.Assert(bc.Brightness, Not(Equals), uint(0))
-	c.Assert(bc.MaxBrightness, Not(Equals), uint(0))
-	c.Assert(bc.ActualBrightness, Not(Equals), uint(0))
+	for _, f := range []string{"a", "-5", "+5%", "150%", "2000", "max", "intel_backlight=x%"} {
+		_, err := floorFor(d, []string{f})
+		c.Assert(err, ErrorMatches, floorMsg)
+	}
 }
 
-func (s *GobacklightSuite) TestInitDevicePermissionKo(c *C) {
-	conf := Config{}
-	bc := BrightnessControl{Config: &conf}
+func (s *GobacklightSuite) TestInitAutoOk(c *C) {
+	defer func(path string) { syspath = path }(syspath)
+	syspath = c.MkDir() + "/"
 
-	if err := os.Chmod(syspath+bc.Device, 0300); err != nil {
-		c.Fatal(err)
-	}
+	makeDevice(c, syspath, "intel_backlight", "raw")
+	makeDevice(c, syspath, "acpi_video0", "firmware")
+
+	conf := Config{Device: "auto"}
+	bc := BrightnessControl{Config: &conf}
 	err := bc.Init()
 
-	c.Assert(err, Not(IsNil))
-	c.Assert(bc, Not(IsNil))
-	c.Assert(bc.Path, Not(IsNil))
-	c.Assert(bc.Device, Not(IsNil))
-	c.Assert(bc.Path, Equals, syspath+bc.Device+"/")
-	c.Assert(bc.Brightness, Equals, int64(0))
-	c.Assert(bc.MaxBrightness, Equals, int64(0))
-	c.Assert(bc.ActualBrightness, Equals, int64(0))
+	c.Assert(err, IsNil)
+	c.Assert(bc.Backlight.Name, Equals, "acpi_video0")
+	c.Assert(bc.Backlight.Path, Equals, syspath+"acpi_video0")
+	c.Assert(bc.Backlight.MaxBrightness, Equals, int64(1000))
+	c.Assert(bc.Backlight.ActualBrightness, Equals, int64(500))
 }
 
-func (s *GobacklightSuite) TestInitDeviceDirKo(c *C) {
+func (s *GobacklightSuite) TestInitDevicePermissionKo(c *C) {
 	conf := Config{}
 	bc := BrightnessControl{Config: &conf}
 
-	if err := os.RemoveAll(syspath + bc.Device); err != nil {
+	if err := os.Chmod(syspath, 0300); err != nil {
 		c.Fatal(err)
 	}
 	err := bc.Init()
 
 	c.Assert(err, Not(IsNil))
-	c.Assert(err, ErrorMatches, "stat .* no such file or directory")
-	c.Assert(bc, Not(IsNil))
-	c.Assert(bc.Path, Not(IsNil))
-	c.Assert(bc.Device, Not(IsNil))
-	c.Assert(bc.Path, Equals, syspath+bc.Device+"/")
-	c.Assert(bc.Brightness, Equals, int64(0))
-	c.Assert(bc.MaxBrightness, Equals, int64(0))
-	c.Assert(bc.ActualBrightness, Equals, int64(0))
+	c.Assert(err, ErrorMatches, "open .*: permission denied")
+	c.Assert(bc.Backlight, IsNil)
 }
 
-func (s *GobacklightSuite) TestInitDeviceDirAFileKo(c *C) {
+func (s *GobacklightSuite) TestInitDeviceDirKo(c *C) {
 	conf := Config{}
 	bc := BrightnessControl{Config: &conf}
 
-	if err := os.RemoveAll(syspath + bc.Device); err != nil {
+	if err := os.RemoveAll(syspath); err != nil {
 		c.Fatal(err)
-		if _, err := os.Create(syspath + bc.Device); err != nil {
-			c.Fatal(err)
-		}
 	}
 	err := bc.Init()
 
 	c.Assert(err, Not(IsNil))
 	c.Assert(err, ErrorMatches, "stat .* no such file or directory")
-	c.Assert(bc, Not(IsNil))
-	c.Assert(bc.Path, Not(IsNil))
-	c.Assert(bc.Device, Not(IsNil))
-	c.Assert(bc.Path, Equals, syspath+bc.Device+"/")
-	c.Assert(bc.Brightness, Equals, int64(0))
-	c.Assert(bc.MaxBrightness, Equals, int64(0))
-	c.Assert(bc.ActualBrightness, Equals, int64(0))
+	c.Assert(bc.Backlight, IsNil)
 }
 
 func (s *GobacklightSuite) TestInitFilePermissionKo(c *C) {
@@ -769,13 +555,8 @@ func (s *GobacklightSuite) TestInitFilePermissionKo(c *C) {
 	err := bc.Init()
 
 	c.Assert(err, Not(IsNil))
-	c.Assert(bc, Not(IsNil))
-	c.Assert(bc.Path, Not(IsNil))
-	c.Assert(bc.Device, Not(IsNil))
-	c.Assert(bc.Path, Equals, syspath+bc.Device+"/")
-	c.Assert(bc.Brightness, Equals, int64(0))
-	c.Assert(bc.MaxBrightness, Equals, int64(0))
-	c.Assert(bc.ActualBrightness, Equals, int64(0))
+	c.Assert(err, ErrorMatches, "open .*: permission denied")
+	c.Assert(bc.Backlight, IsNil)
 }
 
 func (s *GobacklightSuite) TestRunGetOk(c *C) {
@@ -786,7 +567,7 @@ func (s *GobacklightSuite) TestRunGetOk(c *C) {
 	if err := bc.Init(); err != nil {
 		c.Fatal(err)
 	}
-	if v, err := bc.Run(); err != nil {
+	if v, err := bc.Run(context.Background()); err != nil {
 		c.Fatal(err)
 	} else {
 		c.Assert(err, IsNil)
@@ -801,7 +582,7 @@ func (s *GobacklightSuite) TestRunOptKo(c *C) {
 	if err := bc.Init(); err != nil {
 		c.Fatal(err)
 	}
-	v, err := bc.Run()
+	v, err := bc.Run(context.Background())
 
 	c.Assert(err, Not(IsNil))
 	c.Assert(err, ErrorMatches, nooptMsg)
@@ -818,7 +599,7 @@ func (s *GobacklightSuite) TestRunGetCombinedKo(c *C) {
 		c.Fatal(err)
 	}
 
-	v, err := bc.Run()
+	v, err := bc.Run(context.Background())
 	c.Assert(err, Not(IsNil))
 	c.Assert(v, Equals, "")
 	c.Assert(err, ErrorMatches, combinedMsg)
@@ -832,7 +613,7 @@ func (s *GobacklightSuite) TestRunIncOk(c *C) {
 	if err := bc.Init(); err != nil {
 		c.Fatal(err)
 	}
-	if v, err := bc.Run(); err != nil {
+	if v, err := bc.Run(context.Background()); err != nil {
 		c.Fatal(err)
 	} else {
 		c.Assert(err, IsNil)
@@ -841,19 +622,19 @@ func (s *GobacklightSuite) TestRunIncOk(c *C) {
 	}
 }
 
-func (s *GobacklightSuite) TestRunIncValueKo(c *C) {
+func (s *GobacklightSuite) TestRunIncValueOk(c *C) {
 	conf := Config{
-		Inc: uint(15),
+		Inc: uint(150),
 	}
 	bc := BrightnessControl{Config: &conf}
 	if err := bc.Init(); err != nil {
 		c.Fatal(err)
 	}
-	v, err := bc.Run()
+	v, err := bc.Run(context.Background())
 
-	c.Assert(err, Not(IsNil))
+	c.Assert(err, IsNil)
 	c.Assert(v, Equals, "")
-	c.Assert(err, ErrorMatches, valueMsg)
+	c.Assert(bc.Backlight.Clamped, Equals, true)
 }
 
 func (s *GobacklightSuite) TestRunIncFileKo(c *C) {
@@ -867,7 +648,7 @@ func (s *GobacklightSuite) TestRunIncFileKo(c *C) {
 	if err := os.Remove(syspath + "brightness"); err != nil {
 		c.Fatal(err)
 	}
-	v, err := bc.Run()
+	v, err := bc.Run(context.Background())
 
 	c.Assert(err, Not(IsNil))
 	c.Assert(v, Equals, "")
@@ -884,7 +665,7 @@ func (s *GobacklightSuite) TestRunIncCombinedKo(c *C) {
 		c.Fatal(err)
 	}
 
-	v, err := bc.Run()
+	v, err := bc.Run(context.Background())
 	c.Assert(err, Not(IsNil))
 	c.Assert(v, Equals, "")
 	c.Assert(err, ErrorMatches, combinedMsg)
@@ -898,7 +679,7 @@ func (s *GobacklightSuite) TestRunDecOk(c *C) {
 	if err := bc.Init(); err != nil {
 		c.Fatal(err)
 	}
-	if v, err := bc.Run(); err != nil {
+	if v, err := bc.Run(context.Background()); err != nil {
 		c.Fatal(err)
 	} else {
 		c.Assert(err, IsNil)
@@ -907,19 +688,19 @@ func (s *GobacklightSuite) TestRunDecOk(c *C) {
 	}
 }
 
-func (s *GobacklightSuite) TestRunDecValueKo(c *C) {
+func (s *GobacklightSuite) TestRunDecValueOk(c *C) {
 	conf := Config{
-		Dec: uint(15),
+		Dec: uint(150),
 	}
 	bc := BrightnessControl{Config: &conf}
 	if err := bc.Init(); err != nil {
 		c.Fatal(err)
 	}
-	v, err := bc.Run()
+	v, err := bc.Run(context.Background())
 
-	c.Assert(err, Not(IsNil))
+	c.Assert(err, IsNil)
 	c.Assert(v, Equals, "")
-	c.Assert(err, ErrorMatches, valueMsg)
+	c.Assert(bc.Backlight.Clamped, Equals, true)
 }
 
 func (s *GobacklightSuite) TestRunDecFileKo(c *C) {
@@ -933,7 +714,7 @@ func (s *GobacklightSuite) TestRunDecFileKo(c *C) {
 	if err := os.Remove(syspath + "brightness"); err != nil {
 		c.Fatal(err)
 	}
-	v, err := bc.Run()
+	v, err := bc.Run(context.Background())
 
 	c.Assert(err, Not(IsNil))
 	c.Assert(v, Equals, "")
@@ -950,7 +731,7 @@ func (s *GobacklightSuite) TestRunDecCombinedKo(c *C) {
 		c.Fatal(err)
 	}
 
-	v, err := bc.Run()
+	v, err := bc.Run(context.Background())
 	c.Assert(err, Not(IsNil))
 	c.Assert(v, Equals, "")
 	c.Assert(err, ErrorMatches, combinedMsg)
@@ -964,7 +745,7 @@ func (s *GobacklightSuite) TestRunSetOk(c *C) {
 	if err := bc.Init(); err != nil {
 		c.Fatal(err)
 	}
-	if v, err := bc.Run(); err != nil {
+	if v, err := bc.Run(context.Background()); err != nil {
 		c.Fatal(err)
 	} else {
 		c.Assert(err, IsNil)
@@ -981,11 +762,10 @@ func (s *GobacklightSuite) TestRunSetValueKo(c *C) {
 	if err := bc.Init(); err != nil {
 		c.Fatal(err)
 	}
-	v, err := bc.Run()
+	v, err := bc.Run(context.Background())
 
-	c.Assert(err, Not(IsNil))
+	c.Assert(err, Equals, backlight.ErrRange)
 	c.Assert(v, Equals, "")
-	c.Assert(err, ErrorMatches, setMsg)
 }
 
 func (s *GobacklightSuite) TestRunSetFileKo(c *C) {
@@ -999,7 +779,7 @@ func (s *GobacklightSuite) TestRunSetFileKo(c *C) {
 	if err := os.Remove(syspath + "brightness"); err != nil {
 		c.Fatal(err)
 	}
-	v, err := bc.Run()
+	v, err := bc.Run(context.Background())
 
 	c.Assert(err, Not(IsNil))
 	c.Assert(v, Equals, "")
@@ -1016,8 +796,137 @@ func (s *GobacklightSuite) TestRunSetCombinedKo(c *C) {
 		c.Fatal(err)
 	}
 
-	v, err := bc.Run()
+	v, err := bc.Run(context.Background())
+	c.Assert(err, Not(IsNil))
+	c.Assert(v, Equals, "")
+	c.Assert(err, ErrorMatches, combinedMsg)
+}
+
+func makeDevice(c *C, root string, name string, kind string) {
+	path := filepath.Join(root, name)
+	if err := os.Mkdir(path, 0755); err != nil {
+		c.Fatal(err)
+	}
+	values := map[string]string{"brightness": "500", "actual_brightness": "500", "max_brightness": "1000"}
+	if kind != "" {
+		values["type"] = kind
+	}
+	for file, value := range values {
+		if err := ioutil.WriteFile(filepath.Join(path, file), []byte(value+"\n"), 0644); err != nil {
+			c.Fatal(err)
+		}
+	}
+}
+
+func (s *GobacklightSuite) TestRunListTableOk(c *C) {
+	defer func(path string) { syspath = path }(syspath)
+	syspath = c.MkDir() + "/"
+
+	makeDevice(c, syspath, "intel_backlight", "raw")
+
+	conf := Config{List: true, Output: "table"}
+	bc := BrightnessControl{Config: &conf}
+	v, err := bc.Run(context.Background())
+
+	c.Assert(err, IsNil)
+	c.Assert(v, Matches, "NAME +TYPE +SCALE +MAX +ACTUAL +PERCENT +BL_POWER +WRITABLE\nintel_backlight +raw +- +1000 +500 +50 +- +true")
+}
+
+func (s *GobacklightSuite) TestRunListJSONOk(c *C) {
+	defer func(path string) { syspath = path }(syspath)
+	syspath = c.MkDir() + "/"
+
+	makeDevice(c, syspath, "intel_backlight", "raw")
+
+	conf := Config{List: true, Output: "json"}
+	bc := BrightnessControl{Config: &conf}
+	v, err := bc.Run(context.Background())
+	c.Assert(err, IsNil)
+
+	var devices []backlight.Info
+	c.Assert(json.Unmarshal([]byte(v), &devices), IsNil)
+	c.Assert(devices, HasLen, 1)
+	c.Assert(devices[0].Name, Equals, "intel_backlight")
+	c.Assert(devices[0].Percent, Equals, 50)
+}
+
+func (s *GobacklightSuite) TestRunListCombinedKo(c *C) {
+	conf := Config{
+		List: true,
+		Get:  true,
+	}
+	bc := BrightnessControl{Config: &conf}
+
+	v, err := bc.Run(context.Background())
 	c.Assert(err, Not(IsNil))
 	c.Assert(v, Equals, "")
 	c.Assert(err, ErrorMatches, combinedMsg)
 }
+
+func (s *GobacklightSuite) TestRunSetFadeCanceledKo(c *C) {
+	conf := Config{
+		Set:  uint(25),
+		Fade: time.Second,
+	}
+	bc := BrightnessControl{Config: &conf}
+	if err := bc.Init(); err != nil {
+		c.Fatal(err)
+	}
+	if bc.Backlight.ActualBrightness == 250 {
+		conf.Set = uint(75)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	v, err := bc.Run(ctx)
+
+	c.Assert(err, Equals, context.Canceled)
+	c.Assert(v, Equals, "")
+}
+
+func (s *GobacklightSuite) TestRunIncClampedOk(c *C) {
+	conf := Config{
+		Inc: uint(5),
+		Max: uint(20),
+	}
+	bc := BrightnessControl{Config: &conf}
+	if err := bc.Init(); err != nil {
+		c.Fatal(err)
+	}
+	bc.Backlight.ActualBrightness = 190
+
+	v, err := bc.Run(context.Background())
+	c.Assert(err, IsNil)
+	c.Assert(v, Equals, "")
+	c.Assert(bc.Backlight.Clamped, Equals, true)
+	c.Assert(bc.Backlight.Brightness, Equals, int64(200))
+}
+
+func (s *GobacklightSuite) TestRunToOk(c *C) {
+	conf := Config{
+		To: "750",
+	}
+	bc := BrightnessControl{Config: &conf}
+	if err := bc.Init(); err != nil {
+		c.Fatal(err)
+	}
+	v, err := bc.Run(context.Background())
+
+	c.Assert(err, IsNil)
+	c.Assert(v, Equals, "")
+	c.Assert(bc.Backlight.Brightness, Equals, int64(750))
+}
+
+func (s *GobacklightSuite) TestRunToRangeKo(c *C) {
+	conf := Config{
+		To: "1500",
+	}
+	bc := BrightnessControl{Config: &conf}
+	if err := bc.Init(); err != nil {
+		c.Fatal(err)
+	}
+	v, err := bc.Run(context.Background())
+
+	c.Assert(err, Equals, backlight.ErrRange)
+	c.Assert(v, Equals, "")
+}
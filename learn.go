@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rustx/gobacklight/backlight"
+)
+
+// AdaptCommand is the adapt command, setting the brightness learned from the manual changes until interrupted.
+type AdaptCommand struct {
+	Interval time.Duration `long:"interval" default:"10s" description:"interval between two predictions of the brightness"`
+	Once     bool          `long:"once" description:"set the learned brightness once and exit"`
+}
+
+// ModelCommand is the model command, inspecting and resetting the learned model.
+type ModelCommand struct {
+	Show  ModelShowCommand `command:"show" description:"print the samples of the model and the brightness learned for now"`
+	Reset struct{}         `command:"reset" description:"forget all the samples of the model"`
+}
+
+// ModelShowCommand is the model show command.
+type ModelShowCommand struct {
+	Output string `short:"o" long:"output" default:"table" choice:"table" choice:"json" description:"output format"`
+}
+
+// defaultState returns the path of the state file of the model, in $XDG_STATE_HOME when it is set.
+func defaultState() string {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home := os.Getenv("HOME")
+		if home == "" {
+			home = os.TempDir()
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "gobacklight", "model.json")
+}
+
+// state returns the path of the state file of the model, the state option overriding statepath.
+func (bc *BrightnessControl) state() string {
+	if bc.Config.State != "" {
+		return bc.Config.State
+	}
+	return statepath
+}
+
+// conditions returns the current conditions : the illuminance of the first ambient light sensor, if any,
+// the power source, and the time.
+func (bc *BrightnessControl) conditions() backlight.Conditions {
+	c := backlight.Conditions{Time: now(), Power: backlight.PowerAC}
+	if sensor, err := backlight.OpenSensor(iiopath, backlight.Auto); err == nil {
+		if lux, err := sensor.Lux(); err == nil {
+			c.Lux = &lux
+		}
+	}
+	if power, err := backlight.ReadPowerSource(powerpath); err == nil {
+		c.Power = power
+	}
+	return c
+}
+
+// written returns the percentage written by the last change, by the device or by the daemon.
+func (bc *BrightnessControl) written() float64 {
+	if bc.response != nil {
+		return float64(bc.response.Percent)
+	}
+	v, _ := bc.Backlight.LastWrite()
+	return bc.Backlight.Percent(v)
+}
+
+// learn records the brightness written by a manual change in the model, with the current conditions.
+// It is best effort : the change succeeded, so a state file which can't be written is ignored.
+func (bc *BrightnessControl) learn() {
+	if bc.Config.NoLearn {
+		return
+	}
+	sample := backlight.Sample{Conditions: bc.conditions(), Percent: bc.written()}
+	backlight.UpdateModel(bc.state(), func(model *backlight.Model) {
+		model.Add(sample)
+	})
+}
+
+func adaptAction(bc *BrightnessControl, ctx context.Context, arg string) (string, error) {
+	options := bc.Config.AdaptCommand
+	info, _ := os.Stat(bc.state())
+	model, err := backlight.LoadModel(bc.state())
+	if err != nil {
+		return "", err
+	}
+	if len(model.Samples) == 0 {
+		return "", fmt.Errorf(samplesMsg)
+	}
+	last := math.NaN()
+	for {
+		if p, ok := model.Predict(bc.conditions()); ok {
+			p = math.Round(p)
+			if p != last {
+				if err := bc.apply(ctx, backlight.Value{Unit: backlight.Percent, Amount: p}); err != nil {
+					return "", err
+				}
+				last = p
+			}
+		}
+		if options.Once {
+			return "", nil
+		}
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(options.Interval):
+		}
+		// the manual changes recorded meanwhile by set, inc and dec are learned by the next predictions,
+		// but the brightness the user just chose is kept until the conditions change : the prediction of now
+		// blends it with the older samples, and writing it would undo the change
+		if current, _ := os.Stat(bc.state()); changed(info, current) {
+			if m, err := backlight.LoadModel(bc.state()); err == nil {
+				model, info = m, current
+				if p, ok := model.Predict(bc.conditions()); ok {
+					last = math.Round(p)
+				}
+			}
+		}
+	}
+}
+
+// changed reports whether a file was written, replaced, created or removed between two of its descriptions,
+// nil when it doesn't exist.
+func changed(before os.FileInfo, after os.FileInfo) bool {
+	if before == nil || after == nil {
+		return before != after
+	}
+	return !os.SameFile(before, after) || !before.ModTime().Equal(after.ModTime()) || before.Size() != after.Size()
+}
+
+func modelShowAction(bc *BrightnessControl, ctx context.Context, arg string) (string, error) {
+	model, err := backlight.LoadModel(bc.state())
+	if err != nil {
+		return "", err
+	}
+	return bc.Format.model(model, bc.conditions())
+}
+
+func modelResetAction(bc *BrightnessControl, ctx context.Context, arg string) (string, error) {
+	return "", backlight.ResetModel(bc.state())
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+	"github.com/rustx/gobacklight/backlight"
+	. "gopkg.in/check.v1"
+)
+
+// saveModel saves a model of the samples in a new state file, and returns its path.
+func saveModel(c *C, samples ...backlight.Sample) string {
+	path := filepath.Join(c.MkDir(), "model.json")
+	m := &backlight.Model{Samples: samples}
+	if err := m.Save(path); err != nil {
+		c.Fatal(err)
+	}
+	return path
+}
+
+func (s *GobacklightSuite) TestParseModelCommandOk(c *C) {
+	var conf Config
+	parser := flags.NewParser(&conf, flags.None)
+	parser.SubcommandsOptional = true
+	_, err := parser.ParseArgs([]string{"model", "show", "-o", "json"})
+
+	c.Assert(err, IsNil)
+	c.Assert(parser.Active.Name, Equals, "model")
+	c.Assert(parser.Active.Active.Name, Equals, "show")
+	c.Assert(conf.ModelCommand.Show.Output, Equals, "json")
+}
+
+func (s *GobacklightSuite) TestRunSetLearnOk(c *C) {
+	defer func(path string) { syspath = path }(syspath)
+	syspath = c.MkDir() + "/"
+	makeDevice(c, syspath, "intel_backlight", "raw")
+
+	state := filepath.Join(c.MkDir(), "gobacklight", "model.json")
+	conf := Config{Device: "intel_backlight", State: state}
+	conf.SetCommand.Args.Value = "75%"
+	bc := BrightnessControl{Config: &conf, Command: "set"}
+	_, err := bc.Run(context.Background())
+	c.Assert(err, IsNil)
+
+	conf.IncCommand.Args.Percent = "5"
+	bc = BrightnessControl{Config: &conf, Command: "inc"}
+	_, err = bc.Run(context.Background())
+	c.Assert(err, IsNil)
+
+	m, err := backlight.LoadModel(state)
+	c.Assert(err, IsNil)
+	c.Assert(m.Samples, HasLen, 2)
+	c.Assert(m.Samples[0].Percent, Equals, float64(75))
+	c.Assert(m.Samples[0].Power, Equals, backlight.PowerAC)
+	c.Assert(m.Samples[0].Lux, IsNil)
+	c.Assert(time.Since(m.Samples[0].Time) < time.Minute, Equals, true)
+	// the fake actual_brightness stays at 500
+	c.Assert(m.Samples[1].Percent, Equals, float64(55))
+}
+
+func (s *GobacklightSuite) TestRunSetNoLearnOk(c *C) {
+	state := filepath.Join(c.MkDir(), "model.json")
+	conf := Config{State: state, NoLearn: true}
+	conf.SetCommand.Args.Value = "75%"
+	bc := BrightnessControl{Config: &conf, Command: "set"}
+	_, err := bc.Run(context.Background())
+	c.Assert(err, IsNil)
+
+	_, err = os.Stat(state)
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *GobacklightSuite) TestRunAdaptCommandOk(c *C) {
+	defer func(path string) { syspath = path }(syspath)
+	syspath = c.MkDir() + "/"
+	makeDevice(c, syspath, "intel_backlight", "raw")
+
+	now := time.Now()
+	conf := Config{Device: "intel_backlight", State: saveModel(c,
+		backlight.Sample{Conditions: backlight.Conditions{Time: now, Power: backlight.PowerAC}, Percent: 30},
+		backlight.Sample{Conditions: backlight.Conditions{Time: now, Power: backlight.PowerAC}, Percent: 40},
+	)}
+	conf.AdaptCommand.Once = true
+	bc := BrightnessControl{Config: &conf, Command: "adapt"}
+	_, err := bc.Run(context.Background())
+
+	c.Assert(err, IsNil)
+	c.Assert(bc.Backlight.Brightness, Equals, int64(350))
+}
+
+func (s *GobacklightSuite) TestRunAdaptCommandReloadOk(c *C) {
+	defer func(path string) { syspath = path }(syspath)
+	syspath = c.MkDir() + "/"
+	makeDevice(c, syspath, "intel_backlight", "raw")
+	defer func(clock func() time.Time) { now = clock }(now)
+	var mu sync.Mutex
+	t0 := time.Now()
+	clock := t0
+	now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return clock
+	}
+
+	conf := Config{Device: "intel_backlight", State: saveModel(c,
+		backlight.Sample{Conditions: backlight.Conditions{Time: t0.Add(-6 * time.Hour), Power: backlight.PowerAC}, Percent: 30},
+	)}
+	conf.AdaptCommand.Interval = 5 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		bc := BrightnessControl{Config: &conf, Command: "adapt"}
+		_, err := bc.Run(ctx)
+		done <- err
+	}()
+	brightness := func(expected string) {
+		var data []byte
+		for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+			if data, _ = ioutil.ReadFile(filepath.Join(syspath, "intel_backlight", "brightness")); strings.TrimSpace(string(data)) == expected {
+				return
+			}
+		}
+		c.Fatalf("brightness %s, expected %s", data, expected)
+	}
+	brightness("300")
+
+	// a manual change to 80% while adapt runs, appended to the model like set does
+	if err := ioutil.WriteFile(filepath.Join(syspath, "intel_backlight", "brightness"), []byte("800"), 0644); err != nil {
+		c.Fatal(err)
+	}
+	m, err := backlight.LoadModel(conf.State)
+	c.Assert(err, IsNil)
+	m.Add(backlight.Sample{Conditions: backlight.Conditions{Time: t0, Power: backlight.PowerAC}, Percent: 80})
+	c.Assert(m.Save(conf.State), IsNil)
+	blend, _ := m.Predict(backlight.Conditions{Time: t0, Power: backlight.PowerAC})
+	c.Assert(math.Round(blend), Not(Equals), float64(80))
+
+	// the blend of the samples doesn't undo the change
+	time.Sleep(20 * conf.AdaptCommand.Interval)
+	brightness("800")
+
+	// until the conditions change
+	mu.Lock()
+	clock = t0.Add(-6 * time.Hour)
+	mu.Unlock()
+	p, _ := m.Predict(backlight.Conditions{Time: t0.Add(-6 * time.Hour), Power: backlight.PowerAC})
+	brightness(fmt.Sprint(math.Round(p) * 10))
+
+	cancel()
+	c.Assert(<-done, Equals, context.Canceled)
+}
+
+func (s *GobacklightSuite) TestRunAdaptCommandKo(c *C) {
+	conf := Config{State: filepath.Join(c.MkDir(), "model.json")}
+	bc := BrightnessControl{Config: &conf, Command: "adapt"}
+	_, err := bc.Run(context.Background())
+
+	c.Assert(err, ErrorMatches, samplesMsg)
+}
+
+func (s *GobacklightSuite) TestRunModelCommandsOk(c *C) {
+	lux := 120.0
+	state := saveModel(c,
+		backlight.Sample{Conditions: backlight.Conditions{Time: time.Now(), Lux: &lux, Power: backlight.PowerBattery}, Percent: 45},
+	)
+	conf := Config{State: state}
+	conf.ModelCommand.Show.Output = "table"
+	bc := BrightnessControl{Config: &conf, Command: "model show"}
+	v, err := bc.Run(context.Background())
+
+	c.Assert(err, IsNil)
+	lines := strings.Split(v, "\n")
+	c.Assert(lines, HasLen, 3)
+	c.Assert(strings.Fields(lines[0]), DeepEquals, []string{"TIME", "LUX", "POWER", "PERCENT"})
+	c.Assert(strings.Fields(lines[1])[2:], DeepEquals, []string{"120", "battery", "45"})
+	c.Assert(lines[2], Equals, "learned brightness now, at - lux on ac : 45%")
+
+	conf.ModelCommand.Show.Output = "json"
+	bc = BrightnessControl{Config: &conf, Command: "model show"}
+	v, err = bc.Run(context.Background())
+	c.Assert(err, IsNil)
+	var out struct {
+		Samples    []backlight.Sample `json:"samples"`
+		Prediction *int               `json:"prediction"`
+	}
+	c.Assert(json.Unmarshal([]byte(v), &out), IsNil)
+	c.Assert(out.Samples, HasLen, 1)
+	c.Assert(*out.Prediction, Equals, 45)
+
+	bc = BrightnessControl{Config: &conf, Command: "model reset"}
+	_, err = bc.Run(context.Background())
+	c.Assert(err, IsNil)
+	_, err = os.Stat(state)
+	c.Assert(os.IsNotExist(err), Equals, true)
+
+	conf.ModelCommand.Show.Output = "table"
+	bc = BrightnessControl{Config: &conf, Command: "model show"}
+	v, err = bc.Run(context.Background())
+	c.Assert(err, IsNil)
+	c.Assert(strings.HasSuffix(v, "no samples learned yet"), Equals, true)
+}
@@ -1,307 +1,562 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"io/ioutil"
+	"io"
+	"math"
 	"os"
+	"os/signal"
+	"reflect"
 	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/jessevdk/go-flags"
+	"github.com/rustx/gobacklight/backlight"
 )
 
-// Config struct parses and validates the options from the command line
+// Config struct parses and validates the options from the command line.
+// The actions are commands, the Inc, Dec, Set, To, Get and List flags are kept as legacy aliases of them.
 type Config struct {
-	Device string `short:"v" long:"device" default:"intel_backlight" required:"true" description:"brightness device"`
-	Inc    uint   `short:"i" long:"inc" default:"nil" description:"increment brightness up to given percentage between [1 -10]"`
-	Dec    uint   `short:"d" long:"dec" default:"nil" description:"decrement brightness down to percentage between [1 -10]"`
-	Set    uint   `short:"s" long:"set" default:"nil" description:"set brightness to given percentage between [1-99]"`
-	Get    bool   `short:"g" long:"get" description:"get actual brightness percentage"`
+	Device string `short:"v" long:"device" default:"auto" required:"true" description:"brightness device, auto picks the preferred one, and a class prefix like leds:tpacpi::kbd_backlight or ddc:DP-1 picks its class"`
+	Class  string `long:"class" default:"backlight" choice:"backlight" choice:"leds" choice:"ddc" description:"class of the devices : the panel backlights of /sys/class/backlight, the LEDs of /sys/class/leds like the keyboard backlights, or the external monitors over DDC/CI"`
+	Inc    uint   `short:"i" long:"inc" description:"legacy alias of the inc command"`
+	Dec    uint   `short:"d" long:"dec" description:"legacy alias of the dec command"`
+	Set    uint   `short:"s" long:"set" description:"legacy alias of the set command, with a percentage between [1-100]"`
+	To     string `short:"t" long:"to" description:"legacy alias of the set command"`
+	Get    bool   `short:"g" long:"get" description:"legacy alias of the get command"`
+	List   bool   `short:"l" long:"list" description:"legacy alias of the list command"`
+	Output string `short:"o" long:"output" default:"table" choice:"table" choice:"json" description:"output format of the legacy list flag"`
+
+	Fade         time.Duration `long:"fade" description:"fade to the new brightness over the given duration, like 500ms"`
+	FadeInterval time.Duration `long:"fade-interval" default:"20ms" description:"interval between two steps of a fade, at least 50ms for a monitor"`
+	Easing       string        `long:"easing" default:"linear" choice:"linear" choice:"ease-in-out" choice:"exponential" description:"easing of a fade"`
+
+	Curve    string  `long:"curve" default:"auto" choice:"auto" choice:"linear" choice:"exponential" choice:"cie1931" description:"mapping between percentages and raw brightness values, auto picks it from the device scale"`
+	Exponent float64 `long:"exponent" default:"4" description:"exponent of the exponential curve"`
+
+	Min uint `long:"min" default:"0" description:"lowest percentage reached by set, inc and dec"`
+	Max uint `long:"max" default:"100" description:"highest percentage reached by set, inc and dec"`
+
+	Floor    []string `long:"floor" description:"lowest brightness written, as a percentage like 5% or a raw value, for one device when prefixed like intel_backlight=5%"`
+	AllowOff bool     `long:"allow-off" description:"allow the brightness to go down to 0, ignoring the floor"`
+
+	Socket   string `long:"socket" description:"socket of the daemon, $XDG_RUNTIME_DIR/gobacklight.sock by default"`
+	NoDaemon bool   `long:"no-daemon" description:"access the device directly, even when the daemon is running"`
+	NoLogind bool   `long:"no-logind" description:"don't fall back on the SetBrightness method of logind when writing the brightness file is denied"`
+
+	AC         string `long:"ac" description:"brightness set by apply-profile on AC, any value of set"`
+	Battery    string `long:"battery" description:"brightness set by apply-profile on battery, any value of set"`
+	ACMax      string `long:"ac-max" description:"highest percentage reached on AC, like 90%"`
+	BatteryMax string `long:"battery-max" description:"highest percentage reached on battery, like 60%"`
+
+	BatteryCaps string `long:"battery-caps" description:"highest percentages reached below battery levels, as capacity:percent rules like 20:40,10:25, until the battery charges"`
+
+	State   string `long:"state" description:"state file of the learned model, $XDG_STATE_HOME/gobacklight/model.json by default"`
+	NoLearn bool   `long:"no-learn" description:"don't record the changes of set, inc and dec in the learned model"`
+
+	GetCommand    GetCommand     `command:"get" description:"print the current brightness percentage"`
+	SetCommand    ValueCommand   `command:"set" description:"set the brightness to a value" long-description:"Set the brightness to a value : 50% is a percentage, +5% or -10% a relative percentage, 750 a raw value, +100 or -100 a relative raw value, 0.4 a fraction, and max or min the limits of the device."`
+	IncCommand    PercentCommand `command:"inc" description:"increment the brightness with a percentage"`
+	DecCommand    PercentCommand `command:"dec" description:"decrement the brightness with a percentage"`
+	ListCommand   OutputCommand  `command:"list" description:"list all backlight devices with their attributes"`
+	InfoCommand   OutputCommand  `command:"info" description:"print the attributes of the device"`
+	WatchCommand  WatchCommand   `command:"watch" description:"print the brightness each time it changes, until interrupted"`
+	DaemonCommand DaemonCommand  `command:"daemon" description:"hold the device and serve the get, set, inc and dec commands on a Unix socket"`
+	AutoCommand   AutoCommand    `command:"auto" description:"set the brightness from an ambient light sensor, until interrupted"`
+	ColorCommand  ColorCommand   `command:"color" description:"print or set the color of a multicolor LED, like an RGB keyboard"`
+	AdaptCommand  AdaptCommand   `command:"adapt" description:"set the brightness learned from the changes of set, inc and dec, until interrupted"`
+	ModelCommand  ModelCommand   `command:"model" description:"inspect or reset the model learned from the changes of set, inc and dec"`
+
+	ApplyProfileCommand ApplyProfileCommand `command:"apply-profile" description:"apply the brightness of the power source, set with --ac and --battery"`
+	ScheduleCommand     ScheduleCommand     `command:"schedule" description:"set the brightness along the day, with fixed times and sunrise and sunset"`
 }
 
-// BrightnessControl is the main object, loading the device values and executing actions.
+// BrightnessControl is the main object, loading the device and executing actions from the command line.
+// Command is the name of the command given on the command line, like model show for a subcommand,
+// empty when the legacy flags are used. Format is the output format of the command,
+// and Out the writer of the commands streaming lines, stdout by default.
+// Backlight is nil when the command was run by the daemon, its response being kept instead.
 type BrightnessControl struct {
 	*Config
-	Path             string
-	Brightness       int64
-	ActualBrightness int64
-	MaxBrightness    int64
+	Command   string
+	Format    Format
+	Out       io.Writer
+	Backlight *backlight.Device
+	response  *Response
+	reason    string
 }
 
 var (
-	config  Config
-	syspath = "/sys/class/backlight/"
+	config    Config
+	syspath   = backlight.DefaultRoot
+	ledpath   = backlight.DefaultLEDRoot
+	busaddr   = systemBus()
+	drmpath   = backlight.DefaultDRMRoot
+	i2copen   = backlight.OpenI2C
+	sockpath  = defaultSocket()
+	iiopath   = backlight.DefaultIIORoot
+	powerpath = backlight.DefaultPowerRoot
+	statepath = defaultState()
+	now       = time.Now
 
-	combinedMsg = "Error combined options"
-	setMsg      = "Error value must be between 1 and 99"
-	valueMsg    = "Error value must be between 1 and 10"
-	nilMsg      = "Error action is nil"
-	driverMsg   = "Error driver files not found in device path"
-	nooptMsg    = "Error no options, try gobacklight -h"
+	combinedMsg  = "Error combined options"
+	nilMsg       = "Error action is nil"
+	nooptMsg     = "Error no options, try gobacklight -h"
+	rangeMsg     = "Error min must be lower than max"
+	floorMsg     = "Error floor must be a percentage or a raw value"
+	percentMsg   = "Error value must be a positive percentage"
+	templateMsg  = "Error template output needs a template"
+	runningMsg   = "Error the daemon is already running"
+	notsocketMsg = "Error %s exists and is not a socket"
+	ownerMsg     = "Error the socket %s belongs to another user, try --no-daemon"
+	requestMsg   = "Error request must be a json object"
+	commandMsg   = "Error unknown command"
+	deviceMsg    = "Error the daemon controls the device %s"
+	optionsMsg   = "Error the daemon runs with other options"
+	samplesMsg   = "Error no samples learned yet, change the brightness with set, inc or dec first"
+	profileMsg   = "Error %s profile : %s"
+	clampedMsg   = "Brightness clamped to %d%%\n"
+	cappedMsg    = "Brightness clamped to %d%% : %s\n"
+	batteryMsg   = "battery below %d%%, until it charges"
+	locationMsg  = "Error location must be a latitude and a longitude in degrees, like 48.85,2.35"
 
 	nofileMsg = "open .*: no such file or directory"
 
-	driverFiles = [3]string{"brightness", "actual_brightness", "max_brightness"}
-
 	example = `Examples :
-	gobacklight -v intel_backlight -g
-	gobacklight -v intel_backlight -i 5
-	gobacklight -v intel_backlight -d 5
+	gobacklight get
+	gobacklight get -o json
+	gobacklight get --template '☀ {{.Percent}}%'
+	gobacklight list -o json
+	gobacklight -v intel_backlight info
+	gobacklight watch -o json
+	gobacklight -v intel_backlight daemon
+	gobacklight auto --lux-curve 0:10,100:50,1000:100 --fade 1s
+	gobacklight adapt --fade 2s
+	gobacklight model show
+	gobacklight --ac 100% --battery 40% --battery-max 60% apply-profile --watch
+	gobacklight --battery-caps 20:40,10:25 daemon
+	gobacklight schedule --points 07:00=80%,sunset-30m=50%,22:00=30% --location 48.85,2.35 show
+	gobacklight schedule --points 07:00=80%,sunset-30m=50%,22:00=30% --location 48.85,2.35 run --fade 5s
+	gobacklight -v intel_backlight inc 5
+	gobacklight -v intel_backlight dec 5
+	gobacklight -v intel_backlight set 25%
+	gobacklight -v intel_backlight set +100
+	gobacklight -v intel_backlight set max
+	gobacklight -v intel_backlight set 25% --fade 500ms --easing ease-in-out
+	gobacklight -v intel_backlight dec 5 --curve cie1931
+	gobacklight -v intel_backlight dec 5 --floor 2%
 	gobacklight -v intel_backlight -s 25
+	gobacklight --class leds list
+	gobacklight --class leds inc 50
+	gobacklight -v leds:tpacpi::kbd_backlight set 0
+	gobacklight -v leds:rgb:kbd_backlight set 80% --color ff8800
+	gobacklight -v leds:rgb:kbd_backlight color red=255,green=128,blue=0
+	gobacklight --class ddc list
+	gobacklight -v ddc:DP-1 set 40%
 `
 )
 
-func readFile(file string) (string, error) {
-	value, err := ioutil.ReadFile(file)
-	if err != nil {
-		return "", err
+// ValidateOptions validate the legacy flags provided to BrightnessControl with the command line.
+// It checks that the action is known, and that a unique action was called with command line :
+// it returns an error when combined actions are called, or when a command is combined with a legacy flag.
+// The to action is the legacy alias of set with a value, its value is checked too.
+func (bc *BrightnessControl) ValidateOptions(action string) error {
+	if action == "to" {
+		if _, err := backlight.ParseValue(bc.Config.To); err != nil {
+			return err
+		}
+	} else if _, ok := actions[action]; !ok {
+		return fmt.Errorf(nilMsg)
 	}
-	return string(value), nil
-}
-
-func writeStringToFile(file string, value string) error {
-	f, err := os.OpenFile(file, os.O_WRONLY, 0644)
-	if err != nil {
-		return err
+	count := len(bc.legacyActions())
+	if bc.Command != "" {
+		count++
+	}
+	if count > 1 {
+		return fmt.Errorf(combinedMsg)
 	}
-	defer f.Close()
-	f.WriteString(value)
-	f.Sync()
 	return nil
 }
 
-func checkDevice(path string) ([]os.FileInfo, error) {
-	var result []os.FileInfo
-	files, err := ioutil.ReadDir(path)
-	if err != nil {
-		return nil, err
+// legacyActions maps the legacy flags set on the command line to the command they stand for, with its argument.
+func (bc *BrightnessControl) legacyActions() map[string]string {
+	legacy := map[string]string{}
+	if bc.Config.Get == true {
+		legacy["get"] = ""
 	}
-	for _, f := range files {
-		for _, e := range driverFiles {
-			if e == f.Name() {
-				result = append(result, f)
-			}
-		}
+	if bc.Config.List == true {
+		legacy["list"] = ""
 	}
+	if bc.Config.Inc > 0 {
+		legacy["inc"] = strconv.FormatUint(uint64(bc.Config.Inc), 10)
+	}
+	if bc.Config.Dec > 0 {
+		legacy["dec"] = strconv.FormatUint(uint64(bc.Config.Dec), 10)
+	}
+	if bc.Config.Set > 0 {
+		legacy["set"] = strconv.FormatUint(uint64(bc.Config.Set), 10) + "%"
+	}
+	if bc.Config.To != "" {
+		legacy["to"] = bc.Config.To
+	}
+	return legacy
+}
 
-	if len(result) == 3 {
-		return result, nil
+// device returns the class, the sysfs root and the name of the device given by the command line, the root of the monitors being drmpath.
+// The class is the one prefixing the device name, like leds:tpacpi::kbd_backlight, or the class option.
+func (bc *BrightnessControl) device() (backlight.Class, string, string, error) {
+	name, class := splitDevice(bc.Config.Device, bc.Config.Class)
+	c, err := backlight.ParseClass(class)
+	if err != nil {
+		return "", "", "", err
+	}
+	switch c {
+	case backlight.ClassLEDs:
+		return c, ledpath, name, nil
+	case backlight.ClassDDC:
+		return c, drmpath, name, nil
 	}
-	return nil, fmt.Errorf(driverMsg)
+	return c, syspath, name, nil
 }
 
-// LoadParams reads all files in driver folder, and fills BrightnessControl fields.
-// It expects that your driver folder contains at least 3 files : brightness, actual_brightness, max_brightness.
-// It returns an error when the driver files could not be read, or converted to string.
-func (bc *BrightnessControl) LoadParams(files []os.FileInfo) error {
-	if len(files) == 3 {
-		for _, file := range files {
-			switch file.Name() {
-			case "actual_brightness":
-				actual, err := readFile(bc.Path + file.Name())
-				if err != nil {
-					return err
-				}
-				a, err := strconv.ParseInt(strings.Trim(actual, "\n"), 10, 0)
-				if err != nil {
-					return err
-				}
-				bc.ActualBrightness = a
-			case "brightness":
-				brightness, err := readFile(bc.Path + file.Name())
-				if err != nil {
-					return err
-				}
-				b, err := strconv.ParseInt(strings.Trim(brightness, "\n"), 10, 0)
-				if err != nil {
-					return err
-				}
-				bc.Brightness = b
-			case "max_brightness":
-				max, err := readFile(bc.Path + file.Name())
-				if err != nil {
-					return err
-				}
-				m, err := strconv.ParseInt(strings.Trim(max, "\n"), 10, 0)
-				if err != nil {
-					return err
-				}
-				bc.MaxBrightness = m
-			default:
-				return fmt.Errorf("Error no proper files on driver folder")
-			}
+// splitDevice returns the name and the class of a device prefixed by its class, like leds:tpacpi::kbd_backlight,
+// and the class given when the device has no prefix, backlight when it is empty.
+// The LED names hold colons, like tpacpi::kbd_backlight, so only a known class is taken as prefix.
+func splitDevice(device string, class string) (string, string) {
+	for _, c := range []backlight.Class{backlight.ClassBacklight, backlight.ClassLEDs, backlight.ClassDDC} {
+		if strings.HasPrefix(device, string(c)+":") {
+			return strings.TrimPrefix(device, string(c)+":"), string(c)
 		}
-		return nil
 	}
-	return fmt.Errorf(driverMsg)
+	if class == "" {
+		class = string(backlight.ClassBacklight)
+	}
+	return device, class
 }
 
-// ValidateOptions validate the settings provided to BrightnessControl with the command line.
-// It checks that a unique action was called with command line, and returns an error when combined actions are called.
-// It also checks the command line values prior to execute actions, and returns an error when not OK.
-func (bc *BrightnessControl) ValidateOptions(action string) error {
-
-	switch action {
-	case "get":
-		if bc.Config.Set > 0 || bc.Config.Inc > 0 || bc.Config.Dec > 0 {
-			return fmt.Errorf(combinedMsg)
+// floorFor returns the raw floor of the device from the floor options.
+// An option prefixed with the device name, like intel_backlight=5%, wins over an option without prefix.
+// It returns an error when a value is not an absolute percentage or raw value of the device.
+func floorFor(d *backlight.Device, floors []string) (int64, error) {
+	floor, own := int64(0), int64(-1)
+	for _, f := range floors {
+		name, value := "", f
+		if i := strings.Index(f, "="); i >= 0 {
+			name, value = f[:i], f[i+1:]
 		}
-	case "set":
-		if bc.Config.Inc > 0 || bc.Config.Dec > 0 || bc.Config.Get == true {
-			return fmt.Errorf(combinedMsg)
+		if name != "" && name != d.Name {
+			continue
 		}
-		if bc.Config.Set > 100 || bc.Config.Set <= 0 {
-			return fmt.Errorf(setMsg)
+		parsed, err := backlight.ParseValue(value)
+		if err != nil || parsed.Relative || (parsed.Unit != backlight.Raw && parsed.Unit != backlight.Percent) {
+			return 0, fmt.Errorf(floorMsg)
 		}
-	case "dec":
-		if bc.Config.Inc > 0 || bc.Config.Set > 0 || bc.Config.Get == true {
-			return fmt.Errorf(combinedMsg)
-		}
-		if bc.Config.Dec > 10 || bc.Config.Dec <= 0 {
-			return fmt.Errorf(valueMsg)
-		}
-	case "inc":
-		if bc.Config.Dec > 0 || bc.Config.Set > 0 || bc.Config.Get == true {
-			return fmt.Errorf(combinedMsg)
+		v, err := d.Target(parsed)
+		if err != nil {
+			return 0, fmt.Errorf(floorMsg)
 		}
-		if bc.Config.Inc > 10 || bc.Config.Inc <= 0 {
-			return fmt.Errorf(valueMsg)
+		if name == "" {
+			floor = v
+		} else {
+			own = v
 		}
-	default:
-		return fmt.Errorf(nilMsg)
 	}
-	return nil
+	if own >= 0 {
+		return own, nil
+	}
+	return floor, nil
+}
+
+// clamped returns the percentage written and true when the value of the last write was clamped,
+// by the device or by the daemon.
+func (bc *BrightnessControl) clamped() (int, bool) {
+	if bc.response != nil {
+		return bc.response.Percent, bc.response.Clamped
+	}
+	if bc.Backlight != nil && bc.Backlight.Clamped {
+		return int(math.Round(bc.Backlight.Percent(bc.Backlight.Brightness))), true
+	}
+	return 0, false
+}
+
+// clampReason returns why the value of the last write was clamped when a battery rule capped it, by the device or by the daemon,
+// and empty otherwise.
+func (bc *BrightnessControl) clampReason() string {
+	if bc.response != nil {
+		return bc.response.Reason
+	}
+	if bc.Backlight == nil {
+		return ""
+	}
+	raw, clamped := bc.Backlight.LastWrite()
+	return capped(bc.Backlight, raw, clamped, bc.reason)
+}
+
+// out returns the writer of the commands streaming lines.
+func (bc *BrightnessControl) out() io.Writer {
+	if bc.Out == nil {
+		return os.Stdout
+	}
+	return bc.Out
+}
+
+// open initializes the device when it was not already, for the actions which need it.
+func (bc *BrightnessControl) open() error {
+	if bc.Backlight != nil {
+		return nil
+	}
+	return bc.Init()
 }
 
-// Init checks if the BrightnessControl can load the values from the device files.
-// It uses the checkDevice helper to ensure all files are present in the device folder given by the command line.
-// It returns an error if the device path doesn't contain files needed.
+// systemBus returns the D-Bus address of the system bus, from DBUS_SYSTEM_BUS_ADDRESS like the D-Bus libraries.
+func systemBus() string {
+	if address := os.Getenv("DBUS_SYSTEM_BUS_ADDRESS"); address != "" {
+		return address
+	}
+	return backlight.DefaultSystemBus
+}
+
+// Init opens the device given by the command line in syspath, in ledpath for the LEDs, or in drmpath for the monitors,
+// and loads its values. When the device is auto, the preferred device of the root is picked.
+// The brightness is written through logind when writing the brightness file is denied, unless the no-logind option is given.
+// The device is capped for the current power source and battery level by the ac-max, battery-max and battery-caps options.
+// It returns an error if the device path doesn't contain files needed, if the min and max options overlap,
+// or if a profile is invalid.
 func (bc *BrightnessControl) Init() error {
-	bc.Path = syspath + bc.Config.Device + "/"
-	if _, err := os.Stat(bc.Path); os.IsNotExist(err) {
+	if bc.Config.Max > 0 && bc.Config.Min >= bc.Config.Max {
+		return fmt.Errorf(rangeMsg)
+	}
+	class, root, name, err := bc.device()
+	if err != nil {
+		return err
+	}
+	var d *backlight.Device
+	if class == backlight.ClassDDC {
+		d, err = backlight.OpenDDC(root, name, i2copen)
+	} else {
+		d, err = class.Open(root, name)
+	}
+	if err != nil {
+		return err
+	}
+	// logind only sets the brightness of the backlight and leds subsystems
+	if !bc.Config.NoLogind && class != backlight.ClassDDC {
+		d.Backend = &backlight.Logind{Backend: d.Backend, Subsystem: string(class), Name: d.Name, Address: busaddr}
+	}
+	if d.Curve, err = backlight.ParseCurve(bc.Config.Curve, bc.Config.Exponent); err != nil {
+		return err
+	}
+	if bc.Config.Min > 0 {
+		d.Min = d.Raw(float64(bc.Config.Min))
+	}
+	if bc.Config.Max > 0 {
+		d.Max = d.Raw(float64(bc.Config.Max))
+	}
+	if d.Floor, err = floorFor(d, bc.Config.Floor); err != nil {
 		return err
 	}
-	files, err := checkDevice(bc.Path)
+	d.AllowOff = bc.Config.AllowOff
+	caps, err := bc.caps()
 	if err != nil {
 		return err
 	}
-	return bc.LoadParams(files)
+	bc.reason = caps.Limit(d)
+	d.Fade = backlight.Fade{
+		Duration: bc.Config.Fade,
+		Interval: bc.Config.FadeInterval,
+		Easing:   backlight.Easings[bc.Config.Easing],
+	}
+	bc.Backlight = d
+	return nil
 }
 
-// Run validate BrightnessControl options, and run actions from the command line arguments.
-// When calling the get action it returns the current brightness in stdout, else stdout is empty.
-// It returns an error if the action called encountered an error.
-func (bc *BrightnessControl) Run() (string, error) {
-	if bc.Config.Get == true {
-		if err := bc.ValidateOptions("get"); err != nil {
-			return "", err
-		}
-		v, _ := bc.Get()
-		return v, nil
+// Close closes the device, when an action opened it.
+func (bc *BrightnessControl) Close() error {
+	if bc.Backlight == nil {
+		return nil
 	}
-	if bc.Config.Set > 0 {
-		if err := bc.ValidateOptions("set"); err != nil {
-			return "", err
-		}
-		err := bc.Set()
-		if err != nil {
-			return "", err
+	return bc.Backlight.Close()
+}
+
+// Run runs the command given on the command line, or the action of the legacy flags.
+// The actions are looked up in the actions table, the device is opened by the actions which need it.
+// A fade in progress is stopped when ctx is done.
+// It returns an error if no action or several actions are called, or if the action encountered an error.
+func (bc *BrightnessControl) Run(ctx context.Context) (string, error) {
+	name, arg := bc.Command, ""
+	switch name {
+	case "get":
+		bc.Format = Format(bc.Config.GetCommand)
+	case "set":
+		arg = bc.Config.SetCommand.Args.Value
+	case "color":
+		arg = bc.Config.ColorCommand.Args.Color
+	case "inc":
+		arg = bc.Config.IncCommand.Args.Percent
+	case "dec":
+		arg = bc.Config.DecCommand.Args.Percent
+	case "list":
+		bc.Format = Format(bc.Config.ListCommand)
+	case "info":
+		bc.Format = Format(bc.Config.InfoCommand)
+	case "watch":
+		bc.Format = Format{Output: bc.Config.WatchCommand.Output, Template: bc.Config.WatchCommand.Template}
+	case "model show":
+		bc.Format = Format{Output: bc.Config.ModelCommand.Show.Output}
+	case "schedule show":
+		bc.Format = Format{Output: bc.Config.ScheduleCommand.Show.Output}
+	case "":
+		legacy := bc.legacyActions()
+		if len(legacy) == 0 {
+			return "", fmt.Errorf(nooptMsg)
 		}
-		return "", nil
-	}
-	if bc.Config.Dec > 0 {
-		if err := bc.ValidateOptions("dec"); err != nil {
-			return "", err
+		for name, arg = range legacy {
+			break
 		}
-		err := bc.Dec()
-		if err != nil {
-			return "", err
+		bc.Format = Format{Output: bc.Config.Output}
+		if name == "get" {
+			bc.Format.Output = "plain"
 		}
-		return "", nil
 	}
-	if bc.Config.Inc > 0 {
-		if err := bc.ValidateOptions("inc"); err != nil {
-			return "", err
-		}
-		err := bc.Inc()
-		if err != nil {
-			return "", err
-		}
-		return "", nil
+	if err := bc.ValidateOptions(name); err != nil {
+		return "", err
 	}
-	return "", fmt.Errorf(nooptMsg)
-
+	if name == "to" {
+		name = "set"
+	}
+	return actions[name](bc, ctx, arg)
 }
 
-// Get returns the current brightness expressed as percentage
-// It uses the MaxBrightness and ActualBrightness fields to return the current Brightness as a percentage.
-func (bc *BrightnessControl) Get() (string, error) {
-	actualPct := strconv.Itoa(int(bc.ActualBrightness) * 100 / int(bc.MaxBrightness))
-	return actualPct, nil
+// apply is the single path of the actions changing the brightness, it opens the device when needed.
+func (bc *BrightnessControl) apply(ctx context.Context, v backlight.Value) error {
+	if err := bc.open(); err != nil {
+		return err
+	}
+	return bc.Backlight.ApplyContext(ctx, v)
 }
 
-// Inc will increment the current brightness with a percentage between 1 and 10
-// It uses the MaxBrightness and ActualBrightness fields to apply the given percentage to current Brightness.
-// It returns an error if it could not write the new value to the brightness file.
-func (bc *BrightnessControl) Inc() error {
-	value := int(bc.ActualBrightness) + int(int(bc.Config.Inc)*int(bc.MaxBrightness)/100)
-
-	if value > 0 && value <= int(bc.MaxBrightness) {
-		if err := writeStringToFile(bc.Path+"brightness", strconv.Itoa(value)); err != nil {
+// change is the path of the manual changes of set, inc and dec : the request is sent to the daemon when it is running,
+// or the color of the request and the value applied to the device, and the brightness written is recorded in the learned model.
+func (bc *BrightnessControl) change(ctx context.Context, req Request, v backlight.Value) error {
+	if _, ok, err := bc.remote(ctx, req); ok {
+		if err != nil {
+			return err
+		}
+	} else {
+		if req.Color != "" {
+			if err := bc.open(); err != nil {
+				return err
+			}
+			if err := setColor(bc.Backlight, req.Color); err != nil {
+				return err
+			}
+		}
+		if err := bc.apply(ctx, v); err != nil {
 			return err
 		}
 	}
+	bc.learn()
 	return nil
 }
 
-// Dec will decrement the current brightness with a percentage between 1 and 10
-// It uses the MaxBrightness and ActualBrightness fields to apply the given percentage to current Brightness.
-// It returns an error if it could not write the new value to the brightness file.
-func (bc *BrightnessControl) Dec() error {
-	value := int(bc.ActualBrightness) - int(int(bc.Config.Dec)*int(bc.MaxBrightness)/100)
-
-	if value > 0 && value <= int(bc.MaxBrightness) {
-		if err := writeStringToFile(bc.Path+"brightness", strconv.Itoa(value)); err != nil {
-			return err
+// negativeArgs returns the arguments of the command line with their negative values, like set -10% or -t -10%,
+// hidden from the parser, which would take them for short options :
+// the value of an option is joined to it, like --to=-10%, and the value of a command is moved after --,
+// which needs the PassDoubleDash option of the parser.
+func negativeArgs(parser *flags.Parser, args []string) []string {
+	command, out, values := parser.Command, []string{}, []string{}
+	var option string
+	for i, arg := range args {
+		switch {
+		case arg == "--":
+			out = append(out, args[i:]...)
+			return append(out, values...)
+		case option != "" && isNegative(arg):
+			if strings.HasPrefix(option, "--") {
+				arg = "=" + arg
+			}
+			out[len(out)-1] = option + arg
+		case isNegative(arg):
+			values = append(values, arg)
+		case option == "" && !strings.HasPrefix(arg, "-"):
+			if sub := command.Find(arg); sub != nil {
+				command = sub
+			}
+			out = append(out, arg)
+		default:
+			out = append(out, arg)
+		}
+		option = ""
+		if takesValue(command, arg) {
+			option = arg
 		}
 	}
-	return nil
+	if len(values) > 0 {
+		out = append(append(out, "--"), values...)
+	}
+	return out
 }
 
-// Set will set the current brightness with a percentage between 1 and 100
-// It uses the MaxBrightness and ActualBrightness fields to apply the given percentage to current Brightness.
-// It returns an error if it could not write the new value to the brightness file.
-func (bc *BrightnessControl) Set() error {
-	value := int(int(bc.Config.Set) * int(bc.MaxBrightness) / 100)
+// isNegative reports whether an argument is a negative value, like -10%, -100 or -0.1.
+func isNegative(arg string) bool {
+	return len(arg) > 1 && arg[0] == '-' && (arg[1] == '.' || (arg[1] >= '0' && arg[1] <= '9'))
+}
 
-	if value > 0 && value <= int(bc.MaxBrightness) {
-		if err := writeStringToFile(bc.Path+"brightness", strconv.Itoa(value)); err != nil {
-			return err
-		}
+// takesValue reports whether an argument is an option of the command expecting its value in the next argument, like -t or --to.
+func takesValue(command *flags.Command, arg string) bool {
+	var option *flags.Option
+	switch {
+	case strings.HasPrefix(arg, "--") && !strings.Contains(arg, "="):
+		option = command.FindOptionByLongName(arg[2:])
+	case len(arg) == 2 && arg[0] == '-' && arg[1] != '-':
+		option = command.FindOptionByShortName(rune(arg[1]))
 	}
-	return nil
+	return option != nil && option.Field().Type.Kind() != reflect.Bool
 }
 
 func main() {
 	bc := BrightnessControl{Config: &config}
-	if _, err := flags.Parse(&config); err != nil {
+	parser := flags.NewParser(&config, flags.Default)
+	parser.SubcommandsOptional = true
+	if _, err := parser.ParseArgs(negativeArgs(parser, os.Args[1:])); err != nil {
 		fmt.Println(example)
 		os.Exit(1)
 	}
-	if err := bc.Init(); err != nil {
+	for active := parser.Active; active != nil; active = active.Active {
+		bc.Command = strings.TrimSpace(bc.Command + " " + active.Name)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-interrupt
+		cancel()
+	}()
+
+	out, err := bc.Run(ctx)
+	bc.Close()
+	if err == context.Canceled {
+		os.Exit(130)
+	} else if err != nil {
 		fmt.Println("An error occurred : ", err)
 		fmt.Println(example)
 		os.Exit(1)
 	} else {
-		if out, err := bc.Run(); err != nil {
-			fmt.Println("An error occurred : ", err)
-			fmt.Println(example)
-			os.Exit(1)
-		} else {
-			if out != "" {
-				fmt.Println(out)
+		if out != "" {
+			fmt.Println(out)
+		}
+		if percent, ok := bc.clamped(); ok {
+			if reason := bc.clampReason(); reason != "" {
+				fmt.Fprintf(os.Stderr, cappedMsg, percent, reason)
+			} else {
+				fmt.Fprintf(os.Stderr, clampedMsg, percent)
 			}
-			os.Exit(0)
+			os.Exit(2)
 		}
+		os.Exit(0)
 	}
 }
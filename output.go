@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+	"time"
+
+	"github.com/rustx/gobacklight/backlight"
+)
+
+// Format is the output format of the read commands, with the text/template of the template output.
+type Format struct {
+	Output   string
+	Template string
+}
+
+// mode returns the output format, a template implies the template output.
+func (f Format) mode() string {
+	if f.Template != "" {
+		return "template"
+	}
+	return f.Output
+}
+
+// device renders the attributes of a device, as printed by get : the percentage by default,
+// the raw actual brightness, the attributes as a json object, or the template.
+func (f Format) device(info backlight.Info) (string, error) {
+	switch f.mode() {
+	case "raw":
+		return strconv.FormatInt(info.ActualBrightness, 10), nil
+	case "json":
+		out, err := json.MarshalIndent(info, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(out), nil
+	case "template":
+		return render(f.Template, info)
+	}
+	return strconv.Itoa(info.Percent), nil
+}
+
+// event renders a change of brightness, as printed by watch : like get, but the json output is a single line
+// with the time of the change, and the template gets the time in the .Time field.
+func (f Format) event(e backlight.Event) (string, error) {
+	switch f.mode() {
+	case "json":
+		out, err := json.Marshal(e)
+		if err != nil {
+			return "", err
+		}
+		return string(out), nil
+	case "template":
+		return render(f.Template, e)
+	}
+	return f.device(e.Info)
+}
+
+// devices renders the attributes of devices, as printed by list and info : a table by default,
+// a json array, or the template with one line per device.
+func (f Format) devices(devices []backlight.Info) (string, error) {
+	switch f.mode() {
+	case "json":
+		out, err := json.MarshalIndent(devices, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(out), nil
+	case "template":
+		data := make([]interface{}, len(devices))
+		for i, d := range devices {
+			data[i] = d
+		}
+		return render(f.Template, data...)
+	}
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tTYPE\tSCALE\tMAX\tACTUAL\tPERCENT\tBL_POWER\tWRITABLE")
+	for _, d := range devices {
+		blPower, scale := "-", "-"
+		if d.BlPower != nil {
+			blPower = strconv.FormatInt(*d.BlPower, 10)
+		}
+		if d.Scale != "" {
+			scale = d.Scale
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%d\t%s\t%t\n", d.Name, d.Type, scale, d.MaxBrightness, d.ActualBrightness, d.Percent, blPower, d.Writable)
+	}
+	w.Flush()
+	return strings.TrimRight(buf.String(), "\n"), nil
+}
+
+// model renders the samples of a model and the brightness it predicts in the conditions, as printed by model show :
+// a table of the samples followed by the prediction by default, or a json object.
+func (f Format) model(m *backlight.Model, now backlight.Conditions) (string, error) {
+	prediction, ok := m.Predict(now)
+	if f.mode() == "json" {
+		out := struct {
+			*backlight.Model
+			Conditions backlight.Conditions `json:"conditions"`
+			Prediction *int                 `json:"prediction"`
+		}{Model: m, Conditions: now}
+		if ok {
+			p := int(math.Round(prediction))
+			out.Prediction = &p
+		}
+		data, err := json.MarshalIndent(out, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	}
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tLUX\tPOWER\tPERCENT")
+	for _, s := range m.Samples {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%.0f\n", s.Time.Format("2006-01-02 15:04"), lux(s.Lux), s.Power, s.Percent)
+	}
+	w.Flush()
+	if !ok {
+		return buf.String() + "no samples learned yet", nil
+	}
+	return buf.String() + fmt.Sprintf("learned brightness now, at %s lux on %s : %.0f%%", lux(now.Lux), now.Power, prediction), nil
+}
+
+// schedule renders the timeline of a schedule on the day of t, and its percentage at t, as printed by schedule show :
+// a table of the points followed by the sunrise, the sunset and the percentage by default, or a json object.
+func (f Format) schedule(s backlight.Schedule, t time.Time) (string, error) {
+	timeline := s.Timeline(t)
+	percent, ok := s.Percent(t)
+	var sunrise, sunset *time.Time
+	if s.Location != nil {
+		if rise, set, ok := s.Location.Sun(t); ok {
+			sunrise, sunset = &rise, &set
+		}
+	}
+	if f.mode() == "json" {
+		out := struct {
+			Timeline []backlight.TimelineEntry `json:"timeline"`
+			Sunrise  *time.Time                `json:"sunrise,omitempty"`
+			Sunset   *time.Time                `json:"sunset,omitempty"`
+			Time     time.Time                 `json:"time"`
+			Percent  *int                      `json:"percent"`
+		}{Timeline: timeline, Sunrise: sunrise, Sunset: sunset, Time: t}
+		if ok {
+			p := int(math.Round(percent))
+			out.Percent = &p
+		}
+		data, err := json.MarshalIndent(out, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	}
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tPOINT\tPERCENT")
+	for _, e := range timeline {
+		fmt.Fprintf(w, "%s\t%s\t%.0f\n", e.Time.Format("15:04"), e.Point, e.Percent)
+	}
+	w.Flush()
+	if sunrise != nil {
+		fmt.Fprintf(&buf, "sunrise at %s, sunset at %s\n", sunrise.Format("15:04"), sunset.Format("15:04"))
+	}
+	if !ok {
+		return buf.String() + "no points in the schedule today", nil
+	}
+	return buf.String() + fmt.Sprintf("brightness now, at %s : %.0f%%", t.Format("15:04"), percent), nil
+}
+
+// lux renders an illuminance, a dash when there is no sensor.
+func lux(v *float64) string {
+	if v == nil {
+		return "-"
+	}
+	return strconv.FormatFloat(*v, 'f', 0, 64)
+}
+
+// render executes the text/template with each data, one line per data.
+// It returns an error when the template is empty or invalid.
+func render(text string, data ...interface{}) (string, error) {
+	if text == "" {
+		return "", fmt.Errorf(templateMsg)
+	}
+	t, err := template.New("output").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	lines := make([]string, 0, len(data))
+	for _, d := range data {
+		var buf bytes.Buffer
+		if err := t.Execute(&buf, d); err != nil {
+			return "", err
+		}
+		lines = append(lines, buf.String())
+	}
+	return strings.Join(lines, "\n"), nil
+}
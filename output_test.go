@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/rustx/gobacklight/backlight"
+	. "gopkg.in/check.v1"
+)
+
+var sampleBlPower = int64(0)
+
+var sampleInfo = backlight.Info{
+	Name:             "intel_backlight",
+	Type:             "raw",
+	Brightness:       480,
+	MaxBrightness:    1200,
+	ActualBrightness: 480,
+	Percent:          40,
+	BlPower:          &sampleBlPower,
+	Writable:         true,
+}
+
+func (s *GobacklightSuite) TestFormatDeviceOk(c *C) {
+	for _, t := range []struct {
+		format Format
+		out    string
+	}{
+		{Format{Output: "plain"}, "40"},
+		{Format{Output: "raw"}, "480"},
+		{Format{Output: "template", Template: "☀ {{.Percent}}%"}, "☀ 40%"},
+		{Format{Output: "plain", Template: "{{.Name}} {{.ActualBrightness}}/{{.MaxBrightness}} {{.BlPower}}"}, "intel_backlight 480/1200 0"},
+	} {
+		out, err := t.format.device(sampleInfo)
+		c.Assert(err, IsNil)
+		c.Assert(out, Equals, t.out)
+	}
+}
+
+func (s *GobacklightSuite) TestFormatDeviceJSONOk(c *C) {
+	out, err := Format{Output: "json"}.device(sampleInfo)
+	c.Assert(err, IsNil)
+
+	var decoded backlight.Info
+	c.Assert(json.Unmarshal([]byte(out), &decoded), IsNil)
+	c.Assert(decoded, DeepEquals, sampleInfo)
+}
+
+func (s *GobacklightSuite) TestFormatDevicesTemplateOk(c *C) {
+	out, err := Format{Output: "template", Template: "{{.Name}}={{.Percent}}"}.devices([]backlight.Info{sampleInfo, sampleInfo})
+
+	c.Assert(err, IsNil)
+	c.Assert(out, Equals, "intel_backlight=40\nintel_backlight=40")
+}
+
+func (s *GobacklightSuite) TestFormatTemplateKo(c *C) {
+	_, err := Format{Output: "template"}.device(sampleInfo)
+	c.Assert(err, ErrorMatches, templateMsg)
+
+	_, err = Format{Template: "{{.Percent"}.device(sampleInfo)
+	c.Assert(err, ErrorMatches, "template: output:.*")
+
+	_, err = Format{Template: "{{.Unknown}}"}.device(sampleInfo)
+	c.Assert(err, ErrorMatches, "template: output:.*")
+}
+
+func (s *GobacklightSuite) TestRunGetCommandOutputOk(c *C) {
+	defer func(path string) { syspath = path }(syspath)
+	syspath = c.MkDir() + "/"
+
+	makeDevice(c, syspath, "intel_backlight", "raw")
+
+	for output, out := range map[string]string{"plain": "50", "raw": "500"} {
+		conf := Config{Device: "intel_backlight"}
+		conf.GetCommand.Output = output
+		bc := BrightnessControl{Config: &conf, Command: "get"}
+		v, err := bc.Run(context.Background())
+
+		c.Assert(err, IsNil)
+		c.Assert(v, Equals, out)
+	}
+
+	conf := Config{Device: "intel_backlight"}
+	conf.GetCommand.Template = "{{.Name}} {{.Brightness}}"
+	bc := BrightnessControl{Config: &conf, Command: "get"}
+	v, err := bc.Run(context.Background())
+
+	c.Assert(err, IsNil)
+	c.Assert(v, Equals, "intel_backlight 500")
+}
+
+func (s *GobacklightSuite) TestFormatEventOk(c *C) {
+	e := backlight.Event{Time: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), Info: sampleInfo}
+
+	out, err := Format{Output: "plain"}.event(e)
+	c.Assert(err, IsNil)
+	c.Assert(out, Equals, "40")
+
+	out, err = Format{Template: `{{.Time.Format "15:04:05"}} {{.Percent}}%`}.event(e)
+	c.Assert(err, IsNil)
+	c.Assert(out, Equals, "03:04:05 40%")
+
+	out, err = Format{Output: "json"}.event(e)
+	c.Assert(err, IsNil)
+	c.Assert(strings.Contains(out, "\n"), Equals, false)
+
+	var decoded backlight.Event
+	c.Assert(json.Unmarshal([]byte(out), &decoded), IsNil)
+	c.Assert(decoded.Time.Equal(e.Time), Equals, true)
+	c.Assert(decoded.Info, DeepEquals, sampleInfo)
+}
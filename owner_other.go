@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+import "os"
+
+// owned reports that the file belongs to the current user, the owner is only checked on Linux.
+func owned(info os.FileInfo) bool {
+	return true
+}
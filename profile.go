@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/rustx/gobacklight/backlight"
+)
+
+// ApplyProfileCommand is the apply-profile command, applying the profile of the power source.
+type ApplyProfileCommand struct {
+	Watch    bool          `long:"watch" description:"keep running, and apply the profile each time the power source or the battery level changes"`
+	Interval time.Duration `long:"interval" default:"2s" description:"polling interval of the power source and the battery level with --watch"`
+}
+
+// Caps caps the device with the profile of the power source and the rules of the battery level,
+// both read from powerpath on each Limit. The cap of a battery rule holds until the battery charges again,
+// saved in the state folder beside the model, so it holds for the commands run one at a time too.
+type Caps struct {
+	Profiles backlight.Profiles
+	Battery  backlight.BatteryCap
+}
+
+// caps returns the caps given by the ac, battery, ac-max, battery-max and battery-caps options.
+// It returns an error when a target is not a value of set, when a cap is not a percentage,
+// or when the battery rules are invalid.
+func (bc *BrightnessControl) caps() (*Caps, error) {
+	caps := &Caps{Profiles: backlight.Profiles{}}
+	profiles := map[backlight.PowerSource][2]string{
+		backlight.PowerAC:      {bc.Config.AC, bc.Config.ACMax},
+		backlight.PowerBattery: {bc.Config.Battery, bc.Config.BatteryMax},
+	}
+	// the sources are checked in order, so the error of two invalid profiles is always the one of ac
+	for _, source := range []backlight.PowerSource{backlight.PowerAC, backlight.PowerBattery} {
+		options := profiles[source]
+		var profile backlight.Profile
+		if target := options[0]; target != "" {
+			v, err := backlight.ParseValue(target)
+			if err != nil {
+				return nil, fmt.Errorf(profileMsg, source, err)
+			}
+			profile.Target = &v
+		}
+		if max := options[1]; max != "" {
+			p, err := parsePercent(max)
+			if err != nil {
+				return nil, fmt.Errorf(profileMsg, source, err)
+			}
+			profile.Cap = p
+		}
+		caps.Profiles[source] = profile
+	}
+	if bc.Config.BatteryCaps != "" {
+		rules, err := backlight.ParseBatteryRules(bc.Config.BatteryCaps)
+		if err != nil {
+			return nil, err
+		}
+		caps.Battery.Rules = rules
+		// the cap holds across the commands, until the battery charges
+		caps.Battery.Path = filepath.Join(filepath.Dir(bc.state()), "battery.json")
+	}
+	return caps, nil
+}
+
+// Limit sets the cap of the device for the current power source and battery level.
+// The power source is AC when it can't be read, like on a system without power_supply class.
+// It returns the reason of the cap when a battery rule sets it, like battery below 20%, and empty otherwise.
+func (c *Caps) Limit(d *backlight.Device) string {
+	source, err := backlight.ReadPowerSource(powerpath)
+	if err != nil {
+		source = backlight.PowerAC
+	}
+	return c.limit(d, source)
+}
+
+func (c *Caps) limit(d *backlight.Device, source backlight.PowerSource) string {
+	c.Profiles.Limit(d, source)
+	percent, reason := c.Profiles[source].Cap, ""
+	if battery, err := backlight.ReadBattery(powerpath); err == nil {
+		if rule, ok := c.Battery.Update(battery); ok && (percent == 0 || rule.Cap < percent) {
+			percent, reason = rule.Cap, fmt.Sprintf(batteryMsg, rule.Below)
+			d.SetCap(percent)
+		}
+	}
+	// the max option may be lower than the cap
+	if _, upper := d.Limits(); upper != d.Raw(percent) {
+		return ""
+	}
+	return reason
+}
+
+// Apply switches the device to the profile of the power source, like Profiles.Apply with the cap of the battery level.
+func (c *Caps) Apply(ctx context.Context, d *backlight.Device, source backlight.PowerSource) error {
+	c.limit(d, source)
+	return c.Profiles.Enter(ctx, d, source)
+}
+
+// capped returns the reason of the cap when the write of raw was clamped to it, empty otherwise.
+func capped(d *backlight.Device, raw int64, clamped bool, reason string) string {
+	if _, upper := d.Limits(); clamped && raw == upper {
+		return reason
+	}
+	return ""
+}
+
+func applyProfileAction(bc *BrightnessControl, ctx context.Context, arg string) (string, error) {
+	if err := bc.open(); err != nil {
+		return "", err
+	}
+	caps, err := bc.caps()
+	if err != nil {
+		return "", err
+	}
+	d := bc.Backlight
+	if !bc.Config.ApplyProfileCommand.Watch {
+		source, err := backlight.ReadPowerSource(powerpath)
+		if err != nil {
+			return "", err
+		}
+		return "", caps.Apply(ctx, d, source)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	sources := make(chan backlight.PowerSource)
+	done := make(chan error, 1)
+	go func() { done <- backlight.WatchPower(ctx, powerpath, bc.Config.ApplyProfileCommand.Interval, sources) }()
+	ticker := time.NewTicker(bc.Config.ApplyProfileCommand.Interval)
+	defer ticker.Stop()
+
+	source := backlight.PowerAC
+	for {
+		select {
+		case s, ok := <-sources:
+			if !ok {
+				return "", <-done
+			}
+			source = s
+			if err := d.Load(); err != nil {
+				return "", err
+			}
+			if err := caps.Apply(ctx, d, source); err != nil {
+				return "", err
+			}
+		case <-ticker.C:
+			// the battery level changes without the power source
+			before := d.Cap
+			caps.limit(d, source)
+			if d.Cap == before {
+				continue
+			}
+			if err := d.Load(); err != nil {
+				return "", err
+			}
+			if err := d.ApplyContext(ctx, backlight.Value{Unit: backlight.Raw, Relative: true}); err != nil {
+				return "", err
+			}
+		}
+	}
+}
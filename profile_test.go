@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+	"github.com/rustx/gobacklight/backlight"
+	. "gopkg.in/check.v1"
+)
+
+// makeSupplies creates a fake power_supply class in powerpath, with a battery and an adapter online or not.
+func makeSupplies(c *C, online string) {
+	for name, values := range map[string]map[string]string{
+		"BAT0": {"type": "Battery"},
+		"AC":   {"type": "Mains", "online": online},
+	} {
+		path := filepath.Join(powerpath, name)
+		if err := os.MkdirAll(path, 0755); err != nil {
+			c.Fatal(err)
+		}
+		for file, value := range values {
+			if err := ioutil.WriteFile(filepath.Join(path, file), []byte(value+"\n"), 0644); err != nil {
+				c.Fatal(err)
+			}
+		}
+	}
+}
+
+// brightness returns the content of a brightness file.
+func brightness(c *C, file string) string {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		c.Fatal(err)
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func (s *GobacklightSuite) TestRunApplyProfileOk(c *C) {
+	defer func(path, power string) { syspath, powerpath = path, power }(syspath, powerpath)
+	syspath, powerpath = c.MkDir()+"/", c.MkDir()
+	makeDevice(c, syspath, "intel_backlight", "raw")
+	makeSupplies(c, "0")
+
+	conf := Config{Device: "intel_backlight", AC: "100%", Battery: "40%"}
+	bc := BrightnessControl{Config: &conf, Command: "apply-profile"}
+	_, err := bc.Run(context.Background())
+	c.Assert(err, IsNil)
+	c.Assert(bc.Backlight.Brightness, Equals, int64(400))
+
+	makeSupplies(c, "1")
+	bc = BrightnessControl{Config: &conf, Command: "apply-profile"}
+	_, err = bc.Run(context.Background())
+	c.Assert(err, IsNil)
+	c.Assert(bc.Backlight.Brightness, Equals, int64(1000))
+}
+
+func (s *GobacklightSuite) TestRunApplyProfileWatchOk(c *C) {
+	defer func(path, power string) { syspath, powerpath = path, power }(syspath, powerpath)
+	syspath, powerpath = c.MkDir()+"/", c.MkDir()
+	makeDevice(c, syspath, "intel_backlight", "raw")
+	makeSupplies(c, "1")
+
+	conf := Config{Device: "intel_backlight", BatteryMax: "30%"}
+	conf.ApplyProfileCommand = ApplyProfileCommand{Watch: true, Interval: 5 * time.Millisecond}
+	bc := BrightnessControl{Config: &conf, Command: "apply-profile"}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := bc.Run(ctx)
+		done <- err
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	makeSupplies(c, "0")
+	file := filepath.Join(syspath, "intel_backlight", "brightness")
+	for i := 0; i < 100 && brightness(c, file) != "300"; i++ {
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	c.Assert(<-done, Equals, context.Canceled)
+	c.Assert(brightness(c, file), Equals, "300")
+}
+
+func (s *GobacklightSuite) TestRunSetCappedOk(c *C) {
+	defer func(path, power string) { syspath, powerpath = path, power }(syspath, powerpath)
+	syspath, powerpath = c.MkDir()+"/", c.MkDir()
+	makeDevice(c, syspath, "intel_backlight", "raw")
+	makeSupplies(c, "0")
+
+	conf := Config{Device: "intel_backlight", ACMax: "90%", BatteryMax: "60", NoLearn: true}
+	conf.SetCommand.Args.Value = "80%"
+	bc := BrightnessControl{Config: &conf, Command: "set"}
+	_, err := bc.Run(context.Background())
+
+	c.Assert(err, IsNil)
+	c.Assert(bc.Backlight.Brightness, Equals, int64(600))
+	percent, clamped := bc.clamped()
+	c.Assert(clamped, Equals, true)
+	c.Assert(percent, Equals, 60)
+}
+
+func (s *GobacklightSuite) TestParseProfileOk(c *C) {
+	var conf Config
+	parser := flags.NewParser(&conf, flags.PassDoubleDash)
+	args := strings.Fields("--ac 100% --battery 40% --battery-max 60% apply-profile --watch")
+	_, err := parser.ParseArgs(negativeArgs(parser, args))
+	c.Assert(err, IsNil)
+
+	bc := BrightnessControl{Config: &conf, Command: "apply-profile"}
+	caps, err := bc.caps()
+	c.Assert(err, IsNil)
+	c.Assert(caps.Profiles[backlight.PowerAC].Cap, Equals, float64(0))
+	c.Assert(caps.Profiles[backlight.PowerBattery].Cap, Equals, float64(60))
+	c.Assert(*caps.Profiles[backlight.PowerBattery].Target, Equals, backlight.Value{Unit: backlight.Percent, Amount: 40})
+}
+
+func (s *GobacklightSuite) TestRunApplyProfileKo(c *C) {
+	conf := Config{Battery: "bright"}
+	bc := BrightnessControl{Config: &conf, Command: "apply-profile"}
+	_, err := bc.Run(context.Background())
+
+	c.Assert(err, ErrorMatches, "Error battery profile : Error value must be .*")
+
+	for _, max := range []string{"bright", "1e1"} {
+		conf = Config{ACMax: max}
+		bc = BrightnessControl{Config: &conf, Command: "apply-profile"}
+		_, err = bc.Run(context.Background())
+
+		c.Assert(err, ErrorMatches, "Error ac profile : "+percentMsg)
+	}
+
+	// the first invalid profile is reported, whatever the run
+	for i := 0; i < 20; i++ {
+		conf = Config{AC: "bright", Battery: "dim"}
+		bc = BrightnessControl{Config: &conf, Command: "apply-profile"}
+		_, err = bc.Run(context.Background())
+
+		c.Assert(err, ErrorMatches, "Error ac profile : Error value must be .*")
+	}
+}
+
+// makeBattery sets the capacity and the status of the battery of the fake power_supply class.
+func makeBattery(c *C, capacity string, status string) {
+	path := filepath.Join(powerpath, "BAT0")
+	if err := os.MkdirAll(path, 0755); err != nil {
+		c.Fatal(err)
+	}
+	for file, value := range map[string]string{"type": "Battery", "capacity": capacity, "status": status} {
+		if err := ioutil.WriteFile(filepath.Join(path, file), []byte(value+"\n"), 0644); err != nil {
+			c.Fatal(err)
+		}
+	}
+}
+
+func (s *GobacklightSuite) TestRunSetBatteryCapOk(c *C) {
+	defer func(path, power string) { syspath, powerpath = path, power }(syspath, powerpath)
+	syspath, powerpath = c.MkDir()+"/", c.MkDir()
+	makeDevice(c, syspath, "intel_backlight", "raw")
+	makeSupplies(c, "0")
+	makeBattery(c, "15", "Discharging")
+
+	conf := Config{Device: "intel_backlight", BatteryMax: "60%", BatteryCaps: "20:40,10:25", NoLearn: true}
+	conf.SetCommand.Args.Value = "80%"
+	bc := BrightnessControl{Config: &conf, Command: "set"}
+	_, err := bc.Run(context.Background())
+
+	c.Assert(err, IsNil)
+	c.Assert(bc.Backlight.Brightness, Equals, int64(400))
+	percent, clamped := bc.clamped()
+	c.Assert(clamped, Equals, true)
+	c.Assert(percent, Equals, 40)
+	c.Assert(bc.clampReason(), Equals, "battery below 20%, until it charges")
+
+	conf.SetCommand.Args.Value = "30%"
+	bc = BrightnessControl{Config: &conf, Command: "set"}
+	_, err = bc.Run(context.Background())
+	c.Assert(err, IsNil)
+	c.Assert(bc.Backlight.Brightness, Equals, int64(300))
+	c.Assert(bc.clampReason(), Equals, "")
+
+	// the cap holds for the next commands, even with the capacity read above the level
+	makeBattery(c, "21", "Discharging")
+	conf.SetCommand.Args.Value = "80%"
+	bc = BrightnessControl{Config: &conf, Command: "set"}
+	_, err = bc.Run(context.Background())
+	c.Assert(err, IsNil)
+	c.Assert(bc.Backlight.Brightness, Equals, int64(400))
+	c.Assert(bc.clampReason(), Equals, "battery below 20%, until it charges")
+
+	makeBattery(c, "15", "Charging")
+	conf.SetCommand.Args.Value = "80%"
+	bc = BrightnessControl{Config: &conf, Command: "set"}
+	_, err = bc.Run(context.Background())
+	c.Assert(err, IsNil)
+	c.Assert(bc.Backlight.Brightness, Equals, int64(600))
+	c.Assert(bc.clampReason(), Equals, "")
+}
+
+func (s *GobacklightSuite) TestRunSetBatteryCapMaxOk(c *C) {
+	defer func(path, power string) { syspath, powerpath = path, power }(syspath, powerpath)
+	syspath, powerpath = c.MkDir()+"/", c.MkDir()
+	makeDevice(c, syspath, "intel_backlight", "raw")
+	makeBattery(c, "15", "Discharging")
+
+	conf := Config{Device: "intel_backlight", Max: 30, BatteryCaps: "20:40", NoLearn: true}
+	conf.SetCommand.Args.Value = "80%"
+	bc := BrightnessControl{Config: &conf, Command: "set"}
+	_, err := bc.Run(context.Background())
+
+	c.Assert(err, IsNil)
+	c.Assert(bc.Backlight.Brightness, Equals, int64(300))
+	c.Assert(bc.clampReason(), Equals, "")
+}
+
+func (s *GobacklightSuite) TestDaemonBatteryCapOk(c *C) {
+	defer func(power string) { powerpath = power }(powerpath)
+	powerpath = c.MkDir()
+	makeBattery(c, "8", "Discharging")
+	root := c.MkDir()
+	makeDevice(c, root, "intel_backlight", "raw")
+	d, err := backlight.Open(root, "intel_backlight")
+	if err != nil {
+		c.Fatal(err)
+	}
+	dm := &Daemon{Backlight: d, Caps: &Caps{Battery: backlight.BatteryCap{Rules: backlight.BatteryRules{{Below: 20, Cap: 40}, {Below: 10, Cap: 25}}}}}
+
+	resp := dm.Handle(context.Background(), Request{Command: "set", Value: "80%"})
+	c.Assert(resp.Error, Equals, "")
+	c.Assert(resp.Percent, Equals, 25)
+	c.Assert(resp.Clamped, Equals, true)
+	c.Assert(resp.Reason, Equals, "battery below 10%, until it charges")
+}
+
+func (s *GobacklightSuite) TestRunApplyProfileWatchBatteryOk(c *C) {
+	defer func(path, power string) { syspath, powerpath = path, power }(syspath, powerpath)
+	syspath, powerpath = c.MkDir()+"/", c.MkDir()
+	makeDevice(c, syspath, "intel_backlight", "raw")
+	makeSupplies(c, "0")
+	makeBattery(c, "50", "Discharging")
+
+	conf := Config{Device: "intel_backlight", BatteryCaps: "20:40"}
+	conf.ApplyProfileCommand = ApplyProfileCommand{Watch: true, Interval: 5 * time.Millisecond}
+	bc := BrightnessControl{Config: &conf, Command: "apply-profile"}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := bc.Run(ctx)
+		done <- err
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	file := filepath.Join(syspath, "intel_backlight", "brightness")
+	c.Assert(brightness(c, file), Equals, "500")
+	makeBattery(c, "19", "Discharging")
+	for i := 0; i < 100 && brightness(c, file) != "400"; i++ {
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	c.Assert(<-done, Equals, context.Canceled)
+	c.Assert(brightness(c, file), Equals, "400")
+}
+
+func (s *GobacklightSuite) TestRunBatteryCapsKo(c *C) {
+	conf := Config{BatteryCaps: "low:dim"}
+	conf.SetCommand.Args.Value = "80%"
+	bc := BrightnessControl{Config: &conf, Command: "set"}
+	_, err := bc.Run(context.Background())
+
+	c.Assert(err, Equals, backlight.ErrBatteryRules)
+}
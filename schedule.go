@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rustx/gobacklight/backlight"
+)
+
+// ScheduleCommand is the schedule command, setting the brightness along the day.
+// Its options are shared by its subcommands.
+type ScheduleCommand struct {
+	Points   string `long:"points" description:"brightness percentages along the day, as time=percent points like 07:00=80%,sunset-30m=50%,22:00=30%"`
+	Location string `long:"location" description:"latitude and longitude of the sunrise and sunset points, in degrees like 48.85,2.35"`
+
+	Show ScheduleShowCommand `command:"show" description:"print the timeline of the schedule for today"`
+	Run  ScheduleRunCommand  `command:"run" description:"set the brightness of the schedule, until interrupted"`
+}
+
+// ScheduleShowCommand is the schedule show command.
+type ScheduleShowCommand struct {
+	Output string `short:"o" long:"output" default:"table" choice:"table" choice:"json" description:"output format"`
+}
+
+// ScheduleRunCommand is the schedule run command.
+type ScheduleRunCommand struct {
+	Interval time.Duration `long:"interval" default:"1m" description:"interval between two updates of the brightness"`
+}
+
+// schedule returns the schedule given by the points and location options.
+func (bc *BrightnessControl) schedule() (backlight.Schedule, error) {
+	options := bc.Config.ScheduleCommand
+	var location *backlight.Location
+	if options.Location != "" {
+		fields := strings.Split(options.Location, ",")
+		if len(fields) != 2 {
+			return backlight.Schedule{}, fmt.Errorf(locationMsg)
+		}
+		lat, err := strconv.ParseFloat(strings.TrimSpace(fields[0]), 64)
+		if err != nil || lat < -90 || lat > 90 {
+			return backlight.Schedule{}, fmt.Errorf(locationMsg)
+		}
+		lon, err := strconv.ParseFloat(strings.TrimSpace(fields[1]), 64)
+		if err != nil || lon < -180 || lon > 180 {
+			return backlight.Schedule{}, fmt.Errorf(locationMsg)
+		}
+		location = &backlight.Location{Latitude: lat, Longitude: lon}
+	}
+	return backlight.ParseSchedule(options.Points, location)
+}
+
+func scheduleShowAction(bc *BrightnessControl, ctx context.Context, arg string) (string, error) {
+	schedule, err := bc.schedule()
+	if err != nil {
+		return "", err
+	}
+	return bc.Format.schedule(schedule, now())
+}
+
+func scheduleRunAction(bc *BrightnessControl, ctx context.Context, arg string) (string, error) {
+	schedule, err := bc.schedule()
+	if err != nil {
+		return "", err
+	}
+	if err := bc.open(); err != nil {
+		return "", err
+	}
+	scheduler := &backlight.Scheduler{Schedule: schedule, Interval: bc.Config.ScheduleCommand.Run.Interval, Clock: now}
+	return "", scheduler.Run(ctx, bc.Backlight)
+}
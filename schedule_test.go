@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+	"github.com/rustx/gobacklight/backlight"
+	. "gopkg.in/check.v1"
+)
+
+// at sets the clock of the commands to the time, and returns a function restoring it.
+func at(t time.Time) func() {
+	restore := now
+	now = func() time.Time { return t }
+	return func() { now = restore }
+}
+
+func (s *GobacklightSuite) TestParseScheduleCommandOk(c *C) {
+	for _, args := range [][]string{
+		{"schedule", "--points", "07:00=80%", "show", "-o", "json"},
+		{"schedule", "show", "--points", "07:00=80%", "-o", "json"},
+	} {
+		var conf Config
+		parser := flags.NewParser(&conf, flags.None)
+		parser.SubcommandsOptional = true
+		_, err := parser.ParseArgs(args)
+
+		c.Assert(err, IsNil)
+		c.Assert(parser.Active.Active.Name, Equals, "show")
+		c.Assert(conf.ScheduleCommand.Points, Equals, "07:00=80%")
+		c.Assert(conf.ScheduleCommand.Show.Output, Equals, "json")
+	}
+}
+
+func (s *GobacklightSuite) TestRunScheduleShowOk(c *C) {
+	cest := time.FixedZone("CEST", 2*3600)
+	defer at(time.Date(2024, 6, 21, 23, 30, 0, 0, cest))()
+
+	conf := Config{}
+	conf.ScheduleCommand = ScheduleCommand{Points: "07:00=80%,sunset-30m=50%,23:00=30%", Location: "48.8566,2.3522"}
+	conf.ScheduleCommand.Show.Output = "table"
+	bc := BrightnessControl{Config: &conf, Command: "schedule show"}
+	v, err := bc.Run(context.Background())
+
+	c.Assert(err, IsNil)
+	lines := strings.Split(v, "\n")
+	c.Assert(lines, HasLen, 6)
+	c.Assert(strings.Fields(lines[0]), DeepEquals, []string{"TIME", "POINT", "PERCENT"})
+	c.Assert(strings.Fields(lines[1]), DeepEquals, []string{"07:00", "07:00", "80"})
+	c.Assert(strings.Fields(lines[2])[1:], DeepEquals, []string{"sunset-30m", "50"})
+	c.Assert(strings.Fields(lines[3]), DeepEquals, []string{"23:00", "23:00", "30"})
+	c.Assert(strings.HasPrefix(lines[4], "sunrise at 05:4"), Equals, true, Commentf(lines[4]))
+	c.Assert(lines[5], Equals, "brightness now, at 23:30 : 33%")
+
+	conf.ScheduleCommand.Show.Output = "json"
+	bc = BrightnessControl{Config: &conf, Command: "schedule show"}
+	v, err = bc.Run(context.Background())
+	c.Assert(err, IsNil)
+	var out struct {
+		Timeline []backlight.TimelineEntry `json:"timeline"`
+		Sunset   time.Time                 `json:"sunset"`
+		Percent  int                       `json:"percent"`
+	}
+	c.Assert(json.Unmarshal([]byte(v), &out), IsNil)
+	c.Assert(out.Timeline, HasLen, 3)
+	c.Assert(out.Sunset.IsZero(), Equals, false)
+	c.Assert(out.Percent, Equals, 33)
+}
+
+func (s *GobacklightSuite) TestRunScheduleRunOk(c *C) {
+	defer at(time.Date(2024, 3, 1, 14, 0, 0, 0, time.UTC))()
+
+	conf := Config{}
+	conf.ScheduleCommand = ScheduleCommand{Points: "08:00=80%,20:00=40%"}
+	conf.ScheduleCommand.Run.Interval = 5 * time.Millisecond
+	bc := BrightnessControl{Config: &conf, Command: "schedule run"}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := bc.Run(ctx)
+
+	c.Assert(err, Equals, context.DeadlineExceeded)
+	c.Assert(bc.Backlight.Brightness, Equals, bc.Backlight.Raw(60))
+}
+
+func (s *GobacklightSuite) TestRunScheduleKo(c *C) {
+	conf := Config{}
+	conf.ScheduleCommand = ScheduleCommand{Points: "sunset=50%"}
+	bc := BrightnessControl{Config: &conf, Command: "schedule show"}
+	_, err := bc.Run(context.Background())
+	c.Assert(err, Equals, backlight.ErrLocation)
+
+	conf.ScheduleCommand.Location = "paris"
+	_, err = bc.Run(context.Background())
+	c.Assert(err, ErrorMatches, locationMsg)
+
+	conf.ScheduleCommand = ScheduleCommand{}
+	_, err = bc.Run(context.Background())
+	c.Assert(err, Equals, backlight.ErrSchedule)
+}